@@ -0,0 +1,137 @@
+package main
+
+import (
+	"antizapret-admin-panel/internal/entity"
+	"antizapret-admin-panel/internal/service"
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+)
+
+const usageText = `Использование:
+  antizapret-admin-panel                          запуск веб-панели
+  antizapret-admin-panel user add <логин>         создать пользователя (-role admin|operator|viewer)
+  antizapret-admin-panel user passwd <логин>      сменить пароль пользователя
+  antizapret-admin-panel user delete <логин>      удалить пользователя
+  antizapret-admin-panel user reset-totp <логин>  отключить двухфакторную аутентификацию
+  antizapret-admin-panel user list                список пользователей
+
+Пароль передается флагом -password, иначе читается из стандартного ввода.`
+
+// runCommand выполняет подкоманду CLI и возвращает код выхода процесса.
+func runCommand(args []string, users service.UserService, totp service.TOTPService) int {
+	if args[0] != "user" || len(args) < 2 {
+		fmt.Fprintln(os.Stderr, usageText)
+		return 2
+	}
+
+	// Логин идет сразу после подкоманды, флаги — после логина
+	var username string
+	flagArgs := args[2:]
+	if len(flagArgs) > 0 && !strings.HasPrefix(flagArgs[0], "-") {
+		username, flagArgs = flagArgs[0], flagArgs[1:]
+	}
+
+	flags := flag.NewFlagSet("user "+args[1], flag.ContinueOnError)
+	password := flags.String("password", "", "пароль пользователя")
+	role := flags.String("role", entity.RoleAdmin, "роль пользователя: admin, operator или viewer")
+	if err := flags.Parse(flagArgs); err != nil {
+		return 2
+	}
+
+	var err error
+	switch args[1] {
+	case "list":
+		err = listUsers(users)
+	case "add", "passwd", "delete", "reset-totp":
+		if username == "" || flags.NArg() != 0 {
+			fmt.Fprintln(os.Stderr, usageText)
+			return 2
+		}
+
+		switch args[1] {
+		case "add":
+			err = withPassword(*password, func(p string) error {
+				_, err := users.CreateUser(username, p, *role)
+				return err
+			})
+		case "passwd":
+			err = withPassword(*password, func(p string) error {
+				return users.ChangePassword(username, p)
+			})
+		case "delete":
+			err = users.DeleteUser(username)
+		case "reset-totp":
+			err = totp.Reset(username)
+		}
+	default:
+		fmt.Fprintln(os.Stderr, usageText)
+		return 2
+	}
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Ошибка: %v\n", err)
+		return 1
+	}
+	if args[1] != "list" {
+		fmt.Println("Готово")
+	}
+	return 0
+}
+
+func listUsers(users service.UserService) error {
+	list, err := users.ListUsers()
+	if err != nil {
+		return err
+	}
+	for _, user := range list {
+		fmt.Printf("%s\t%s\tсоздан %s\n", user.Username, user.Role, user.CreatedAt.Format("2006-01-02 15:04"))
+	}
+	return nil
+}
+
+// withPassword берет пароль из флага или читает его строкой из стандартного ввода.
+func withPassword(password string, fn func(string) error) error {
+	if password == "" {
+		fmt.Fprint(os.Stderr, "Пароль: ")
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && line == "" {
+			return errors.New("не удалось прочитать пароль")
+		}
+		password = strings.TrimRight(line, "\r\n")
+	}
+	return fn(password)
+}
+
+// bootstrapAdmin создает первого администратора из ADMIN_USERNAME/ADMIN_PASSWORD,
+// если хранилище пользователей пустое. Так существующие установки переходят
+// на хранилище пользователей без ручных действий.
+func bootstrapAdmin(users service.UserService) {
+	list, err := users.ListUsers()
+	if err != nil {
+		log.Fatal("Failed to load users: ", err)
+	}
+	if len(list) > 0 {
+		return
+	}
+
+	password := os.Getenv("ADMIN_PASSWORD")
+	if password == "" {
+		log.Println("Пользователей нет. Создайте администратора: antizapret-admin-panel user add <логин>")
+		return
+	}
+
+	username := os.Getenv("ADMIN_USERNAME")
+	if username == "" {
+		username = "admin"
+	}
+
+	if _, err := users.CreateUser(username, password, entity.RoleAdmin); err != nil {
+		log.Fatal("Failed to create initial admin from ADMIN_USERNAME/ADMIN_PASSWORD: ", err)
+	}
+	log.Printf("Создан первый администратор %s из переменных окружения", username)
+}
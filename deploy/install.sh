@@ -4,7 +4,7 @@
 #
 # Делает следующее:
 # 1. Скачивает последний релиз (tar.gz).
-# 2. Запрашивает пароль и сохраняет его в systemd.
+# 2. Запрашивает пароль и сохраняет его в systemd, создает /etc/antizapret-admin/config.yaml.
 # 3. Останавливает службу (если уже установлена).
 # 4. Распаковывает и обновляет бинарник и service-файл.
 # 5. Перезапускает службу.
@@ -17,6 +17,8 @@ BINARY_NAME="antizapret-admin-panel"
 INSTALL_DIR="/usr/local/bin"
 SYSTEMD_DIR="/etc/systemd/system"
 WORK_DIR="/usr/local/share/antizapret-admin"
+CONFIG_DIR="/etc/antizapret-admin"
+CONFIG_FILE="$CONFIG_DIR/config.yaml"
 
 echo_info() { echo -e "\033[34m[INFO]\033[0m $1"; }
 echo_error() { echo -e "\033[31m[ERROR]\033[0m $1"; exit 1; }
@@ -124,13 +126,60 @@ cat > "$OVERRIDE_FILE" << EOF
 [Service]
 Environment="ADMIN_USERNAME=$FINAL_USERNAME"
 Environment="ADMIN_PASSWORD=$FINAL_PASSWORD"
-Environment="OPENVPN_CLIENTS_PATH=/root/antizapret/client/openvpn/vpn-udp/"
-Environment="OPENVPN_ANTIZAPRET_PATH=/root/antizapret/client/openvpn/antizapret-udp/"
-Environment="CLIENT_SCRIPT_PATH=/root/antizapret/client.sh"
 EOF
 
 echo_info "Учетные данные сохранены в конфигурации systemd."
 
+# Пути из файла конфигурации читают и служба, и CLI (antizapret-admin-panel user ...).
+# Существующий файл не перезаписывается, чтобы сохранить ручные изменения.
+if [ ! -f "$CONFIG_FILE" ]; then
+    mkdir -p "$CONFIG_DIR"
+    cat > "$CONFIG_FILE" << EOF
+# Конфигурация Antizapret Admin Panel. Переменные окружения с прежними именами
+# (OPENVPN_CLIENTS_PATH, DATA_PATH и т.д.) переопределяют значения из файла.
+listen_addr: ":8080"
+# Внешний адрес панели, нужен для QR-кодов со ссылками на скачивание
+# public_base_url: https://vpn.example.com:8080
+# Обратные прокси (nginx и т.п.), которым можно верить в X-Forwarded-For
+# trusted_proxies: ["127.0.0.1"]
+data_path: $WORK_DIR/
+# Меньше TimeoutStopSec в unit-файле
+shutdown_timeout: 60s
+antizapret:
+  root: /root/antizapret/
+  client_script: /root/antizapret/client.sh
+  doall_script: /root/antizapret/doall.sh
+  client_profiles: /root/antizapret/client/
+  config_path: /root/antizapret/config/
+openvpn:
+  clients_path: /root/antizapret/client/openvpn/vpn-udp/
+  antizapret_path: /root/antizapret/client/openvpn/antizapret-udp/
+  status_path: /etc/openvpn/server/logs/
+  pki_path: /etc/openvpn/easyrsa3/pki/
+  management_path: /run/openvpn-server/
+wireguard:
+  config_path: /etc/wireguard/
+auth:
+  session_ttl: 12h
+  download_token_store: file
+# Резервные копии AntiZapret: сколько последних архивов хранить (0 — все)
+backups:
+  path: $WORK_DIR/backups/
+  keep: 10
+  knot_resolver_path: /etc/knot-resolver/
+# HTTPS: mode file (cert_file и key_file) или acme (сертификат Let's Encrypt)
+# tls:
+#   mode: acme
+#   http_addr: ":80"
+#   acme:
+#     domains: [vpn.example.com]
+#     email: admin@example.com
+EOF
+    echo_info "Создан файл конфигурации $CONFIG_FILE"
+else
+    echo_info "Используется существующий файл конфигурации $CONFIG_FILE"
+fi
+
 # 3. Скачивание
 TMP_DIR=$(mktemp -d)
 trap 'rm -rf -- "$TMP_DIR"' EXIT
@@ -6,6 +6,7 @@ SERVICE_NAME="antizapret-admin.service"
 BINARY_PATH="/usr/local/bin/antizapret-admin-panel"
 UNINSTALL_SCRIPT_PATH="/usr/local/bin/antizapret-admin-uninstall"
 WORK_DIR="/usr/local/share/antizapret-admin"
+CONFIG_DIR="/etc/antizapret-admin"
 
 echo "Остановка сервиса..."
 systemctl stop $SERVICE_NAME || true
@@ -24,5 +25,6 @@ rm -f $UNINSTALL_SCRIPT_PATH
 # Опционально: удаление рабочей директории (раскомментировать, если нужно удалять данные)
 # echo "Удаление рабочих данных..."
 # rm -rf $WORK_DIR
+# rm -rf $CONFIG_DIR
 
 echo "Antizapret Admin Panel успешно удалена."
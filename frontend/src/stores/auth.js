@@ -17,11 +17,16 @@ export const useAuthStore = defineStore('auth', () => {
     }
   }
 
+  // Возвращает challenge, если у пользователя включен TOTP: тогда вход завершается через verifyTotp
   async function login(username, password) {
     try {
       const response = await api.post('/api/login', { username, password });
+      if (response.data.totpRequired) {
+        return response.data.challenge;
+      }
       setToken(response.data.token);
       await router.push('/');
+      return null;
     } catch (error) {
       console.error('Login failed:', error);
       setToken(null);
@@ -29,8 +34,26 @@ export const useAuthStore = defineStore('auth', () => {
     }
   }
 
+  async function verifyTotp(challenge, code) {
+    try {
+      const response = await api.post('/api/login/totp', { challenge, code });
+      setToken(response.data.token);
+      await router.push('/');
+    } catch (error) {
+      console.error('Two-factor verification failed:', error);
+      setToken(null);
+      throw error;
+    }
+  }
+
   function logout() {
+    // Отзываем сессию на сервере. Токен передаем явно: к этому моменту он уже удален из хранилища,
+    // поэтому 401 от сервера не вызовет повторный logout.
+    const currentToken = token.value;
     setToken(null);
+    if (currentToken) {
+      api.post('/api/logout', null, { headers: { 'X-Auth-Token': currentToken } }).catch(() => {});
+    }
     router.push('/login');
   }
 
@@ -47,5 +70,5 @@ export const useAuthStore = defineStore('auth', () => {
     }
   }
 
-  return { token, isAuthenticated, login, logout, checkAuth, setToken };
+  return { token, isAuthenticated, login, verifyTotp, logout, checkAuth, setToken };
 });
@@ -0,0 +1,69 @@
+package api
+
+import (
+	"antizapret-admin-panel/internal/entity"
+	"antizapret-admin-panel/internal/middleware"
+	"antizapret-admin-panel/internal/service"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AntiZapretHandler применяет списки маршрутизации запуском doall.sh.
+type AntiZapretHandler struct {
+	service service.AntiZapretService
+	audit   service.AuditService
+}
+
+// NewAntiZapretHandler — конструктор обработчика.
+func NewAntiZapretHandler(s service.AntiZapretService, audit service.AuditService) *AntiZapretHandler {
+	return &AntiZapretHandler{service: s, audit: audit}
+}
+
+// Apply запускает doall.sh фоновой задачей и сразу отвечает 202 с ID задачи.
+// Вывод скрипта отдает GET /api/jobs/:id/stream. Если doall.sh уже запущен, ответ 409 с ID текущей задачи.
+func (h *AntiZapretHandler) Apply(c *gin.Context) {
+	var startedBy string
+	if user := middleware.CurrentUser(c); user != nil {
+		startedBy = user.Username
+	}
+
+	job, err := h.service.Apply(startedBy)
+	if errors.Is(err, service.ErrApplyInProgress) {
+		c.JSON(http.StatusConflict, gin.H{"error": "doall.sh is already running", "job": jobLinks(job)})
+		return
+	}
+	h.audit.Record(newAuditEntry(c, entity.AuditActionApply, nil, err))
+	if errors.Is(err, service.ErrJobQueueFull) {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Too many pending operations, try again later"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start doall.sh", "details": err.Error()})
+		return
+	}
+
+	c.Header("Location", "/api/jobs/"+job.ID)
+	c.JSON(http.StatusAccepted, jobLinks(job))
+}
+
+// GetApplyStatus возвращает текущий запуск doall.sh, последний и последний успешный.
+func (h *AntiZapretHandler) GetApplyStatus(c *gin.Context) {
+	status, err := h.service.Status()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get apply status", "details": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, status)
+}
+
+// jobLinks описывает задачу для ответа: ID, статус и адреса для отслеживания.
+func jobLinks(job *entity.Job) gin.H {
+	return gin.H{
+		"jobId":     job.ID,
+		"status":    job.Status,
+		"statusUrl": "/api/jobs/" + job.ID,
+		"streamUrl": "/api/jobs/" + job.ID + "/stream",
+	}
+}
@@ -0,0 +1,83 @@
+package api
+
+import (
+	"antizapret-admin-panel/internal/entity"
+	"antizapret-admin-panel/internal/middleware"
+	"antizapret-admin-panel/internal/service"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AuditHandler отдает журнал аудита.
+type AuditHandler struct {
+	service service.AuditService
+}
+
+// NewAuditHandler — конструктор обработчика журнала аудита.
+func NewAuditHandler(s service.AuditService) *AuditHandler {
+	return &AuditHandler{service: s}
+}
+
+// GetAudit возвращает страницу журнала. Фильтры: actor, action, client (ID или имя), outcome,
+// from и to (RFC 3339).
+func (h *AuditHandler) GetAudit(c *gin.Context) {
+	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil || limit < 1 || limit > 500 {
+		limit = 50
+	}
+
+	filter := entity.AuditFilter{
+		Actor:   c.Query("actor"),
+		Action:  c.Query("action"),
+		Client:  c.Query("client"),
+		Outcome: c.Query("outcome"),
+	}
+	for param, target := range map[string]*time.Time{"from": &filter.From, "to": &filter.To} {
+		value := c.Query(param)
+		if value == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid '" + param + "' parameter, expected RFC 3339 time"})
+			return
+		}
+		*target = t
+	}
+
+	entries, err := h.service.List(filter, page, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read audit log", "details": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, entries)
+}
+
+// newAuditEntry заполняет запись аудита данными запроса: пользователем, IP, клиентом и результатом.
+func newAuditEntry(c *gin.Context, action string, client *entity.Client, err error) entity.AuditEntry {
+	entry := entity.AuditEntry{
+		IP:      c.ClientIP(),
+		Action:  action,
+		Outcome: entity.AuditOutcomeSuccess,
+	}
+	if user := middleware.CurrentUser(c); user != nil {
+		entry.Actor = user.Username
+	}
+	if client != nil {
+		entry.ClientID = client.ID
+		entry.ClientName = client.Name
+		entry.ClientType = client.Type
+	}
+	if err != nil {
+		entry.Outcome = entity.AuditOutcomeFailure
+		entry.Error = err.Error()
+	}
+	return entry
+}
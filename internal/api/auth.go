@@ -0,0 +1,187 @@
+package api
+
+import (
+	"antizapret-admin-panel/internal/entity"
+	"antizapret-admin-panel/internal/middleware"
+	"antizapret-admin-panel/internal/repository"
+	"antizapret-admin-panel/internal/service"
+	"errors"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// LoginRequest представляет структуру данных для запроса на вход.
+type LoginRequest struct {
+	Username string `json:"username" binding:"required"`
+	Password string `json:"password" binding:"required"`
+}
+
+// VerifyTOTPRequest представляет структуру данных для второго шага входа.
+// Code — шестизначный код из приложения или код восстановления.
+type VerifyTOTPRequest struct {
+	Challenge string `json:"challenge" binding:"required"`
+	Code      string `json:"code" binding:"required"`
+}
+
+// AuthHandler обрабатывает вход в панель и управление сессиями.
+type AuthHandler struct {
+	auth  service.AuthService
+	audit service.AuditService
+}
+
+// NewAuthHandler — конструктор обработчика аутентификации.
+func NewAuthHandler(auth service.AuthService, audit service.AuditService) *AuthHandler {
+	return &AuthHandler{auth: auth, audit: audit}
+}
+
+// Login обрабатывает запросы на вход.
+func (h *AuthHandler) Login(c *gin.Context) {
+	var req LoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	token, session, err := h.auth.Login(req.Username, req.Password, c.ClientIP(), c.Request.UserAgent())
+	var totpRequired *service.TOTPRequiredError
+	if !errors.As(err, &totpRequired) {
+		entry := newAuditEntry(c, entity.AuditActionLogin, nil, err)
+		entry.Actor = req.Username
+		h.audit.Record(entry)
+	}
+	if totpRequired != nil {
+		// Пароль верен, но сессия будет выдана только после /api/login/totp
+		c.JSON(http.StatusOK, gin.H{"totpRequired": true, "challenge": totpRequired.Challenge, "expiresAt": totpRequired.ExpiresAt})
+		return
+	}
+	if respondLoginError(c, req.Username, err) {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"token": token, "expiresAt": session.ExpiresAt})
+}
+
+// VerifyTOTP обрабатывает второй шаг входа для пользователей с TOTP.
+func (h *AuthHandler) VerifyTOTP(c *gin.Context) {
+	var req VerifyTOTPRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	token, session, err := h.auth.VerifyTOTP(req.Challenge, req.Code, c.ClientIP(), c.Request.UserAgent())
+	entry := newAuditEntry(c, entity.AuditActionLoginTOTP, nil, err)
+	if session != nil {
+		entry.Actor = session.Username
+	}
+	h.audit.Record(entry)
+	if errors.Is(err, service.ErrInvalidChallenge) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Login challenge expired, sign in again"})
+		return
+	}
+	if errors.Is(err, service.ErrInvalidTOTPCode) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid two-factor code"})
+		return
+	}
+	if respondLoginError(c, "", err) {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"token": token, "expiresAt": session.ExpiresAt})
+}
+
+// respondLoginError отвечает на ошибку входа и возвращает true, если ответ уже отправлен.
+func respondLoginError(c *gin.Context, username string, err error) bool {
+	if err == nil {
+		return false
+	}
+
+	var locked *service.LoginLockedError
+	switch {
+	case errors.As(err, &locked):
+		retryAfter := int(math.Ceil(locked.RetryAfter.Seconds()))
+		c.Header("Retry-After", strconv.Itoa(retryAfter))
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many failed login attempts", "retryAfter": retryAfter})
+	case errors.Is(err, service.ErrInvalidCredentials):
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
+	default:
+		log.Printf("Login failed for %s: %v", username, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Login failed"})
+	}
+	return true
+}
+
+// Logout отзывает текущую сессию.
+func (h *AuthHandler) Logout(c *gin.Context) {
+	if err := h.auth.Logout(c.GetHeader("X-Auth-Token")); err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// CheckAuth обрабатывает запросы на проверку аутентификации.
+func (h *AuthHandler) CheckAuth(c *gin.Context) {
+	user := middleware.CurrentUser(c)
+	session := middleware.CurrentSession(c)
+	c.JSON(http.StatusOK, gin.H{
+		"status":      "ok",
+		"username":    user.Username,
+		"role":        user.Role,
+		"totpEnabled": user.TOTPEnabled,
+		"permissions": entity.RolePermissions(user.Role),
+		"sessionId":   session.ID,
+		"expiresAt":   session.ExpiresAt,
+	})
+}
+
+// GetSessions возвращает действующие сессии всех пользователей.
+func (h *AuthHandler) GetSessions(c *gin.Context) {
+	sessions, err := h.auth.ListSessions()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve sessions", "details": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, sessions)
+}
+
+// RevokeSession отзывает сессию по ID.
+func (h *AuthHandler) RevokeSession(c *gin.Context) {
+	err := h.auth.RevokeSession(c.Param("id"))
+	if errors.Is(err, repository.ErrSessionNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Session not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke session", "details": err.Error()})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// GetLoginLocks возвращает IP-адреса и логины, вход для которых временно заблокирован.
+func (h *AuthHandler) GetLoginLocks(c *gin.Context) {
+	locks := h.auth.ListLoginLocks()
+	if locks == nil {
+		locks = []entity.LoginLock{}
+	}
+	c.JSON(http.StatusOK, locks)
+}
+
+// UnlockLogin снимает блокировку входа для IP-адреса или логина.
+func (h *AuthHandler) UnlockLogin(c *gin.Context) {
+	kind := c.Param("kind")
+	if kind != entity.LoginLockKindIP && kind != entity.LoginLockKindUsername {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid lock kind, use 'ip' or 'username'"})
+		return
+	}
+	if !h.auth.UnlockLogin(kind, c.Param("key")) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Lock not found"})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
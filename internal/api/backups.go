@@ -0,0 +1,211 @@
+package api
+
+import (
+	"antizapret-admin-panel/internal/entity"
+	"antizapret-admin-panel/internal/repository"
+	"antizapret-admin-panel/internal/service"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxBackupUploadSize ограничивает размер загружаемого архива
+const maxBackupUploadSize = 64 << 20
+
+// BackupHandler создает, отдает, удаляет резервные копии AntiZapret и восстанавливает из них файлы.
+type BackupHandler struct {
+	service service.BackupService
+	jobs    service.JobQueue
+	audit   service.AuditService
+}
+
+// NewBackupHandler — конструктор обработчика резервных копий.
+func NewBackupHandler(s service.BackupService, jobs service.JobQueue, audit service.AuditService) *BackupHandler {
+	return &BackupHandler{service: s, jobs: jobs, audit: audit}
+}
+
+// GetBackups возвращает архивы с размером и датой создания, новые первыми.
+func (h *BackupHandler) GetBackups(c *gin.Context) {
+	backups, err := h.service.List()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list backups", "details": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, backups)
+}
+
+// CreateBackup собирает архив в общей очереди client.sh: он не должен собираться
+// посреди выпуска или отзыва сертификата. Поддерживает async=true, как операции с клиентами.
+func (h *BackupHandler) CreateBackup(c *gin.Context) {
+	var (
+		backup *entity.Backup
+		pruned []string
+		err    error
+	)
+	auditCtx := c.Copy()
+	if !runJob(c, h.jobs, entity.Job{Action: entity.AuditActionBackupCreate}, func(ctx context.Context, w io.Writer) (any, error) {
+		var output bytes.Buffer
+		backup, pruned, err = h.service.Create(ctx, io.MultiWriter(w, &output))
+		entry := newAuditEntry(auditCtx, entity.AuditActionBackupCreate, nil, err)
+		if backup != nil {
+			entry.Target = backup.Name
+		}
+		entry.Output = output.String()
+		h.audit.Record(entry)
+		if err != nil {
+			return nil, err
+		}
+		return gin.H{"backup": backup, "pruned": pruned}, nil
+	}) {
+		return
+	}
+
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create backup", "details": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"backup": backup, "pruned": pruned})
+}
+
+// DownloadBackup отдает архив. Скачивания пишутся в журнал аудита: в архиве закрытые ключи.
+func (h *BackupHandler) DownloadBackup(c *gin.Context) {
+	name := c.Param("name")
+	path, err := h.service.Path(name)
+
+	entry := newAuditEntry(c, entity.AuditActionBackupDownload, nil, err)
+	entry.Target = name
+	h.audit.Record(entry)
+
+	if err != nil {
+		respondBackupError(c, err, "Failed to get backup")
+		return
+	}
+	c.FileAttachment(path, name)
+}
+
+// DeleteBackup удаляет архив.
+func (h *BackupHandler) DeleteBackup(c *gin.Context) {
+	name := c.Param("name")
+	err := h.service.Delete(name)
+
+	entry := newAuditEntry(c, entity.AuditActionBackupDelete, nil, err)
+	entry.Target = name
+	h.audit.Record(entry)
+
+	if err != nil {
+		respondBackupError(c, err, "Failed to delete backup")
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// UploadBackup принимает архив (поле file в multipart/form-data или тело запроса целиком),
+// проверяет его и сохраняет рядом с остальными. Ответ — сохраненный архив и предпросмотр восстановления.
+func (h *BackupHandler) UploadBackup(c *gin.Context) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxBackupUploadSize)
+
+	var body io.Reader = c.Request.Body
+	if c.ContentType() == "multipart/form-data" {
+		header, err := c.FormFile("file")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "file field is required", "details": err.Error()})
+			return
+		}
+		file, err := header.Open()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read uploaded file", "details": err.Error()})
+			return
+		}
+		defer file.Close()
+		body = file
+	}
+
+	backup, preview, err := h.service.Upload(body)
+	entry := newAuditEntry(c, entity.AuditActionBackupUpload, nil, err)
+	if backup != nil {
+		entry.Target = backup.Name
+	}
+	h.audit.Record(entry)
+
+	if err != nil {
+		respondBackupError(c, err, "Failed to save backup")
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"backup": backup, "preview": preview})
+}
+
+// PreviewRestore проверяет архив и показывает, какие файлы восстановление создаст или перезапишет.
+func (h *BackupHandler) PreviewRestore(c *gin.Context) {
+	preview, err := h.service.Preview(c.Param("name"))
+	if err != nil {
+		respondBackupError(c, err, "Failed to preview restore")
+		return
+	}
+	c.JSON(http.StatusOK, preview)
+}
+
+// RestoreBackup записывает файлы архива на место в общей очереди client.sh.
+// Перед этим текущее состояние сохраняется в новый архив (safetyBackup в ответе).
+// Службы OpenVPN, WireGuard и knot-resolver панель не перезапускает.
+func (h *BackupHandler) RestoreBackup(c *gin.Context) {
+	name := c.Param("name")
+	// Неверный архив отклоняется сразу, а не после постановки в очередь
+	if _, err := h.service.Preview(name); err != nil {
+		respondBackupError(c, err, "Failed to read backup")
+		return
+	}
+
+	var (
+		result *entity.RestoreResult
+		err    error
+	)
+	auditCtx := c.Copy()
+	if !runJob(c, h.jobs, entity.Job{Action: entity.AuditActionBackupRestore}, func(ctx context.Context, w io.Writer) (any, error) {
+		var output bytes.Buffer
+		result, err = h.service.Restore(ctx, name, io.MultiWriter(w, &output))
+		entry := newAuditEntry(auditCtx, entity.AuditActionBackupRestore, nil, err)
+		entry.Target = name
+		entry.Output = output.String()
+		h.audit.Record(entry)
+		return result, err
+	}) {
+		return
+	}
+
+	if err != nil {
+		response := gin.H{"error": "Failed to restore backup", "details": err.Error()}
+		if result != nil {
+			response["safetyBackup"] = result.SafetyBackup
+		}
+		if errors.Is(err, repository.ErrInvalidBackup) {
+			c.JSON(http.StatusBadRequest, response)
+			return
+		}
+		if errors.Is(err, service.ErrConfigBusy) {
+			c.JSON(http.StatusConflict, response)
+			return
+		}
+		c.JSON(http.StatusInternalServerError, response)
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+func respondBackupError(c *gin.Context, err error, message string) {
+	// Обрезанная по лимиту загрузка выглядит и как поврежденный архив, поэтому проверяется первой
+	var maxBytesErr *http.MaxBytesError
+	switch {
+	case errors.As(err, &maxBytesErr):
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Backup archive is too large"})
+	case errors.Is(err, repository.ErrBackupNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Backup not found"})
+	case errors.Is(err, repository.ErrInvalidBackup):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid backup archive", "details": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message, "details": err.Error()})
+	}
+}
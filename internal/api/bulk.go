@@ -0,0 +1,252 @@
+package api
+
+import (
+	"antizapret-admin-panel/internal/entity"
+	"antizapret-admin-panel/internal/service"
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxBulkBodySize ограничивает размер CSV/JSON с пакетом клиентов
+const maxBulkBodySize = 1 << 20
+
+// BulkCreateClients создает клиентов пакетом из CSV или JSON.
+// Все строки проверяются до запуска client.sh: при любой ошибке пакет отклоняется целиком с отчетом по строкам.
+// Затем клиенты создаются одной задачей очереди, ответ — отчет по каждой строке.
+// С zip=true вместо JSON отдается архив с report.json и профилями созданных клиентов.
+func (h *ClientHandler) BulkCreateClients(c *gin.Context) {
+	wantZip, err := strconv.ParseBool(c.DefaultQuery("zip", "false"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid zip. Must be true or false."})
+		return
+	}
+	if async, _ := strconv.ParseBool(c.Query("async")); async && wantZip {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "zip=true is not supported with async=true"})
+		return
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxBulkBodySize)
+	rows, err := readBulkRows(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid bulk request", "details": err.Error()})
+		return
+	}
+
+	results, err := h.service.ValidateBulk(rows)
+	switch {
+	case errors.Is(err, service.ErrBulkInvalid):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Bulk request contains invalid rows, no clients were created", "results": results})
+		return
+	case errors.Is(err, service.ErrBulkEmpty), errors.Is(err, service.ErrBulkTooLarge):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to validate bulk request", "details": err.Error()})
+		return
+	}
+
+	var report *entity.BulkClientReport
+	auditCtx := c.Copy()
+	job := entity.Job{Action: entity.JobActionClientBulkCreate}
+	if !runJob(c, h.jobs, job, func(ctx context.Context, w io.Writer) (any, error) {
+		report = h.service.CreateClients(ctx, rows)
+
+		for _, result := range report.Results {
+			if result.Status == entity.BulkRowSkipped {
+				continue
+			}
+			var rowErr error
+			if result.Error != "" {
+				rowErr = errors.New(result.Error)
+			}
+			entry := newAuditEntry(auditCtx, entity.AuditActionClientCreate, result.Client, rowErr)
+			entry.ClientName, entry.ClientType, entry.Output = result.Name, result.Type, result.Output
+			h.audit.Record(entry)
+			io.WriteString(w, result.Output)
+		}
+
+		if report.Failed > 0 || report.Skipped > 0 {
+			return report, fmt.Errorf("%d of %d clients were not created", report.Failed+report.Skipped, len(rows))
+		}
+		return report, nil
+	}) {
+		return
+	}
+
+	if wantZip {
+		h.writeBulkZip(c, report)
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
+
+// writeBulkZip отдает архив с report.json и профилями созданных клиентов по путям <имя>/<kind>/<variant>/<файл>.
+func (h *ClientHandler) writeBulkZip(c *gin.Context, report *entity.BulkClientReport) {
+	reportJSON, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to build report", "details": err.Error()})
+		return
+	}
+
+	c.Header("Content-Type", "application/zip")
+	c.Header("Content-Disposition", `attachment; filename="clients.zip"`)
+	c.Header("Cache-Control", "no-store")
+	c.Status(http.StatusOK)
+
+	// Клиенты уже созданы, поэтому ошибки архива только логируются
+	archive := zip.NewWriter(c.Writer)
+	w, err := archive.Create("report.json")
+	if err == nil {
+		_, err = w.Write(reportJSON)
+	}
+	if err != nil {
+		log.Printf("Failed to add report to bulk archive: %v", err)
+		return
+	}
+
+	seen := make(map[string]bool)
+	for _, result := range report.Results {
+		if result.Status != entity.BulkRowCreated || seen[result.Name] {
+			continue
+		}
+		seen[result.Name] = true
+
+		profiles, err := h.service.GetProfiles(result.Name)
+		if err != nil {
+			log.Printf("Failed to find profiles for client %s: %v", result.Name, err)
+			continue
+		}
+		for _, profile := range profiles {
+			name := path.Join(result.Name, profile.Kind, profile.Variant, filepath.Base(profile.Path))
+			if err := addFileToZip(archive, name, profile.Path); err != nil {
+				log.Printf("Failed to add %s to bulk archive: %v", profile.Path, err)
+				return
+			}
+		}
+	}
+	if err := archive.Close(); err != nil {
+		log.Printf("Failed to finish bulk archive: %v", err)
+	}
+}
+
+// readBulkRows читает пакет из тела запроса: JSON (массив или {"clients": [...]}), CSV
+// или multipart-форма с файлом в поле file. Формат определяется по Content-Type или расширению файла.
+func readBulkRows(c *gin.Context) ([]entity.BulkClientRow, error) {
+	var (
+		body   io.Reader = c.Request.Body
+		format           = c.ContentType()
+	)
+	if format == "multipart/form-data" {
+		header, err := c.FormFile("file")
+		if err != nil {
+			return nil, fmt.Errorf("file field is required: %w", err)
+		}
+		file, err := header.Open()
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+		body = file
+		format = "text/csv"
+		if strings.EqualFold(filepath.Ext(header.Filename), ".json") {
+			format = "application/json"
+		}
+	}
+
+	switch format {
+	case "application/json":
+		return readBulkJSON(body)
+	case "text/csv", "application/csv":
+		return readBulkCSV(body)
+	default:
+		return nil, fmt.Errorf("unsupported content type %q, use application/json or text/csv", format)
+	}
+}
+
+func readBulkJSON(body io.Reader) ([]entity.BulkClientRow, error) {
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return nil, err
+	}
+
+	var items []CreateClientRequest
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '{' {
+		var wrapped struct {
+			Clients []CreateClientRequest `json:"clients"`
+		}
+		err = json.Unmarshal(data, &wrapped)
+		items = wrapped.Clients
+	} else {
+		err = json.Unmarshal(data, &items)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	rows := make([]entity.BulkClientRow, len(items))
+	for i, item := range items {
+		rows[i] = entity.BulkClientRow{Name: item.Name, Type: parseClientType(item.Type), ExpiresIn: item.ExpiresIn}
+	}
+	return rows, nil
+}
+
+// readBulkCSV читает строки name,type,expires_in. Первая строка считается заголовком,
+// если в ней есть колонка name; тогда колонки могут идти в любом порядке.
+func readBulkCSV(body io.Reader) ([]entity.BulkClientRow, error) {
+	reader := csv.NewReader(body)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	reader.Comment = '#'
+
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	columns := map[string]int{"name": 0, "type": 1, "expires_in": 2}
+	if len(records) > 0 {
+		header := make(map[string]int)
+		for i, column := range records[0] {
+			header[strings.ToLower(strings.TrimSpace(column))] = i
+		}
+		if _, ok := header["name"]; ok {
+			columns = header
+			records = records[1:]
+		}
+	}
+	field := func(record []string, column string) string {
+		i, ok := columns[column]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+
+	rows := make([]entity.BulkClientRow, 0, len(records))
+	for i, record := range records {
+		row := entity.BulkClientRow{Name: field(record, "name"), Type: parseClientType(field(record, "type"))}
+		if expiresIn := field(record, "expires_in"); expiresIn != "" {
+			days, err := strconv.Atoi(expiresIn)
+			if err != nil {
+				return nil, fmt.Errorf("row %d: invalid expires_in %q", i+1, expiresIn)
+			}
+			row.ExpiresIn = days
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
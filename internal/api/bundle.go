@@ -0,0 +1,85 @@
+package api
+
+import (
+	"antizapret-admin-panel/internal/entity"
+	"archive/zip"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DownloadBundle отдает zip-архив со всеми профилями клиента: вариантами OpenVPN,
+// WireGuard и AmneziaWG. Файлы лежат в архиве по путям <kind>/<variant>/<имя файла>.
+// Архив пишется прямо в ответ, без временных файлов.
+func (h *ClientHandler) DownloadBundle(c *gin.Context) {
+	targetClient, err := h.service.GetClientByID(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Client not found", "details": err.Error()})
+		return
+	}
+
+	profiles, err := h.service.GetProfiles(targetClient.Name)
+	if err != nil {
+		h.audit.Record(newAuditEntry(c, entity.AuditActionConfigDownload, targetClient, err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to find client profiles", "details": err.Error()})
+		return
+	}
+	if len(profiles) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No configuration files found for client."})
+		return
+	}
+
+	h.audit.Record(newAuditEntry(c, entity.AuditActionConfigDownload, targetClient, nil))
+	log.Printf("Serving bundle of %d profiles for client %s", len(profiles), targetClient.Name)
+
+	c.Header("Content-Type", "application/zip")
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", targetClient.Name+".zip"))
+	c.Header("Cache-Control", "no-store")
+	c.Status(http.StatusOK)
+
+	// После начала ответа статус уже не поменять, поэтому ошибки только логируются
+	archive := zip.NewWriter(c.Writer)
+	for _, profile := range profiles {
+		name := path.Join(profile.Kind, profile.Variant, filepath.Base(profile.Path))
+		if err := addFileToZip(archive, name, profile.Path); err != nil {
+			log.Printf("Failed to add %s to bundle for client %s: %v", profile.Path, targetClient.Name, err)
+			return
+		}
+	}
+	if err := archive.Close(); err != nil {
+		log.Printf("Failed to finish bundle for client %s: %v", targetClient.Name, err)
+	}
+}
+
+// addFileToZip копирует файл с диска в архив под именем name, сохраняя время изменения.
+func addFileToZip(archive *zip.Writer, name, filePath string) error {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	header, err := zip.FileInfoHeader(info)
+	if err != nil {
+		return err
+	}
+	header.Name = name
+	header.Method = zip.Deflate
+
+	w, err := archive.CreateHeader(header)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(w, file)
+	return err
+}
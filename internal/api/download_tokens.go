@@ -0,0 +1,66 @@
+package api
+
+import (
+	"antizapret-admin-panel/internal/entity"
+	"antizapret-admin-panel/internal/middleware"
+	"antizapret-admin-panel/internal/repository"
+	"antizapret-admin-panel/internal/service"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DownloadTokenHandler показывает и отзывает выданные ссылки на скачивание.
+type DownloadTokenHandler struct {
+	service service.DownloadTokenService
+	audit   service.AuditService
+}
+
+// NewDownloadTokenHandler — конструктор обработчика ссылок на скачивание.
+func NewDownloadTokenHandler(s service.DownloadTokenService, audit service.AuditService) *DownloadTokenHandler {
+	return &DownloadTokenHandler{service: s, audit: audit}
+}
+
+// GetTokens возвращает действующие ссылки на скачивание.
+func (h *DownloadTokenHandler) GetTokens(c *gin.Context) {
+	tokens, err := h.service.List(tokenOwnerFilter(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve download tokens", "details": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, tokens)
+}
+
+// RevokeToken отзывает ссылку по ID.
+func (h *DownloadTokenHandler) RevokeToken(c *gin.Context) {
+	token, err := h.service.Revoke(c.Param("id"), tokenOwnerFilter(c))
+	if errors.Is(err, repository.ErrTokenNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Download token not found"})
+		return
+	}
+
+	var client *entity.Client
+	if token != nil {
+		client = &entity.Client{ID: token.ClientID, Name: token.ClientName, Type: token.ClientType}
+	}
+	h.audit.Record(newAuditEntry(c, entity.AuditActionTokenRevoke, client, err))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke download token", "details": err.Error()})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// tokenOwnerFilter возвращает пользователя, чьими ссылками ограничен запрос. Все ссылки видят
+// и отзывают те, кто управляет клиентами; остальные (например, viewer) — только свои.
+func tokenOwnerFilter(c *gin.Context) string {
+	user := middleware.CurrentUser(c)
+	if user == nil {
+		return ""
+	}
+	if entity.HasPermission(user.Role, entity.PermissionClientsWrite) {
+		return ""
+	}
+	return user.Username
+}
@@ -1,16 +1,19 @@
 package api
 
 import (
+	"antizapret-admin-panel/internal/entity"
+	"antizapret-admin-panel/internal/middleware"
+	"antizapret-admin-panel/internal/repository"
 	"antizapret-admin-panel/internal/service"
-	"crypto/rand"
-	"encoding/hex"
-	"fmt"
+	"context"
+	"errors"
+	"io"
 	"log"
 	"net/http"
 	"os"
 	"path/filepath"
 	"strconv"
-	"sync"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -18,64 +21,23 @@ import (
 
 // --- Структуры данных запросов ---
 
-// LoginRequest представляет структуру данных для запроса на вход.
-type LoginRequest struct {
-	Username string `json:"username" binding:"required"`
-	Password string `json:"password" binding:"required"`
-}
-
 // CreateClientRequest представляет структуру данных для запроса на создание клиента.
+// Поля проверяет сервис (ClientService.ValidateClient), ошибки возвращаются по полям.
 type CreateClientRequest struct {
-	Name      string `json:"name" binding:"required"`
-	Type      string `json:"type" binding:"required"`
+	Name      string `json:"name"`
+	Type      string `json:"type"`
 	ExpiresIn int    `json:"expires_in,omitempty"`
 }
 
-// --- Управление временными токенами для скачивания ---
-
-// DownloadTokenInfo хранит информацию о временном токене.
-type DownloadTokenInfo struct {
-	FilePath  string
-	CreatedAt time.Time
+// RenewClientRequest представляет структуру данных для запроса на продление сертификата.
+type RenewClientRequest struct {
+	ExpiresIn int `json:"expires_in"`
 }
 
-const (
-	TOKEN_LIFETIME         = 5 * time.Minute
-	TOKEN_CLEANUP_INTERVAL = 1 * time.Minute
-)
-
-var (
-	downloadTokens = make(map[string]DownloadTokenInfo)
-	tokensMutex    = &sync.Mutex{}
-)
-
-func init() {
-	go cleanupExpiredTokens()
-}
-
-func cleanupExpiredTokens() {
-	ticker := time.NewTicker(TOKEN_CLEANUP_INTERVAL)
-	defer ticker.Stop()
-
-	for range ticker.C {
-		tokensMutex.Lock()
-		for token, info := range downloadTokens {
-			if time.Since(info.CreatedAt) > TOKEN_LIFETIME {
-				log.Printf("Удаление просроченного токена: %s", token)
-				delete(downloadTokens, token)
-			}
-		}
-		tokensMutex.Unlock()
-	}
-}
-
-// generateSecureToken создает криптографически случайную строку для использования в качестве токена.
-func generateSecureToken(length int) (string, error) {
-	bytes := make([]byte, length)
-	if _, err := rand.Read(bytes); err != nil {
-		return "", err
-	}
-	return hex.EncodeToString(bytes), nil
+// clientTypes сопоставляет тип клиента в запросах с типом сущности
+var clientTypes = map[string]string{
+	"openvpn":   entity.ClientTypeOpenVPN,
+	"wireguard": entity.ClientTypeWireGuard,
 }
 
 // --- ClientHandler (Отрефакторенные обработчики клиентов) ---
@@ -83,11 +45,17 @@ func generateSecureToken(length int) (string, error) {
 // ClientHandler — это наш новый обработчик, работающий со слоем сервиса.
 type ClientHandler struct {
 	service service.ClientService
+	jobs    service.JobQueue
+	tokens  service.DownloadTokenService
+	audit   service.AuditService
+	// Внешний адрес панели для ссылок в QR-кодах, например https://vpn.example.com:8080
+	publicBaseURL string
 }
 
 // NewClientHandler — конструктор для нашего обработчика.
-func NewClientHandler(s service.ClientService) *ClientHandler {
-	return &ClientHandler{service: s}
+// Если publicBaseURL пуст, QR-коды со ссылками недоступны.
+func NewClientHandler(s service.ClientService, jobs service.JobQueue, tokens service.DownloadTokenService, audit service.AuditService, publicBaseURL string) *ClientHandler {
+	return &ClientHandler{service: s, jobs: jobs, tokens: tokens, audit: audit, publicBaseURL: strings.TrimRight(publicBaseURL, "/")}
 }
 
 // GetClients обрабатывает запросы на получение списка всех клиентов.
@@ -116,6 +84,7 @@ func (h *ClientHandler) GetClients(c *gin.Context) {
 }
 
 // CreateClient обрабатывает запросы на создание нового клиента.
+// Операция выполняется в очереди client.sh; с async=true ответ 202 приходит сразу с ID задачи.
 func (h *ClientHandler) CreateClient(c *gin.Context) {
 	var req CreateClientRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -123,12 +92,36 @@ func (h *ClientHandler) CreateClient(c *gin.Context) {
 		return
 	}
 
-	if req.Type != "openvpn" { // TODO: Добавить поддержку разных типов клиентов
-		c.JSON(http.StatusNotImplemented, gin.H{"error": "Создание клиентов кроме OpenVPN пока не реализовано."})
+	// Проверка до постановки в очередь, чтобы и с async=true ошибка пришла сразу
+	clientType := parseClientType(req.Type)
+	if err := h.service.ValidateClient(req.Name, clientType, req.ExpiresIn); err != nil {
+		if !respondValidationError(c, err) {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to validate client", "details": err.Error()})
+		}
 		return
 	}
 
-	newClient, err := h.service.CreateClient(req.Name, req.ExpiresIn)
+	var (
+		newClient *entity.Client
+		err       error
+	)
+	auditCtx := c.Copy()
+	job := entity.Job{Action: entity.AuditActionClientCreate, ClientName: req.Name, ClientType: clientType}
+	if !runJob(c, h.jobs, job, func(ctx context.Context, w io.Writer) (any, error) {
+		var output string
+		newClient, output, err = h.service.CreateClient(ctx, req.Name, clientType, req.ExpiresIn)
+		entry := newAuditEntry(auditCtx, entity.AuditActionClientCreate, newClient, err)
+		entry.ClientName, entry.ClientType, entry.Output = req.Name, clientType, output
+		h.audit.Record(entry)
+		io.WriteString(w, output)
+		return newClient, err
+	}) {
+		return
+	}
+
+	if respondValidationError(c, err) {
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create client", "details": err.Error()})
 		return
@@ -138,15 +131,50 @@ func (h *ClientHandler) CreateClient(c *gin.Context) {
 }
 
 // DeleteClient обрабатывает запросы на удаление клиента по его ID.
+// С async=true отвечает 202 и ID задачи, результат — в GET /api/jobs/:id.
 func (h *ClientHandler) DeleteClient(c *gin.Context) {
-	idStr := c.Param("id")
-	id, err := strconv.Atoi(idStr)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid client ID"})
+	id := c.Param("id")
+	// Несуществующий клиент отклоняется сразу, а не в фоновой задаче
+	target, err := h.service.GetClientByID(id)
+	if errors.Is(err, repository.ErrClientNotFound) {
+		entry := newAuditEntry(c, entity.AuditActionClientDelete, nil, err)
+		entry.ClientID = id
+		h.audit.Record(entry)
+		c.JSON(http.StatusNotFound, gin.H{"error": "Client not found"})
 		return
 	}
 
-	err = h.service.DeleteClient(id)
+	auditCtx := c.Copy()
+	job := clientJob(entity.AuditActionClientDelete, target)
+	if !runJob(c, h.jobs, job, func(ctx context.Context, w io.Writer) (any, error) {
+		var (
+			client *entity.Client
+			output string
+		)
+		client, output, err = h.service.DeleteClient(ctx, id)
+		entry := newAuditEntry(auditCtx, entity.AuditActionClientDelete, client, err)
+		if client == nil {
+			entry.ClientID = id
+		}
+		entry.Output = output
+		h.audit.Record(entry)
+		io.WriteString(w, output)
+		if err == nil {
+			h.revokeClientTokens(auditCtx, client)
+		}
+		return nil, err
+	}) {
+		return
+	}
+
+	if errors.Is(err, repository.ErrClientNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Client not found"})
+		return
+	}
+	if errors.Is(err, service.ErrClientRevoked) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Client is already revoked"})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete client", "details": err.Error()})
 		return
@@ -155,135 +183,302 @@ func (h *ClientHandler) DeleteClient(c *gin.Context) {
 	c.Status(http.StatusNoContent)
 }
 
-// DownloadConfig handles direct download of a client config file.
-func (h *ClientHandler) DownloadConfig(c *gin.Context) {
-	clientName := c.Param("id") // TODO: Сейчас мы ищем по имени, а не по ID
-	configType := c.DefaultQuery("type", "vpn")
+// RenewClient перевыпускает сертификат OpenVPN-клиента с новым сроком действия.
+// С async=true отвечает 202 и ID задачи.
+func (h *ClientHandler) RenewClient(c *gin.Context) {
+	var req RenewClientRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
-	if configType != "vpn" && configType != "antizapret" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid config type. Must be 'vpn' or 'antizapret'."})
+	if err := service.ValidateExpiresIn(req.ExpiresIn); err != nil {
+		respondValidationError(c, err)
 		return
 	}
 
-	filePath, err := h.service.GetClientConfigPathByType(clientName, configType)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get client config path"})
+	id := c.Param("id")
+	target, err := h.service.GetClientByID(id)
+	if errors.Is(err, repository.ErrClientNotFound) {
+		entry := newAuditEntry(c, entity.AuditActionClientRenew, nil, err)
+		entry.ClientID = id
+		h.audit.Record(entry)
+		c.JSON(http.StatusNotFound, gin.H{"error": "Client not found"})
 		return
 	}
 
-	if _, err := os.Stat(filePath); os.IsNotExist(err) {
-		log.Printf("Config file not found for client %s at path %s", clientName, filePath)
-		c.JSON(http.StatusNotFound, gin.H{"error": "Configuration file not found."})
+	var client *entity.Client
+	auditCtx := c.Copy()
+	job := clientJob(entity.AuditActionClientRenew, target)
+	if !runJob(c, h.jobs, job, func(ctx context.Context, w io.Writer) (any, error) {
+		var output string
+		client, output, err = h.service.RenewClient(ctx, id, req.ExpiresIn)
+		entry := newAuditEntry(auditCtx, entity.AuditActionClientRenew, client, err)
+		if client == nil {
+			entry.ClientID = id
+		}
+		entry.Output = output
+		h.audit.Record(entry)
+		io.WriteString(w, output)
+		// Ссылки, выданные до перевыпуска, отдавали бы уже новый профиль
+		if err == nil {
+			h.revokeClientTokens(auditCtx, client)
+		}
+		return client, err
+	}) {
 		return
 	}
 
-	log.Printf("Serving config file %s for client %s", filePath, clientName)
-	c.FileAttachment(filePath, filepath.Base(filePath))
+	if respondValidationError(c, err) {
+		return
+	}
+	switch {
+	case errors.Is(err, repository.ErrClientNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Client not found"})
+	case errors.Is(err, service.ErrRenewNotSupported):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrClientRevoked), errors.Is(err, service.ErrCertificateExpired):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to renew client certificate", "details": err.Error()})
+	default:
+		c.JSON(http.StatusOK, gin.H{"notAfter": client.NotAfter, "client": client})
+	}
 }
 
-// GenerateQRToken создает временный токен для скачивания файла конфигурации.
-func (h *ClientHandler) GenerateQRToken(c *gin.Context) {
-	idStr := c.Param("id")
-	id, err := strconv.Atoi(idStr)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid client ID"})
-		return
+// parseClientType переводит тип клиента из запроса в тип сущности.
+// Неизвестный тип возвращается как есть, его отклонит проверка в сервисе.
+func parseClientType(raw string) string {
+	if clientType, ok := clientTypes[strings.ToLower(strings.TrimSpace(raw))]; ok {
+		return clientType
 	}
+	return raw
+}
 
-	configType := c.DefaultQuery("type", "vpn")
-	if configType != "vpn" && configType != "antizapret" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid config type. Must be 'vpn' or 'antizapret'."})
-		return
+// respondValidationError отвечает на ошибки проверки клиента: 400 с сообщениями по полям
+// для *service.ValidationError и 409 для занятого имени. Возвращает false для остальных ошибок.
+func respondValidationError(c *gin.Context, err error) bool {
+	var validationErr *service.ValidationError
+	switch {
+	case errors.As(err, &validationErr):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Validation failed", "fields": validationErr.Fields})
+	case errors.Is(err, service.ErrClientExists):
+		c.JSON(http.StatusConflict, gin.H{"error": "Client already exists", "fields": gin.H{"name": err.Error()}})
+	default:
+		return false
 	}
+	return true
+}
 
-	targetClient, err := h.service.GetClientByID(id)
+// clientJob описывает задачу над существующим клиентом. client может быть nil.
+func clientJob(action string, client *entity.Client) entity.Job {
+	job := entity.Job{Action: action}
+	if client != nil {
+		job.ClientID, job.ClientName, job.ClientType = client.ID, client.Name, client.Type
+	}
+	return job
+}
+
+// DownloadConfig handles direct download of a client config file.
+// Вариант профиля выбирается параметрами, как описано у resolveConfigPath.
+func (h *ClientHandler) DownloadConfig(c *gin.Context) {
+	targetClient, err := h.service.GetClientByID(c.Param("id"))
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Client not found", "details": err.Error()})
 		return
 	}
+	clientName := targetClient.Name
 
-	if targetClient.Type != "OpenVPN" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "QR code generation is only supported for OpenVPN clients."})
+	filePath, ok := h.resolveConfigPath(c, targetClient)
+	if !ok {
 		return
 	}
 
-	filePath, err := h.service.GetClientConfigPathByType(targetClient.Name, configType)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get client config path", "details": err.Error()})
+	if _, err := os.Stat(filePath); os.IsNotExist(err) {
+		log.Printf("Config file not found for client %s at path %s", clientName, filePath)
+		h.audit.Record(newAuditEntry(c, entity.AuditActionConfigDownload, targetClient, err))
+		c.JSON(http.StatusNotFound, gin.H{"error": "Configuration file not found."})
 		return
 	}
 
-	token, err := generateSecureToken(20)
+	log.Printf("Serving config file %s for client %s", filePath, clientName)
+	h.audit.Record(newAuditEntry(c, entity.AuditActionConfigDownload, targetClient, nil))
+	c.FileAttachment(filePath, filepath.Base(filePath))
+}
+
+// GenerateQRToken создает временную ссылку для скачивания файла конфигурации.
+// Срок жизни задается параметром ttl (например, 24h), число скачиваний — max_uses.
+// token из ответа передается в qr.png и qr.svg, чтобы нарисовать QR-код этой ссылки.
+func (h *ClientHandler) GenerateQRToken(c *gin.Context) {
+	targetClient, err := h.service.GetClientByID(c.Param("id"))
 	if err != nil {
-		log.Printf("Failed to generate secure token: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not generate download token."})
+		c.JSON(http.StatusNotFound, gin.H{"error": "Client not found", "details": err.Error()})
 		return
 	}
 
-	tokensMutex.Lock()
-	downloadTokens[token] = DownloadTokenInfo{
-		FilePath:  filePath,
-		CreatedAt: time.Now(),
+	filePath, ok := h.resolveConfigPath(c, targetClient)
+	if !ok {
+		return
 	}
-	tokensMutex.Unlock()
 
-	log.Printf("Сгенерирован токен %s для файла %s", token, filePath)
-	downloadURL := fmt.Sprintf("/api/download/%s", token)
+	token, downloadToken, ok := h.issueDownloadToken(c, targetClient, filePath)
+	if !ok {
+		return
+	}
 
-	c.JSON(http.StatusOK, gin.H{"download_url": downloadURL})
+	c.JSON(http.StatusOK, gin.H{
+		"download_url": downloadTokenPath(token),
+		"token":        token,
+		"id":           downloadToken.ID,
+		"expiresAt":    downloadToken.ExpiresAt,
+		"maxUses":      downloadToken.MaxUses,
+	})
 }
 
-// --- Прочие обработчики API (не зависят от ClientService) ---
+// resolveConfigPath находит файл профиля клиента по параметрам запроса. При ошибке ответ уже отправлен.
+// Для OpenVPN параметр variant выбирает один из entity.OpenVPNVariants, без него используется
+// прежний type (vpn|antizapret). Для WireGuard — type (vpn|antizapret) и flavor (wireguard|amneziawg).
+func (h *ClientHandler) resolveConfigPath(c *gin.Context, client *entity.Client) (string, bool) {
+	var (
+		filePath string
+		err      error
+	)
+
+	variant := c.Query("variant")
+	switch {
+	case client.Type == entity.ClientTypeOpenVPN && variant != "":
+		if !entity.IsOpenVPNVariant(variant) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid variant. Must be one of: " + strings.Join(entity.OpenVPNVariants, ", ") + "."})
+			return "", false
+		}
+		filePath, err = h.service.GetOpenVPNConfigPath(client.Name, variant)
+	default:
+		configType := c.DefaultQuery("type", "vpn")
+		if configType != "vpn" && configType != "antizapret" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid config type. Must be 'vpn' or 'antizapret'."})
+			return "", false
+		}
 
-// LoginHandler обрабатывает запросы на вход.
-func LoginHandler(c *gin.Context) {
-	var req LoginRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
+		if client.Type == entity.ClientTypeWireGuard {
+			flavor := c.DefaultQuery("flavor", entity.WireGuardFlavorWireGuard)
+			if flavor != entity.WireGuardFlavorWireGuard && flavor != entity.WireGuardFlavorAmneziaWG {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid flavor. Must be 'wireguard' or 'amneziawg'."})
+				return "", false
+			}
+			filePath, err = h.service.GetWireGuardConfigPath(client.Name, configType, flavor)
+		} else {
+			filePath, err = h.service.GetClientConfigPathByType(client.Name, configType)
+		}
 	}
-	adminUsername := os.Getenv("ADMIN_USERNAME")
-	if adminUsername == "" {
-		adminUsername = "admin"
+
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Configuration file not found.", "details": err.Error()})
+		return "", false
+	}
+	return filePath, true
+}
+
+// issueDownloadToken выпускает ссылку на скачивание файла с параметрами ttl и max_uses из запроса
+// и возвращает токен. При ошибке ответ уже отправлен.
+func (h *ClientHandler) issueDownloadToken(c *gin.Context, client *entity.Client, filePath string) (string, *entity.DownloadToken, bool) {
+	var ttl time.Duration
+	if value := c.Query("ttl"); value != "" {
+		parsed, err := time.ParseDuration(value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ttl. Use a duration like 30m or 24h."})
+			return "", nil, false
+		}
+		ttl = parsed
+	}
+	var maxUses int
+	if value := c.Query("max_uses"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid max_uses. Must be a number."})
+			return "", nil, false
+		}
+		maxUses = parsed
 	}
-	adminPassword := os.Getenv("ADMIN_PASSWORD")
-	if adminPassword == "" {
-		adminPassword = "password"
+
+	var createdBy string
+	if user := middleware.CurrentUser(c); user != nil {
+		createdBy = user.Username
 	}
-	if req.Username == adminUsername && req.Password == adminPassword {
-		c.JSON(http.StatusOK, gin.H{"token": adminPassword})
-	} else {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
+
+	token, downloadToken, err := h.tokens.Issue(client, filePath, createdBy, ttl, maxUses)
+	if errors.Is(err, service.ErrInvalidTokenTTL) || errors.Is(err, service.ErrInvalidTokenUses) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return "", nil, false
+	}
+	if err != nil {
+		log.Printf("Failed to generate download token: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not generate download token."})
+		return "", nil, false
 	}
+	h.audit.Record(newAuditEntry(c, entity.AuditActionTokenIssue, client, nil))
+
+	log.Printf("Выпущена ссылка %s на файл %s до %s", downloadToken.ID, filePath, downloadToken.ExpiresAt.Format(time.RFC3339))
+	return token, downloadToken, true
 }
 
-// CheckAuthHandler обрабатывает запросы на проверку аутентификации.
-func CheckAuthHandler(c *gin.Context) {
-	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+// downloadTokenPath — путь публичного скачивания по токену
+func downloadTokenPath(token string) string {
+	return "/api/download/" + token
 }
 
-// DownloadFileHandler обрабатывает запрос на скачивание файла по токену.
-func DownloadFileHandler(c *gin.Context) {
-	token := c.Param("token")
-	tokensMutex.Lock()
-	tokenInfo, ok := downloadTokens[token]
-	if ok {
-		delete(downloadTokens, token)
+// revokeClientTokens отзывает ссылки на скачивание удаленного или перевыпущенного клиента.
+// Иначе ссылка отдала бы файл, который окажется по тому же пути у нового клиента с тем же именем.
+func (h *ClientHandler) revokeClientTokens(c *gin.Context, client *entity.Client) {
+	tokens, err := h.tokens.RevokeByClient(client.ID)
+	if err != nil {
+		log.Printf("Failed to revoke download tokens of client %s: %v", client.Name, err)
+		h.audit.Record(newAuditEntry(c, entity.AuditActionTokenRevoke, client, err))
+		return
+	}
+	for _, token := range tokens {
+		entry := newAuditEntry(c, entity.AuditActionTokenRevoke, client, nil)
+		entry.Target = token.ID
+		h.audit.Record(entry)
 	}
-	tokensMutex.Unlock()
+}
 
-	if !ok {
-		log.Printf("Попытка скачивания по неверному токену: %s", token)
+// DownloadByToken обрабатывает публичный запрос на скачивание файла по ссылке.
+// В журнал аудита скачивание записывается от имени пользователя, выпустившего ссылку.
+func (h *ClientHandler) DownloadByToken(c *gin.Context) {
+	token, err := h.tokens.Redeem(c.Param("token"))
+	if errors.Is(err, service.ErrInvalidDownloadToken) {
+		log.Printf("Попытка скачивания по неверной или просроченной ссылке с %s", c.ClientIP())
 		c.JSON(http.StatusNotFound, gin.H{"error": "Invalid or expired token."})
 		return
 	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check download token", "details": err.Error()})
+		return
+	}
 
-	if time.Since(tokenInfo.CreatedAt) > TOKEN_LIFETIME {
-		log.Printf("Попытка скачивания по просроченному токену: %s", token)
+	client := &entity.Client{ID: token.ClientID, Name: token.ClientName, Type: token.ClientType}
+	entry := newAuditEntry(c, entity.AuditActionConfigDownload, client, nil)
+	entry.Actor = token.CreatedBy
+	// Ссылка действует, только пока существует клиент, для которого она выпущена:
+	// у удаленного клиента ID из хранилища метаданных пропадает
+	current, err := h.service.GetClientByID(token.ClientID)
+	if err == nil && current.Status == entity.ClientStatusRevoked {
+		err = service.ErrClientRevoked
+	}
+	if err != nil {
+		entry.Outcome, entry.Error = entity.AuditOutcomeFailure, err.Error()
+		h.audit.Record(entry)
 		c.JSON(http.StatusNotFound, gin.H{"error": "Invalid or expired token."})
 		return
 	}
+	if _, err := os.Stat(token.FilePath); err != nil {
+		entry.Outcome, entry.Error = entity.AuditOutcomeFailure, err.Error()
+		h.audit.Record(entry)
+		c.JSON(http.StatusNotFound, gin.H{"error": "Configuration file not found."})
+		return
+	}
+	h.audit.Record(entry)
 
-	log.Printf("Отправка файла %s по токену %s", tokenInfo.FilePath, token)
-	c.FileAttachment(tokenInfo.FilePath, filepath.Base(tokenInfo.FilePath))
+	log.Printf("Отправка файла %s по ссылке %s (%d из %d)", token.FilePath, token.ID, token.Uses, token.MaxUses)
+	c.FileAttachment(token.FilePath, token.FileName)
 }
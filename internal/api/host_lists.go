@@ -0,0 +1,150 @@
+package api
+
+import (
+	"antizapret-admin-panel/internal/entity"
+	"antizapret-admin-panel/internal/repository"
+	"antizapret-admin-panel/internal/service"
+	"bufio"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxHostListBodySize ограничивает размер запроса на изменение списка
+const maxHostListBodySize = 4 << 20
+
+// HostListRequest — записи для добавления, удаления или замены списка.
+type HostListRequest struct {
+	Entries []string `json:"entries"`
+}
+
+// HostListHandler управляет списками маршрутизации AntiZapret.
+type HostListHandler struct {
+	service service.HostListService
+	audit   service.AuditService
+}
+
+// NewHostListHandler — конструктор обработчика списков.
+func NewHostListHandler(s service.HostListService, audit service.AuditService) *HostListHandler {
+	return &HostListHandler{service: s, audit: audit}
+}
+
+// GetLists возвращает все списки с количеством записей.
+func (h *HostListHandler) GetLists(c *gin.Context) {
+	lists, err := h.service.ListAll()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read lists", "details": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, lists)
+}
+
+// GetList возвращает записи списка.
+func (h *HostListHandler) GetList(c *gin.Context) {
+	list, err := h.service.Get(c.Param("name"))
+	if err != nil {
+		respondHostListError(c, err, "Failed to read list")
+		return
+	}
+	c.JSON(http.StatusOK, list)
+}
+
+// AddEntries добавляет записи в список. Уже имеющиеся записи пропускаются.
+func (h *HostListHandler) AddEntries(c *gin.Context) {
+	h.change(c, entity.AuditActionListAdd, h.service.Add)
+}
+
+// RemoveEntries удаляет записи из списка.
+func (h *HostListHandler) RemoveEntries(c *gin.Context) {
+	h.change(c, entity.AuditActionListRemove, h.service.Remove)
+}
+
+// ReplaceList заменяет все записи списка. Пустой список entries очищает его.
+func (h *HostListHandler) ReplaceList(c *gin.Context) {
+	h.change(c, entity.AuditActionListReplace, h.service.Replace)
+}
+
+// change читает записи из запроса, применяет операцию и пишет изменения в журнал аудита.
+func (h *HostListHandler) change(c *gin.Context, action string, apply func(name string, entries []string) (*entity.HostListChange, error)) {
+	entries, err := readHostListEntries(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	name := c.Param("name")
+	result, err := apply(name, entries)
+	if errors.Is(err, repository.ErrHostListNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "List not found"})
+		return
+	}
+
+	entry := newAuditEntry(c, action, nil, err)
+	entry.Target = name
+	if result != nil {
+		entry.Output = describeHostListChange(result)
+	}
+	h.audit.Record(entry)
+
+	if err != nil {
+		respondHostListError(c, err, "Failed to update list")
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// readHostListEntries принимает JSON {"entries": [...]} или text/plain с записью на строку.
+// В тексте пустые строки и комментарии (#) пропускаются.
+func readHostListEntries(c *gin.Context) ([]string, error) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxHostListBodySize)
+
+	if c.ContentType() == "text/plain" {
+		entries := []string{}
+		scanner := bufio.NewScanner(c.Request.Body)
+		for scanner.Scan() {
+			line, _, _ := strings.Cut(scanner.Text(), "#")
+			if line = strings.TrimSpace(line); line != "" {
+				entries = append(entries, line)
+			}
+		}
+		return entries, scanner.Err()
+	}
+
+	var req HostListRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		return nil, err
+	}
+	if req.Entries == nil {
+		return nil, errors.New("entries is required")
+	}
+	return req.Entries, nil
+}
+
+// describeHostListChange перечисляет добавленные и удаленные записи для журнала аудита.
+func describeHostListChange(change *entity.HostListChange) string {
+	var b strings.Builder
+	for _, entry := range change.Added {
+		b.WriteString("+ " + entry + "\n")
+	}
+	for _, entry := range change.Removed {
+		b.WriteString("- " + entry + "\n")
+	}
+	return b.String()
+}
+
+func respondHostListError(c *gin.Context, err error, message string) {
+	if errors.Is(err, repository.ErrHostListNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "List not found"})
+		return
+	}
+	if errors.Is(err, service.ErrConfigBusy) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if respondValidationError(c, err) {
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": message, "details": err.Error()})
+}
@@ -0,0 +1,132 @@
+package api
+
+import (
+	"antizapret-admin-panel/internal/entity"
+	"antizapret-admin-panel/internal/middleware"
+	"antizapret-admin-panel/internal/service"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-contrib/sse"
+	"github.com/gin-gonic/gin"
+)
+
+// JobHandler отдает состояние задач из всех очередей: client.sh и doall.sh.
+type JobHandler struct {
+	queues []service.JobQueue
+	// Завершается при остановке панели, чтобы потоки вывода не задерживали ее
+	shutdown context.Context
+}
+
+// NewJobHandler — конструктор обработчика задач.
+func NewJobHandler(shutdown context.Context, queues ...service.JobQueue) *JobHandler {
+	return &JobHandler{queues: queues, shutdown: shutdown}
+}
+
+// find ищет задачу по ID во всех очередях.
+func (h *JobHandler) find(id string) (service.JobQueue, *entity.Job, error) {
+	for _, queue := range h.queues {
+		job, err := queue.Get(id)
+		if errors.Is(err, service.ErrJobNotFound) {
+			continue
+		}
+		return queue, job, err
+	}
+	return nil, nil, service.ErrJobNotFound
+}
+
+// GetJob возвращает статус задачи, вывод скрипта и результат.
+func (h *JobHandler) GetJob(c *gin.Context) {
+	_, job, err := h.find(c.Param("id"))
+	if errors.Is(err, service.ErrJobNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Job not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get job", "details": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, job)
+}
+
+// StreamJob отдает вывод задачи через Server-Sent Events по мере его появления.
+// События output содержат новый вывод, их id — смещение в выводе: при переподключении с Last-Event-ID
+// уже полученный вывод не повторяется. Последнее событие status содержит завершенную задачу.
+func (h *JobHandler) StreamJob(c *gin.Context) {
+	id := c.Param("id")
+	queue, _, err := h.find(id)
+	if errors.Is(err, service.ErrJobNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Job not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get job", "details": err.Error()})
+		return
+	}
+
+	offset, _ := strconv.Atoi(c.GetHeader("Last-Event-ID"))
+	ctx, cancel := context.WithCancel(c.Request.Context())
+	defer cancel()
+	stop := context.AfterFunc(h.shutdown, cancel)
+	defer stop()
+
+	// Обратный прокси (nginx) не должен копить поток в буфере
+	c.Header("X-Accel-Buffering", "no")
+	c.Header("Cache-Control", "no-cache")
+	c.Stream(func(w io.Writer) bool {
+		chunk, job, err := queue.Follow(ctx, id, offset)
+		if err != nil {
+			return false
+		}
+		if chunk != "" {
+			offset += len(chunk)
+			c.Render(-1, sse.Event{Id: strconv.Itoa(offset), Event: "output", Data: chunk})
+		}
+		if job.Finished() {
+			c.SSEvent("status", job)
+			return false
+		}
+		return true
+	})
+}
+
+// runJob ставит операцию с client.sh в очередь. С параметром async=true сразу отвечает 202
+// с ID задачи и возвращает false. Иначе дожидается завершения и возвращает true: ответ по результатам,
+// которые сохранил fn, формирует вызывающий. При ошибке ответ уже отправлен.
+// fn выполняется после возврата из обработчика, поэтому gin.Context внутри него можно использовать только через c.Copy().
+func runJob(c *gin.Context, jobs service.JobQueue, job entity.Job, fn service.JobFunc) bool {
+	async, err := strconv.ParseBool(c.DefaultQuery("async", "false"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid async. Must be true or false."})
+		return false
+	}
+
+	if user := middleware.CurrentUser(c); user != nil {
+		job.CreatedBy = user.Username
+	}
+	queued, err := jobs.Submit(job, fn)
+	if errors.Is(err, service.ErrJobQueueFull) {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Too many pending operations, try again later"})
+		return false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to queue operation", "details": err.Error()})
+		return false
+	}
+
+	c.Header("X-Job-ID", queued.ID)
+	if async {
+		c.Header("Location", "/api/jobs/"+queued.ID)
+		c.JSON(http.StatusAccepted, jobLinks(queued))
+		return false
+	}
+
+	// Если клиент не дождался ответа, задача все равно выполнится
+	if _, err := jobs.Wait(c.Request.Context(), queued.ID); err != nil {
+		return false
+	}
+	return true
+}
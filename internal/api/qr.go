@@ -0,0 +1,159 @@
+package api
+
+import (
+	"antizapret-admin-panel/internal/entity"
+	"antizapret-admin-panel/internal/service"
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/skip2/go-qrcode"
+)
+
+// Режимы содержимого QR-кода
+const (
+	qrModeLink   = "link"   // абсолютная ссылка на скачивание конфига
+	qrModeInline = "inline" // текст конфига WireGuard/AmneziaWG, который мобильные приложения импортируют напрямую
+)
+
+// Размер PNG по умолчанию и допустимые границы, в пикселях
+const (
+	qrDefaultSize = 256
+	qrMinSize     = 128
+	qrMaxSize     = 1024
+)
+
+// QRCodePNG отдает QR-код конфига клиента в формате PNG.
+// Параметры: mode (link|inline), size, параметры выбора профиля как у DownloadConfig,
+// а для ссылок — token, выпущенный POST /qr-token. Сами картинки ссылок не выпускают:
+// иначе каждое обновление страницы оставляло бы еще одну действующую ссылку.
+func (h *ClientHandler) QRCodePNG(c *gin.Context) {
+	size, err := strconv.Atoi(c.DefaultQuery("size", strconv.Itoa(qrDefaultSize)))
+	if err != nil || size < qrMinSize || size > qrMaxSize {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid size. Must be between %d and %d.", qrMinSize, qrMaxSize)})
+		return
+	}
+
+	qr, ok := h.buildQRCode(c)
+	if !ok {
+		return
+	}
+
+	png, err := qr.PNG(size)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to render QR code", "details": err.Error()})
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.Data(http.StatusOK, "image/png", png)
+}
+
+// QRCodeSVG отдает QR-код конфига клиента в формате SVG. Параметры те же, что у QRCodePNG, кроме size.
+func (h *ClientHandler) QRCodeSVG(c *gin.Context) {
+	qr, ok := h.buildQRCode(c)
+	if !ok {
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.Data(http.StatusOK, "image/svg+xml", renderQRCodeSVG(qr.Bitmap()))
+}
+
+// buildQRCode собирает содержимое QR-кода по параметрам запроса. При ошибке ответ уже отправлен.
+func (h *ClientHandler) buildQRCode(c *gin.Context) (*qrcode.QRCode, bool) {
+	targetClient, err := h.service.GetClientByID(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Client not found", "details": err.Error()})
+		return nil, false
+	}
+
+	// Конфиг OpenVPN с сертификатами не помещается в QR-код, поэтому для него только ссылка
+	mode := qrModeLink
+	if targetClient.Type == entity.ClientTypeWireGuard {
+		mode = qrModeInline
+	}
+	mode = c.DefaultQuery("mode", mode)
+	if mode != qrModeLink && mode != qrModeInline {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid mode. Must be 'link' or 'inline'."})
+		return nil, false
+	}
+	if mode == qrModeInline && targetClient.Type != entity.ClientTypeWireGuard {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Inline QR codes are only supported for WireGuard clients."})
+		return nil, false
+	}
+
+	var content string
+	if mode == qrModeInline {
+		filePath, ok := h.resolveConfigPath(c, targetClient)
+		if !ok {
+			return nil, false
+		}
+		data, err := os.ReadFile(filePath)
+		if err != nil {
+			h.audit.Record(newAuditEntry(c, entity.AuditActionConfigDownload, targetClient, err))
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read configuration file", "details": err.Error()})
+			return nil, false
+		}
+		// Конфиг в QR-коде уходит с сервера так же, как при скачивании
+		h.audit.Record(newAuditEntry(c, entity.AuditActionConfigDownload, targetClient, nil))
+		content = string(data)
+	} else {
+		// Адрес из заголовков Host и X-Forwarded-Proto задает клиент: в QR-код для конечного
+		// пользователя попал бы чужой домен
+		if h.publicBaseURL == "" {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Link QR codes require PUBLIC_BASE_URL to be configured."})
+			return nil, false
+		}
+		token := c.Query("token")
+		if token == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "token is required for link QR codes. Issue one with POST /api/clients/<id>/qr-token."})
+			return nil, false
+		}
+		downloadToken, err := h.tokens.Lookup(token)
+		if errors.Is(err, service.ErrInvalidDownloadToken) || (err == nil && downloadToken.ClientID != targetClient.ID) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Invalid or expired token."})
+			return nil, false
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check download token", "details": err.Error()})
+			return nil, false
+		}
+		content = h.publicBaseURL + downloadTokenPath(token)
+	}
+
+	qr, err := qrcode.New(content, qrcode.Medium)
+	if err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Content does not fit into a QR code", "details": err.Error()})
+		return nil, false
+	}
+	return qr, true
+}
+
+// renderQRCodeSVG рисует матрицу QR-кода (вместе с отступом) одним SVG-путем, по модулю на единицу.
+func renderQRCodeSVG(bitmap [][]bool) []byte {
+	size := len(bitmap)
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 %d %d" shape-rendering="crispEdges">`, size, size)
+	fmt.Fprintf(&buf, `<rect width="%d" height="%d" fill="#fff"/><path fill="#000" d="`, size, size)
+	for y, row := range bitmap {
+		for x := 0; x < len(row); x++ {
+			if !row[x] {
+				continue
+			}
+			// Соседние темные модули строки объединяются в один прямоугольник
+			start := x
+			for x+1 < len(row) && row[x+1] {
+				x++
+			}
+			fmt.Fprintf(&buf, "M%d %dh%dv1h-%dz", start, y, x-start+1, x-start+1)
+		}
+	}
+	buf.WriteString(`"/></svg>`)
+	return buf.Bytes()
+}
@@ -0,0 +1,120 @@
+package api
+
+import (
+	"antizapret-admin-panel/internal/middleware"
+	"antizapret-admin-panel/internal/repository"
+	"antizapret-admin-panel/internal/service"
+	"encoding/base64"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TOTPCodeRequest представляет структуру данных с кодом из приложения-аутентификатора.
+type TOTPCodeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+// DisableTOTPRequest представляет структуру данных для отключения TOTP. Нужен текущий пароль.
+type DisableTOTPRequest struct {
+	Password string `json:"password" binding:"required"`
+}
+
+// TOTPHandler обрабатывает подключение и отключение второго фактора.
+type TOTPHandler struct {
+	service service.TOTPService
+}
+
+// NewTOTPHandler — конструктор обработчика TOTP.
+func NewTOTPHandler(s service.TOTPService) *TOTPHandler {
+	return &TOTPHandler{service: s}
+}
+
+// Enroll выдает текущему пользователю новый секрет, otpauth:// URI и QR-код для приложения.
+func (h *TOTPHandler) Enroll(c *gin.Context) {
+	enrollment, err := h.service.BeginEnrollment(middleware.CurrentUser(c).Username)
+	if respondTOTPError(c, err) {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"secret": enrollment.Secret,
+		"uri":    enrollment.URI,
+		"qrCode": "data:image/png;base64," + base64.StdEncoding.EncodeToString(enrollment.QRCode),
+	})
+}
+
+// Confirm включает TOTP по коду из приложения и возвращает коды восстановления.
+func (h *TOTPHandler) Confirm(c *gin.Context) {
+	var req TOTPCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	codes, err := h.service.ConfirmEnrollment(middleware.CurrentUser(c).Username, req.Code)
+	if respondTOTPError(c, err) {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"recoveryCodes": codes})
+}
+
+// RegenerateRecoveryCodes заменяет коды восстановления текущего пользователя.
+func (h *TOTPHandler) RegenerateRecoveryCodes(c *gin.Context) {
+	var req TOTPCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	codes, err := h.service.RegenerateRecoveryCodes(middleware.CurrentUser(c).Username, req.Code)
+	if respondTOTPError(c, err) {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"recoveryCodes": codes})
+}
+
+// Disable отключает TOTP текущего пользователя после проверки пароля.
+func (h *TOTPHandler) Disable(c *gin.Context) {
+	var req DisableTOTPRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	err := h.service.Disable(middleware.CurrentUser(c).Username, req.Password)
+	if errors.Is(err, service.ErrInvalidCredentials) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Invalid password"})
+		return
+	}
+	if respondTOTPError(c, err) {
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// Reset отключает TOTP другого пользователя, например если он потерял телефон и коды восстановления.
+func (h *TOTPHandler) Reset(c *gin.Context) {
+	if respondTOTPError(c, h.service.Reset(c.Param("username"))) {
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// respondTOTPError отвечает на ошибку сервиса TOTP и возвращает true, если ответ уже отправлен.
+func respondTOTPError(c *gin.Context, err error) bool {
+	switch {
+	case err == nil:
+		return false
+	case errors.Is(err, repository.ErrUserNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+	case errors.Is(err, service.ErrInvalidTOTPCode):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid two-factor code"})
+	case errors.Is(err, service.ErrTOTPAlreadyEnabled), errors.Is(err, service.ErrTOTPNotEnabled), errors.Is(err, service.ErrTOTPNotPending):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Two-factor operation failed", "details": err.Error()})
+	}
+	return true
+}
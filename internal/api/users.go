@@ -0,0 +1,109 @@
+package api
+
+import (
+	"antizapret-admin-panel/internal/middleware"
+	"antizapret-admin-panel/internal/repository"
+	"antizapret-admin-panel/internal/service"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CreateUserRequest представляет структуру данных для запроса на создание пользователя.
+type CreateUserRequest struct {
+	Username string `json:"username" binding:"required"`
+	Password string `json:"password" binding:"required"`
+	Role     string `json:"role" binding:"required"`
+}
+
+// UpdateUserRequest представляет структуру данных для запроса на изменение пользователя.
+// Можно передать пароль, роль или оба поля.
+type UpdateUserRequest struct {
+	Password string `json:"password,omitempty"`
+	Role     string `json:"role,omitempty"`
+}
+
+// UserHandler обрабатывает управление учетными записями панели.
+type UserHandler struct {
+	service service.UserService
+}
+
+// NewUserHandler — конструктор обработчика пользователей.
+func NewUserHandler(s service.UserService) *UserHandler {
+	return &UserHandler{service: s}
+}
+
+// GetUsers возвращает список пользователей.
+func (h *UserHandler) GetUsers(c *gin.Context) {
+	users, err := h.service.ListUsers()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve users", "details": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, users)
+}
+
+// CreateUser создает нового пользователя.
+func (h *UserHandler) CreateUser(c *gin.Context) {
+	var req CreateUserRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, err := h.service.CreateUser(req.Username, req.Password, req.Role)
+	if err != nil {
+		respondUserError(c, err, "Failed to create user")
+		return
+	}
+	c.JSON(http.StatusCreated, user)
+}
+
+// UpdateUser меняет пароль и/или роль пользователя.
+func (h *UserHandler) UpdateUser(c *gin.Context) {
+	var req UpdateUserRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Password == "" && req.Role == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Nothing to update: pass password and/or role"})
+		return
+	}
+
+	if err := h.service.UpdateUser(c.Param("username"), req.Password, req.Role); err != nil {
+		respondUserError(c, err, "Failed to update user")
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// DeleteUser удаляет пользователя. Удалить самого себя нельзя.
+func (h *UserHandler) DeleteUser(c *gin.Context) {
+	username := c.Param("username")
+	if current := middleware.CurrentUser(c); current != nil && current.Username == username {
+		c.JSON(http.StatusConflict, gin.H{"error": "Cannot delete the current user"})
+		return
+	}
+
+	if err := h.service.DeleteUser(username); err != nil {
+		respondUserError(c, err, "Failed to delete user")
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// respondUserError переводит ошибки сервиса пользователей в HTTP-ответы.
+func respondUserError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, repository.ErrUserNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+	case errors.Is(err, repository.ErrUserExists), errors.Is(err, service.ErrLastAdmin):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrInvalidUsername), errors.Is(err, service.ErrWeakPassword), errors.Is(err, service.ErrInvalidRole):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message, "details": err.Error()})
+	}
+}
@@ -0,0 +1,68 @@
+package api
+
+import (
+	"antizapret-admin-panel/internal/entity"
+	"antizapret-admin-panel/internal/repository"
+	"antizapret-admin-panel/internal/service"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// VPNSessionHandler показывает текущие подключения OpenVPN и разрывает их.
+type VPNSessionHandler struct {
+	service service.VPNSessionService
+	audit   service.AuditService
+}
+
+// NewVPNSessionHandler — конструктор обработчика подключений.
+func NewVPNSessionHandler(s service.VPNSessionService, audit service.AuditService) *VPNSessionHandler {
+	return &VPNSessionHandler{service: s, audit: audit}
+}
+
+// GetSessions возвращает подключения всех инстансов OpenVPN.
+func (h *VPNSessionHandler) GetSessions(c *gin.Context) {
+	sessions, err := h.service.ListSessions(c.Request.Context())
+	if errors.Is(err, repository.ErrManagementUnavailable) {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get sessions", "details": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, sessions)
+}
+
+// DisconnectClient разрывает все подключения клиента OpenVPN. Сертификат не отзывается,
+// поэтому клиент может подключиться снова.
+func (h *VPNSessionHandler) DisconnectClient(c *gin.Context) {
+	id := c.Param("id")
+	client, killed, err := h.service.Disconnect(c.Request.Context(), id)
+
+	entry := newAuditEntry(c, entity.AuditActionClientDisconnect, client, err)
+	if client == nil {
+		entry.ClientID = id
+	}
+	if killed > 0 {
+		entry.Output = fmt.Sprintf("%d session(s) disconnected", killed)
+	}
+	h.audit.Record(entry)
+
+	switch {
+	case errors.Is(err, repository.ErrClientNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Client not found"})
+	case errors.Is(err, service.ErrDisconnectNotSupported):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrClientNotConnected):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, repository.ErrManagementUnavailable):
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to disconnect client", "details": err.Error()})
+	default:
+		c.JSON(http.StatusOK, gin.H{"disconnected": killed, "client": client})
+	}
+}
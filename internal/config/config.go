@@ -0,0 +1,500 @@
+package config
+
+import (
+	"bytes"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultConfigFile — файл конфигурации, который читается, если CONFIG_FILE не задан.
+// Его отсутствие не ошибка: тогда используются значения по умолчанию и переменные окружения.
+const DefaultConfigFile = "/etc/antizapret-admin/config.yaml"
+
+// Значения по умолчанию соответствуют стандартной установке AntiZapret-VPN
+const (
+	defaultListenAddr            = ":8080"
+	defaultDataPath              = "/usr/local/share/antizapret-admin/"
+	defaultAntiZapretRoot        = "/root/antizapret/"
+	defaultOpenVPNStatusPath     = "/etc/openvpn/server/logs/"
+	defaultOpenVPNManagementPath = "/run/openvpn-server/"
+	defaultOpenVPNPKIPath        = "/etc/openvpn/easyrsa3/pki/"
+	defaultWireGuardPath         = "/etc/wireguard/"
+	defaultKnotResolverPath      = "/etc/knot-resolver/"
+	defaultSessionTTL            = 12 * time.Hour
+	defaultShutdownTimeout       = 60 * time.Second
+	defaultDownloadTokenStore    = "file"
+	defaultBackupKeep            = 10
+	defaultACMEDirectoryURL      = "https://acme-v02.api.letsencrypt.org/directory"
+)
+
+// Режимы TLS
+const (
+	TLSModeOff  = "off"  // обычный HTTP
+	TLSModeFile = "file" // сертификат и ключ из файлов
+	TLSModeACME = "acme" // автоматический сертификат через ACME (Let's Encrypt)
+)
+
+// Config — настройки панели. Порядок применения: значения из файла, затем переменные окружения,
+// затем значения по умолчанию для незаданных полей. Пути внутри antizapret выводятся из antizapret.root.
+type Config struct {
+	// Адрес, на котором слушает HTTP-сервер, например :8080 или 127.0.0.1:8080
+	ListenAddr string `yaml:"listen_addr" toml:"listen_addr"`
+	// Внешний адрес панели для ссылок в QR-кодах, например https://vpn.example.com:8080
+	PublicBaseURL string `yaml:"public_base_url" toml:"public_base_url"`
+	// Директория для данных самой панели: пользователи, сессии, журнал аудита
+	DataPath string `yaml:"data_path" toml:"data_path"`
+	// Сколько при остановке ждать завершения запросов и запущенных client.sh.
+	// Должно быть меньше TimeoutStopSec в unit-файле systemd.
+	ShutdownTimeout Duration `yaml:"shutdown_timeout" toml:"shutdown_timeout"`
+	// Адреса или подсети обратных прокси, которым можно верить в X-Forwarded-For и X-Real-IP.
+	// По умолчанию пусто: IP клиента берется из соединения, иначе любой мог бы подменить его
+	// и обойти ограничение попыток входа.
+	TrustedProxies []string `yaml:"trusted_proxies" toml:"trusted_proxies"`
+
+	AntiZapret AntiZapretConfig `yaml:"antizapret" toml:"antizapret"`
+	Backups    BackupConfig     `yaml:"backups" toml:"backups"`
+	OpenVPN    OpenVPNConfig    `yaml:"openvpn" toml:"openvpn"`
+	WireGuard  WireGuardConfig  `yaml:"wireguard" toml:"wireguard"`
+	Auth       AuthConfig       `yaml:"auth" toml:"auth"`
+	TLS        TLSConfig        `yaml:"tls" toml:"tls"`
+}
+
+// AntiZapretConfig — расположение AntiZapret-VPN и его скрипта управления клиентами.
+type AntiZapretConfig struct {
+	Root string `yaml:"root" toml:"root"`
+	// По умолчанию <root>/client.sh
+	ClientScript string `yaml:"client_script" toml:"client_script"`
+	// Пересборка списков маршрутизации, по умолчанию <root>/doall.sh
+	DoallScript string `yaml:"doall_script" toml:"doall_script"`
+	// Корень клиентских профилей (openvpn/, wireguard/, amneziawg/), по умолчанию <root>/client/
+	ClientProfiles string `yaml:"client_profiles" toml:"client_profiles"`
+	// Списки маршрутизации (include-hosts.txt и т.д.), по умолчанию <root>/config/
+	ConfigPath string `yaml:"config_path" toml:"config_path"`
+}
+
+// BackupConfig — хранение резервных копий AntiZapret.
+type BackupConfig struct {
+	// Директория архивов, по умолчанию <data_path>/backups/
+	Path string `yaml:"path" toml:"path"`
+	// Сколько последних архивов хранить после создания нового, 0 — не удалять старые.
+	// Не задано — 10.
+	Keep *int `yaml:"keep" toml:"keep"`
+	// Конфигурация knot-resolver (*.lua), которая попадает в архив
+	KnotResolverPath string `yaml:"knot_resolver_path" toml:"knot_resolver_path"`
+}
+
+// OpenVPNConfig — пути к файлам OpenVPN.
+type OpenVPNConfig struct {
+	// Профили, по которым строится список клиентов, по умолчанию <root>/client/openvpn/vpn-udp/
+	ClientsPath string `yaml:"clients_path" toml:"clients_path"`
+	// По умолчанию <root>/client/openvpn/antizapret-udp/
+	AntizapretPath string `yaml:"antizapret_path" toml:"antizapret_path"`
+	StatusPath     string `yaml:"status_path" toml:"status_path"`
+	PKIPath        string `yaml:"pki_path" toml:"pki_path"`
+	// Сокеты management-интерфейса инстансов (<instance>.sock). Не проверяется при запуске:
+	// директорию создает OpenVPN, и без нее панель работает, только не видит подключения.
+	ManagementPath string `yaml:"management_path" toml:"management_path"`
+}
+
+// WireGuardConfig — пути к файлам WireGuard/AmneziaWG.
+type WireGuardConfig struct {
+	ConfigPath string `yaml:"config_path" toml:"config_path"`
+}
+
+// AuthConfig — настройки входа и ссылок на скачивание.
+type AuthConfig struct {
+	SessionTTL Duration `yaml:"session_ttl" toml:"session_ttl"`
+	// file или memory
+	DownloadTokenStore string `yaml:"download_token_store" toml:"download_token_store"`
+}
+
+// TLSConfig — настройки HTTPS. При включенном TLS обычные HTTP-запросы на listen_addr
+// перенаправляются на HTTPS на том же порту.
+type TLSConfig struct {
+	// off, file или acme
+	Mode     string `yaml:"mode" toml:"mode"`
+	CertFile string `yaml:"cert_file" toml:"cert_file"`
+	KeyFile  string `yaml:"key_file" toml:"key_file"`
+	// Дополнительный обычный HTTP-сервер, например :80: перенаправляет на HTTPS и отвечает на ACME HTTP-01.
+	// Без него ACME использует TLS-ALPN-01, для которого listen_addr должен быть доступен снаружи на порту 443.
+	HTTPAddr string     `yaml:"http_addr" toml:"http_addr"`
+	ACME     ACMEConfig `yaml:"acme" toml:"acme"`
+}
+
+// ACMEConfig — настройки автоматического получения сертификата.
+type ACMEConfig struct {
+	Domains []string `yaml:"domains" toml:"domains"`
+	Email   string   `yaml:"email" toml:"email"`
+	// Каталог ACME-сервера, по умолчанию Let's Encrypt. Для проверки можно указать локальный pebble.
+	DirectoryURL string `yaml:"directory_url" toml:"directory_url"`
+	// Директория для сертификатов и ключа аккаунта, по умолчанию <data_path>/acme
+	CacheDir string `yaml:"cache_dir" toml:"cache_dir"`
+}
+
+// Enabled сообщает, обслуживает ли панель HTTPS.
+func (t TLSConfig) Enabled() bool {
+	return t.Mode != TLSModeOff
+}
+
+// Duration — time.Duration, который в файле записывается строкой вида 30m или 12h.
+type Duration time.Duration
+
+// UnmarshalText разбирает длительность в формате time.ParseDuration.
+func (d *Duration) UnmarshalText(text []byte) error {
+	parsed, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+// MarshalText записывает длительность в формате time.Duration.String.
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}
+
+// Load читает конфигурацию из файла (YAML или TOML по расширению), применяет переменные окружения
+// и значения по умолчанию. Пустой path означает DefaultConfigFile, если он существует.
+// Load не проверяет файловую систему — для этого есть Validate.
+func Load(path string) (*Config, string, error) {
+	cfg := &Config{}
+
+	if path == "" {
+		if _, err := os.Stat(DefaultConfigFile); err == nil {
+			path = DefaultConfigFile
+		}
+	}
+	if path != "" {
+		if err := decodeFile(path, cfg); err != nil {
+			return nil, "", err
+		}
+	}
+
+	if err := cfg.applyEnv(); err != nil {
+		return nil, "", err
+	}
+	cfg.applyDefaults()
+	return cfg, path, nil
+}
+
+// decodeFile разбирает файл конфигурации. Неизвестные ключи считаются ошибкой, чтобы опечатка
+// в имени параметра не превращалась молча в значение по умолчанию.
+func decodeFile(path string, cfg *Config) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		decoder := yaml.NewDecoder(bytes.NewReader(data))
+		decoder.KnownFields(true)
+		if err := decoder.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+			return fmt.Errorf("failed to parse %s: %w", path, err)
+		}
+	case ".toml":
+		decoder := toml.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(cfg); err != nil {
+			return fmt.Errorf("failed to parse %s: %w", path, err)
+		}
+	default:
+		return fmt.Errorf("unsupported config file format %q: use .yaml, .yml or .toml", filepath.Ext(path))
+	}
+	return nil
+}
+
+// applyEnv переопределяет значения из файла переменными окружения с прежними именами.
+func (c *Config) applyEnv() error {
+	overrides := []struct {
+		name   string
+		target *string
+	}{
+		{"LISTEN_ADDR", &c.ListenAddr},
+		{"PUBLIC_BASE_URL", &c.PublicBaseURL},
+		{"DATA_PATH", &c.DataPath},
+		{"ANTIZAPRET_ROOT", &c.AntiZapret.Root},
+		{"CLIENT_SCRIPT_PATH", &c.AntiZapret.ClientScript},
+		{"DOALL_SCRIPT_PATH", &c.AntiZapret.DoallScript},
+		{"CLIENT_PROFILES_PATH", &c.AntiZapret.ClientProfiles},
+		{"ANTIZAPRET_CONFIG_PATH", &c.AntiZapret.ConfigPath},
+		{"OPENVPN_CLIENTS_PATH", &c.OpenVPN.ClientsPath},
+		{"OPENVPN_ANTIZAPRET_PATH", &c.OpenVPN.AntizapretPath},
+		{"OPENVPN_STATUS_PATH", &c.OpenVPN.StatusPath},
+		{"OPENVPN_MANAGEMENT_PATH", &c.OpenVPN.ManagementPath},
+		{"OPENVPN_PKI_PATH", &c.OpenVPN.PKIPath},
+		{"WIREGUARD_CONFIG_PATH", &c.WireGuard.ConfigPath},
+		{"DOWNLOAD_TOKEN_STORE", &c.Auth.DownloadTokenStore},
+		{"BACKUP_PATH", &c.Backups.Path},
+		{"KNOT_RESOLVER_PATH", &c.Backups.KnotResolverPath},
+		{"TLS_MODE", &c.TLS.Mode},
+		{"TLS_CERT_FILE", &c.TLS.CertFile},
+		{"TLS_KEY_FILE", &c.TLS.KeyFile},
+		{"TLS_HTTP_ADDR", &c.TLS.HTTPAddr},
+		{"ACME_EMAIL", &c.TLS.ACME.Email},
+		{"ACME_DIRECTORY_URL", &c.TLS.ACME.DirectoryURL},
+		{"ACME_CACHE_DIR", &c.TLS.ACME.CacheDir},
+	}
+	for _, o := range overrides {
+		if value := os.Getenv(o.name); value != "" {
+			*o.target = value
+		}
+	}
+
+	lists := []struct {
+		name   string
+		target *[]string
+	}{
+		{"ACME_DOMAINS", &c.TLS.ACME.Domains},
+		{"TRUSTED_PROXIES", &c.TrustedProxies},
+	}
+	for _, l := range lists {
+		if value := os.Getenv(l.name); value != "" {
+			*l.target = splitList(value)
+		}
+	}
+
+	// PORT оставлен для совместимости со старыми установками
+	if port := os.Getenv("PORT"); port != "" && os.Getenv("LISTEN_ADDR") == "" {
+		c.ListenAddr = ":" + port
+	}
+
+	if value := os.Getenv("BACKUP_KEEP"); value != "" {
+		keep, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("invalid BACKUP_KEEP %q: %w", value, err)
+		}
+		c.Backups.Keep = &keep
+	}
+
+	durations := []struct {
+		name   string
+		target *Duration
+	}{
+		{"SESSION_TTL", &c.Auth.SessionTTL},
+		{"SHUTDOWN_TIMEOUT", &c.ShutdownTimeout},
+	}
+	for _, d := range durations {
+		if value := os.Getenv(d.name); value != "" {
+			if err := d.target.UnmarshalText([]byte(value)); err != nil {
+				return fmt.Errorf("invalid %s %q: %w", d.name, value, err)
+			}
+		}
+	}
+	return nil
+}
+
+// applyDefaults заполняет незаданные поля.
+func (c *Config) applyDefaults() {
+	setDefault(&c.ListenAddr, defaultListenAddr)
+	setDefault(&c.DataPath, defaultDataPath)
+	setDefault(&c.AntiZapret.Root, defaultAntiZapretRoot)
+	setDefault(&c.AntiZapret.ClientScript, filepath.Join(c.AntiZapret.Root, "client.sh"))
+	setDefault(&c.AntiZapret.DoallScript, filepath.Join(c.AntiZapret.Root, "doall.sh"))
+	setDefault(&c.AntiZapret.ClientProfiles, filepath.Join(c.AntiZapret.Root, "client"))
+	setDefault(&c.AntiZapret.ConfigPath, filepath.Join(c.AntiZapret.Root, "config"))
+	setDefault(&c.OpenVPN.ClientsPath, filepath.Join(c.AntiZapret.ClientProfiles, "openvpn", "vpn-udp"))
+	setDefault(&c.OpenVPN.AntizapretPath, filepath.Join(c.AntiZapret.ClientProfiles, "openvpn", "antizapret-udp"))
+	setDefault(&c.OpenVPN.StatusPath, defaultOpenVPNStatusPath)
+	setDefault(&c.OpenVPN.PKIPath, defaultOpenVPNPKIPath)
+	setDefault(&c.OpenVPN.ManagementPath, defaultOpenVPNManagementPath)
+	setDefault(&c.WireGuard.ConfigPath, defaultWireGuardPath)
+	setDefault(&c.Auth.DownloadTokenStore, defaultDownloadTokenStore)
+	setDefault(&c.Backups.Path, filepath.Join(c.DataPath, "backups"))
+	setDefault(&c.Backups.KnotResolverPath, defaultKnotResolverPath)
+	if c.Backups.Keep == nil {
+		keep := defaultBackupKeep
+		c.Backups.Keep = &keep
+	}
+	if c.Auth.SessionTTL == 0 {
+		c.Auth.SessionTTL = Duration(defaultSessionTTL)
+	}
+	if c.ShutdownTimeout == 0 {
+		c.ShutdownTimeout = Duration(defaultShutdownTimeout)
+	}
+	setDefault(&c.TLS.Mode, TLSModeOff)
+	setDefault(&c.TLS.ACME.DirectoryURL, defaultACMEDirectoryURL)
+	setDefault(&c.TLS.ACME.CacheDir, filepath.Join(c.DataPath, "acme"))
+}
+
+// splitList разбирает значение переменной окружения со списком через запятую.
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func setDefault(field *string, value string) {
+	if *field == "" {
+		*field = value
+	}
+}
+
+// Validate проверяет значения и файловую систему и возвращает все найденные проблемы разом.
+// Обязательны только скрипты AntiZapret и директория данных. Отсутствие остальных директорий
+// не ошибка (например, WireGuard-only установка или еще ни одного клиента), о нем сообщает Warnings.
+func (c *Config) Validate() error {
+	var errs []error
+
+	if _, _, err := net.SplitHostPort(c.ListenAddr); err != nil {
+		errs = append(errs, fmt.Errorf("listen_addr %q: %w", c.ListenAddr, err))
+	}
+	if c.PublicBaseURL != "" {
+		u, err := url.Parse(c.PublicBaseURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs = append(errs, fmt.Errorf("public_base_url %q: must be an absolute http(s) URL", c.PublicBaseURL))
+		}
+	}
+	for _, proxy := range c.TrustedProxies {
+		if net.ParseIP(proxy) == nil {
+			if _, _, err := net.ParseCIDR(proxy); err != nil {
+				errs = append(errs, fmt.Errorf("trusted_proxies %q: must be an IP address or CIDR", proxy))
+			}
+		}
+	}
+	if c.ShutdownTimeout <= 0 {
+		errs = append(errs, fmt.Errorf("shutdown_timeout must be positive"))
+	}
+	if c.Auth.SessionTTL <= 0 {
+		errs = append(errs, fmt.Errorf("auth.session_ttl must be positive"))
+	}
+	if c.Auth.DownloadTokenStore != "file" && c.Auth.DownloadTokenStore != "memory" {
+		errs = append(errs, fmt.Errorf("auth.download_token_store %q: must be 'file' or 'memory'", c.Auth.DownloadTokenStore))
+	}
+
+	errs = append(errs, c.TLS.validate()...)
+
+	if err := checkExecutable(c.AntiZapret.ClientScript); err != nil {
+		errs = append(errs, fmt.Errorf("antizapret.client_script: %w", err))
+	}
+	if err := checkExecutable(c.AntiZapret.DoallScript); err != nil {
+		errs = append(errs, fmt.Errorf("antizapret.doall_script: %w", err))
+	}
+
+	// Пути VPN могут отсутствовать, но файл вместо директории — ошибка в конфигурации
+	for _, d := range c.optionalDirs() {
+		if info, err := os.Stat(d.path); err == nil && !info.IsDir() {
+			errs = append(errs, fmt.Errorf("%s: %s is not a directory", d.key, d.path))
+		}
+	}
+
+	// Директорию данных создает установщик. Если ее нет, скорее всего, в пути опечатка,
+	// и панель завела бы пустую базу пользователей рядом с настоящей.
+	if err := checkDir(c.DataPath); err != nil {
+		errs = append(errs, fmt.Errorf("data_path: %w", err))
+	}
+	// Директорию резервных копий панель создает сама, но она не должна оказаться файлом
+	if info, err := os.Stat(c.Backups.Path); err == nil && !info.IsDir() {
+		errs = append(errs, fmt.Errorf("backups.path: %s is not a directory", c.Backups.Path))
+	}
+	if c.Backups.Keep != nil && *c.Backups.Keep < 0 {
+		errs = append(errs, fmt.Errorf("backups.keep must not be negative"))
+	}
+
+	return errors.Join(errs...)
+}
+
+// Warnings возвращает отсутствующие директории VPN. Панель работает и без них:
+// отсутствующая директория профилей означает, что клиентов этого типа нет.
+func (c *Config) Warnings() []string {
+	var warnings []string
+	for _, d := range c.optionalDirs() {
+		if _, err := os.Stat(d.path); err != nil {
+			warnings = append(warnings, fmt.Sprintf("%s: %v", d.key, err))
+		}
+	}
+	return warnings
+}
+
+type configDir struct {
+	key  string
+	path string
+}
+
+// optionalDirs — директории AntiZapret, OpenVPN и WireGuard, без которых панель запускается.
+func (c *Config) optionalDirs() []configDir {
+	return []configDir{
+		{"antizapret.client_profiles", c.AntiZapret.ClientProfiles},
+		{"antizapret.config_path", c.AntiZapret.ConfigPath},
+		{"openvpn.clients_path", c.OpenVPN.ClientsPath},
+		{"openvpn.antizapret_path", c.OpenVPN.AntizapretPath},
+		{"openvpn.status_path", c.OpenVPN.StatusPath},
+		{"openvpn.pki_path", c.OpenVPN.PKIPath},
+		{"wireguard.config_path", c.WireGuard.ConfigPath},
+	}
+}
+
+// validate проверяет настройки TLS: для file — что пара сертификат/ключ загружается,
+// для acme — что заданы домены и адрес ACME-сервера.
+func (t TLSConfig) validate() []error {
+	var errs []error
+
+	switch t.Mode {
+	case TLSModeOff:
+		return nil
+	case TLSModeFile:
+		if t.CertFile == "" || t.KeyFile == "" {
+			errs = append(errs, fmt.Errorf("tls: cert_file and key_file are required for mode 'file'"))
+		} else if _, err := tls.LoadX509KeyPair(t.CertFile, t.KeyFile); err != nil {
+			errs = append(errs, fmt.Errorf("tls: failed to load certificate: %w", err))
+		}
+	case TLSModeACME:
+		if len(t.ACME.Domains) == 0 {
+			errs = append(errs, fmt.Errorf("tls.acme.domains: at least one domain is required for mode 'acme'"))
+		}
+		if u, err := url.Parse(t.ACME.DirectoryURL); err != nil || u.Scheme != "https" || u.Host == "" {
+			errs = append(errs, fmt.Errorf("tls.acme.directory_url %q: must be an absolute https URL", t.ACME.DirectoryURL))
+		}
+	default:
+		return []error{fmt.Errorf("tls.mode %q: must be 'off', 'file' or 'acme'", t.Mode)}
+	}
+
+	if t.HTTPAddr != "" {
+		if _, _, err := net.SplitHostPort(t.HTTPAddr); err != nil {
+			errs = append(errs, fmt.Errorf("tls.http_addr %q: %w", t.HTTPAddr, err))
+		}
+	}
+	return errs
+}
+
+func checkDir(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%s is not a directory", path)
+	}
+	return nil
+}
+
+func checkExecutable(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if !info.Mode().IsRegular() {
+		return fmt.Errorf("%s is not a regular file", path)
+	}
+	if info.Mode().Perm()&0o111 == 0 {
+		return fmt.Errorf("%s is not executable", path)
+	}
+	return nil
+}
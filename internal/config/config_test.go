@@ -0,0 +1,94 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// newTestConfig возвращает конфигурацию, в которой есть только скрипты AntiZapret и директория данных
+func newTestConfig(t *testing.T) *Config {
+	t.Helper()
+	root := t.TempDir()
+	for _, script := range []string{"client.sh", "doall.sh"} {
+		if err := os.WriteFile(filepath.Join(root, script), []byte("#!/bin/sh\n"), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	dataPath := filepath.Join(root, "data")
+	if err := os.Mkdir(dataPath, 0o700); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &Config{
+		DataPath:   dataPath,
+		AntiZapret: AntiZapretConfig{Root: root},
+		OpenVPN: OpenVPNConfig{
+			StatusPath: filepath.Join(root, "openvpn", "logs"),
+			PKIPath:    filepath.Join(root, "openvpn", "pki"),
+		},
+		WireGuard: WireGuardConfig{ConfigPath: filepath.Join(root, "wireguard")},
+	}
+	cfg.applyDefaults()
+	return cfg
+}
+
+// Свежая установка без клиентов и директорий VPN запускается, отсутствующие пути — предупреждения
+func TestValidateMissingVPNDirs(t *testing.T) {
+	cfg := newTestConfig(t)
+
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+	warnings := cfg.Warnings()
+	if len(warnings) != 7 {
+		t.Fatalf("got %d warnings, want 7: %q", len(warnings), warnings)
+	}
+	if !strings.HasPrefix(warnings[0], "antizapret.client_profiles: ") {
+		t.Errorf("warning = %q", warnings[0])
+	}
+
+	if err := os.MkdirAll(cfg.WireGuard.ConfigPath, 0o700); err != nil {
+		t.Fatal(err)
+	}
+	for _, warning := range cfg.Warnings() {
+		if strings.HasPrefix(warning, "wireguard.config_path") {
+			t.Errorf("existing directory reported: %q", warning)
+		}
+	}
+}
+
+func TestValidateRequiredPaths(t *testing.T) {
+	tests := []struct {
+		name  string
+		setup func(t *testing.T, cfg *Config)
+		want  string
+	}{
+		{"missing client.sh", func(t *testing.T, cfg *Config) {
+			os.Remove(cfg.AntiZapret.ClientScript)
+		}, "antizapret.client_script"},
+		{"doall.sh is not executable", func(t *testing.T, cfg *Config) {
+			os.Chmod(cfg.AntiZapret.DoallScript, 0o644)
+		}, "antizapret.doall_script"},
+		{"missing data directory", func(t *testing.T, cfg *Config) {
+			cfg.DataPath = filepath.Join(cfg.DataPath, "typo")
+		}, "data_path"},
+		{"file instead of a VPN directory", func(t *testing.T, cfg *Config) {
+			if err := os.WriteFile(cfg.WireGuard.ConfigPath, nil, 0o600); err != nil {
+				t.Fatal(err)
+			}
+		}, "wireguard.config_path"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newTestConfig(t)
+			tt.setup(t, cfg)
+
+			err := cfg.Validate()
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("err = %v, want %s", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,22 @@
+package entity
+
+import "time"
+
+// ApplyRun — завершенный запуск doall.sh. ExitCode -1 — скрипт не запустился или был убит сигналом.
+type ApplyRun struct {
+	JobID      string    `json:"jobId"`
+	StartedBy  string    `json:"startedBy,omitempty"`
+	StartedAt  time.Time `json:"startedAt"`
+	FinishedAt time.Time `json:"finishedAt"`
+	DurationMs int64     `json:"durationMs"`
+	ExitCode   int       `json:"exitCode"`
+	Error      string    `json:"error,omitempty"`
+}
+
+// ApplyStatus — состояние применения списков для отображения в панели.
+type ApplyStatus struct {
+	// Задача doall.sh, которая сейчас в очереди или выполняется
+	CurrentJobID string    `json:"currentJobId,omitempty"`
+	LastRun      *ApplyRun `json:"lastRun,omitempty"`
+	LastSuccess  *ApplyRun `json:"lastSuccess,omitempty"`
+}
@@ -0,0 +1,67 @@
+package entity
+
+import "time"
+
+// Действия, которые попадают в журнал аудита
+const (
+	AuditActionLogin            = "login"
+	AuditActionLoginTOTP        = "login.totp"
+	AuditActionClientCreate     = "client.create"
+	AuditActionClientDelete     = "client.delete"
+	AuditActionClientRenew      = "client.renew"
+	AuditActionClientDisconnect = "client.disconnect"
+	AuditActionConfigDownload   = "client.config.download"
+	AuditActionTokenIssue       = "client.token.issue"
+	AuditActionTokenRevoke      = "client.token.revoke"
+	AuditActionListAdd          = "list.add"
+	AuditActionListRemove       = "list.remove"
+	AuditActionListReplace      = "list.replace"
+	AuditActionApply            = "antizapret.apply"
+	AuditActionBackupCreate     = "backup.create"
+	AuditActionBackupDownload   = "backup.download"
+	AuditActionBackupDelete     = "backup.delete"
+	AuditActionBackupUpload     = "backup.upload"
+	AuditActionBackupRestore    = "backup.restore"
+)
+
+// Результат действия
+const (
+	AuditOutcomeSuccess = "success"
+	AuditOutcomeFailure = "failure"
+)
+
+// AuditEntry — запись журнала аудита: кто, когда, откуда, что сделал и чем это закончилось.
+type AuditEntry struct {
+	ID         string    `json:"id"`
+	Time       time.Time `json:"time"`
+	Actor      string    `json:"actor"`
+	IP         string    `json:"ip"`
+	Action     string    `json:"action"`
+	ClientID   string    `json:"clientId,omitempty"`
+	ClientName string    `json:"clientName,omitempty"`
+	ClientType string    `json:"clientType,omitempty"`
+	Outcome    string    `json:"outcome"`
+	Error      string    `json:"error,omitempty"`
+	// Объект действия, не связанный с клиентом, например имя списка маршрутизации
+	Target string `json:"target,omitempty"`
+	// Вывод client.sh для действий, которые его запускают, или описание изменений
+	Output string `json:"output,omitempty"`
+}
+
+// AuditFilter — условия выборки из журнала аудита. Пустые поля не фильтруют.
+type AuditFilter struct {
+	Actor   string
+	Action  string
+	Client  string // ID или имя клиента
+	Outcome string
+	From    time.Time
+	To      time.Time
+}
+
+// PaginatedAuditEntries — страница журнала аудита, новые записи первыми.
+type PaginatedAuditEntries struct {
+	Total   int          `json:"total"`
+	Entries []AuditEntry `json:"entries"`
+	// Строки журнала, которые не удалось прочитать (например, оборванные при сбое)
+	Skipped int `json:"skipped"`
+}
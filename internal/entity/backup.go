@@ -0,0 +1,82 @@
+package entity
+
+import "time"
+
+// Backup — архив резервной копии AntiZapret: PKI easyrsa, конфиги WireGuard, списки маршрутизации и т.д.
+type Backup struct {
+	Name      string    `json:"name"`
+	Kind      string    `json:"kind"`
+	Size      int64     `json:"size"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// Происхождение архива, определяется по имени
+const (
+	// Создан POST /api/backups или положен в директорию вручную. Только такие удаляются по лимиту keep.
+	BackupKindCreated = "created"
+	// Загружен через POST /api/backups/upload
+	BackupKindUpload = "upload"
+	// Состояние сервера перед восстановлением из другого архива
+	BackupKindPreRestore = "pre-restore"
+)
+
+// BackupFormatVersion — версия формата manifest.json, которую пишет и понимает панель
+const BackupFormatVersion = 1
+
+// BackupManifest — manifest.json в корне архива: откуда и чем создан архив и контрольные суммы файлов.
+// В архивах client.sh его нет.
+type BackupManifest struct {
+	FormatVersion int       `json:"formatVersion"`
+	CreatedAt     time.Time `json:"createdAt"`
+	Hostname      string    `json:"hostname,omitempty"`
+	PanelVersion  string    `json:"panelVersion"`
+	GoVersion     string    `json:"goVersion"`
+	// Пустой в предпросмотре восстановления
+	Files []BackupManifestFile `json:"files,omitempty"`
+}
+
+// BackupManifestFile — файл архива. Path — путь внутри архива, например easyrsa3/pki/index.txt.
+type BackupManifestFile struct {
+	Path   string `json:"path"`
+	Size   int64  `json:"size"`
+	Mode   string `json:"mode"`
+	SHA256 string `json:"sha256"`
+}
+
+// Что восстановление сделает с файлом
+const (
+	RestoreActionCreate    = "create"
+	RestoreActionUpdate    = "update"
+	RestoreActionUnchanged = "unchanged"
+	// Файл есть на сервере, но не в архиве: восстановление его не трогает
+	RestoreActionExtra = "extra"
+)
+
+// RestoreChange — файл, который восстановление создаст или перезапишет, или лишний файл на сервере.
+type RestoreChange struct {
+	Path   string `json:"path"`
+	Target string `json:"target"`
+	Action string `json:"action"`
+	Size   int64  `json:"size"`
+}
+
+// RestorePreview — что изменится при восстановлении архива. Неизмененные файлы в Changes не попадают.
+type RestorePreview struct {
+	Backup string `json:"backup"`
+	// Manifest без списка файлов; nil для архива client.sh
+	Manifest *BackupManifest `json:"manifest,omitempty"`
+	// Контрольные суммы проверены по manifest.json
+	Verified  bool            `json:"verified"`
+	Changes   []RestoreChange `json:"changes"`
+	Created   int             `json:"created"`
+	Updated   int             `json:"updated"`
+	Unchanged int             `json:"unchanged"`
+	Extra     int             `json:"extra"`
+	Warnings  []string        `json:"warnings"`
+}
+
+// RestoreResult — итог восстановления. SafetyBackup — архив состояния сервера перед восстановлением.
+type RestoreResult struct {
+	RestorePreview
+	SafetyBackup string `json:"safetyBackup"`
+}
@@ -2,15 +2,115 @@ package entity
 
 import "time"
 
+// Типы клиентов, которые умеет создавать client.sh.
+const (
+	ClientTypeOpenVPN   = "OpenVPN"
+	ClientTypeWireGuard = "WireGuard"
+)
+
+// Профили, которые client.sh создает для клиента WireGuard: обычный WireGuard и AmneziaWG.
+const (
+	WireGuardFlavorWireGuard = "wireguard"
+	WireGuardFlavorAmneziaWG = "amneziawg"
+)
+
+// OpenVPNVariants — варианты профиля OpenVPN, которые client.sh создает для каждого клиента.
+// Каждый лежит в одноименном подкаталоге openvpn/.
+var OpenVPNVariants = []string{"antizapret", "antizapret-udp", "antizapret-tcp", "vpn", "vpn-udp", "vpn-tcp"}
+
+// IsOpenVPNVariant проверяет, что вариант профиля OpenVPN известен.
+func IsOpenVPNVariant(variant string) bool {
+	for _, v := range OpenVPNVariants {
+		if v == variant {
+			return true
+		}
+	}
+	return false
+}
+
+// ClientProfile — файл клиентского профиля на диске.
+// Kind — openvpn, wireguard или amneziawg; Variant — подкаталог, например vpn-udp или antizapret.
+type ClientProfile struct {
+	Kind    string `json:"kind"`
+	Variant string `json:"variant"`
+	Path    string `json:"-"`
+}
+
+// Статусы клиентов. Online/Offline определяются по status.log OpenVPN,
+// Expired/Revoked — по сертификату и pki/index.txt.
+// Для WireGuard/AmneziaWG статус подключения не отслеживается.
+const (
+	ClientStatusActive  = "Active"
+	ClientStatusOnline  = "Online"
+	ClientStatusOffline = "Offline"
+	ClientStatusExpired = "Expired"
+	ClientStatusRevoked = "Revoked"
+)
+
 type Client struct {
-	ID        int       `json:"id"`
+	ID        string    `json:"id"`
 	Name      string    `json:"name"`
 	Type      string    `json:"type"`
 	Status    string    `json:"status"`
 	CreatedAt time.Time `json:"createdAt"`
+
+	// Данные текущего подключения (только для Online)
+	RealAddress    string     `json:"realAddress,omitempty"`
+	VirtualAddress string     `json:"virtualAddress,omitempty"`
+	BytesReceived  int64      `json:"bytesReceived"`
+	BytesSent      int64      `json:"bytesSent"`
+	ConnectedSince *time.Time `json:"connectedSince,omitempty"`
+
+	// Сертификат OpenVPN
+	Serial        string     `json:"serial,omitempty"`
+	NotBefore     *time.Time `json:"notBefore,omitempty"`
+	NotAfter      *time.Time `json:"notAfter,omitempty"`
+	DaysRemaining *int       `json:"daysRemaining,omitempty"`
+	RevokedAt     *time.Time `json:"revokedAt,omitempty"`
 }
 
 type PaginatedClients struct {
 	Total   int      `json:"total"`
 	Clients []Client `json:"clients"`
 }
+
+// Статусы строк пакетного создания клиентов
+const (
+	// Строка прошла проверку, но пакет отклонен из-за других строк
+	BulkRowValid   = "valid"
+	BulkRowCreated = "created"
+	BulkRowFailed  = "failed"
+	BulkRowInvalid = "invalid"
+	// Строка не обработана: панель остановилась до ее очереди
+	BulkRowSkipped = "skipped"
+)
+
+// BulkClientRow — строка пакетного создания клиентов из CSV или JSON.
+// Type — entity.ClientTypeOpenVPN или entity.ClientTypeWireGuard, ExpiresIn 0 — срок по умолчанию.
+type BulkClientRow struct {
+	Name      string `json:"name"`
+	Type      string `json:"type"`
+	ExpiresIn int    `json:"expires_in,omitempty"`
+}
+
+// BulkClientResult — результат обработки одной строки. Row считается с 1.
+type BulkClientResult struct {
+	Row    int     `json:"row"`
+	Name   string  `json:"name"`
+	Type   string  `json:"type"`
+	Status string  `json:"status"`
+	Client *Client `json:"client,omitempty"`
+	Error  string  `json:"error,omitempty"`
+	// Ошибки проверки по полям строки
+	Fields map[string]string `json:"fields,omitempty"`
+	// Вывод client.sh для журнала аудита
+	Output string `json:"-"`
+}
+
+// BulkClientReport — итог пакетного создания клиентов.
+type BulkClientReport struct {
+	Created int                `json:"created"`
+	Failed  int                `json:"failed"`
+	Skipped int                `json:"skipped"`
+	Results []BulkClientResult `json:"results"`
+}
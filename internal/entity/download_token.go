@@ -0,0 +1,20 @@
+package entity
+
+import "time"
+
+// DownloadToken — ссылка на скачивание конфига без входа в панель.
+// Сам токен клиенту отдается один раз, хранится только его SHA-256.
+type DownloadToken struct {
+	ID         string    `json:"id"`
+	TokenHash  string    `json:"-"`
+	FilePath   string    `json:"-"`
+	FileName   string    `json:"fileName"`
+	ClientID   string    `json:"clientId"`
+	ClientName string    `json:"clientName"`
+	ClientType string    `json:"clientType"`
+	CreatedBy  string    `json:"createdBy"`
+	CreatedAt  time.Time `json:"createdAt"`
+	ExpiresAt  time.Time `json:"expiresAt"`
+	MaxUses    int       `json:"maxUses"`
+	Uses       int       `json:"uses"`
+}
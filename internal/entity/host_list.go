@@ -0,0 +1,36 @@
+package entity
+
+// Списки маршрутизации AntiZapret в директории config/
+const (
+	HostListIncludeHosts = "include-hosts"
+	HostListExcludeHosts = "exclude-hosts"
+	HostListIncludeIPs   = "include-ips"
+)
+
+// Виды записей в списках
+const (
+	HostListKindDomain = "domain"
+	HostListKindCIDR   = "cidr"
+)
+
+// HostListKinds сопоставляет список с видом его записей.
+var HostListKinds = map[string]string{
+	HostListIncludeHosts: HostListKindDomain,
+	HostListExcludeHosts: HostListKindDomain,
+	HostListIncludeIPs:   HostListKindCIDR,
+}
+
+// HostList — список маршрутизации. Entries не содержит комментариев и пустых строк.
+type HostList struct {
+	Name    string   `json:"name"`
+	Kind    string   `json:"kind"`
+	Count   int      `json:"count"`
+	Entries []string `json:"entries,omitempty"`
+}
+
+// HostListChange — результат изменения списка: какие записи добавлены и удалены.
+type HostListChange struct {
+	List    HostList `json:"list"`
+	Added   []string `json:"added"`
+	Removed []string `json:"removed"`
+}
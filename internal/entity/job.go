@@ -0,0 +1,38 @@
+package entity
+
+import "time"
+
+// Статусы задач очереди client.sh
+const (
+	JobStatusQueued    = "queued"
+	JobStatusRunning   = "running"
+	JobStatusSucceeded = "succeeded"
+	JobStatusFailed    = "failed"
+)
+
+// JobActionClientBulkCreate — пакетное создание клиентов. В журнал аудита каждый клиент пишется отдельно как client.create.
+const JobActionClientBulkCreate = "client.bulk.create"
+
+// Job — операция, поставленная в очередь выполнения client.sh.
+// Action совпадает с действием в журнале аудита, например client.create.
+type Job struct {
+	ID         string     `json:"id"`
+	Action     string     `json:"action"`
+	ClientID   string     `json:"clientId,omitempty"`
+	ClientName string     `json:"clientName,omitempty"`
+	ClientType string     `json:"clientType,omitempty"`
+	Status     string     `json:"status"`
+	CreatedBy  string     `json:"createdBy,omitempty"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	StartedAt  *time.Time `json:"startedAt,omitempty"`
+	FinishedAt *time.Time `json:"finishedAt,omitempty"`
+	Output     string     `json:"output,omitempty"`
+	Error      string     `json:"error,omitempty"`
+	// Результат операции, например созданный клиент
+	Result any `json:"result,omitempty"`
+}
+
+// Finished сообщает, завершена ли задача.
+func (j *Job) Finished() bool {
+	return j.Status == JobStatusSucceeded || j.Status == JobStatusFailed
+}
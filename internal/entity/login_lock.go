@@ -0,0 +1,18 @@
+package entity
+
+import "time"
+
+// Виды счетчиков неудачных входов
+const (
+	LoginLockKindIP       = "ip"
+	LoginLockKindUsername = "username"
+)
+
+// LoginLock — IP-адрес или логин, для которого вход временно заблокирован после неудачных попыток.
+type LoginLock struct {
+	Kind        string    `json:"kind"`
+	Key         string    `json:"key"`
+	Failures    int       `json:"failures"`
+	LastFailure time.Time `json:"lastFailure"`
+	LockedUntil time.Time `json:"lockedUntil"`
+}
@@ -0,0 +1,66 @@
+package entity
+
+// Роли пользователей панели
+const (
+	RoleAdmin    = "admin"    // полный доступ, включая пользователей, сессии, журнал аудита, списки маршрутизации и резервные копии
+	RoleOperator = "operator" // управление клиентами VPN, просмотр списков маршрутизации
+	RoleViewer   = "viewer"   // только просмотр клиентов и скачивание конфигов
+)
+
+// Права, которые требуют маршруты API
+const (
+	PermissionClientsRead     = "clients:read"
+	PermissionClientsDownload = "clients:download"
+	PermissionClientsWrite    = "clients:write"
+	PermissionUsersManage     = "users:manage"
+	PermissionSessionsManage  = "sessions:manage"
+	PermissionAuditRead       = "audit:read"
+	PermissionListsRead       = "lists:read"
+	PermissionListsWrite      = "lists:write"
+	PermissionBackupsManage   = "backups:manage"
+)
+
+var rolePermissions = map[string][]string{
+	RoleAdmin: {
+		PermissionClientsRead,
+		PermissionClientsDownload,
+		PermissionClientsWrite,
+		PermissionUsersManage,
+		PermissionSessionsManage,
+		PermissionAuditRead,
+		PermissionListsRead,
+		PermissionListsWrite,
+		PermissionBackupsManage,
+	},
+	RoleOperator: {
+		PermissionClientsRead,
+		PermissionClientsDownload,
+		PermissionClientsWrite,
+		PermissionListsRead,
+	},
+	RoleViewer: {
+		PermissionClientsRead,
+		PermissionClientsDownload,
+	},
+}
+
+// IsValidRole проверяет, что роль известна.
+func IsValidRole(role string) bool {
+	_, ok := rolePermissions[role]
+	return ok
+}
+
+// RolePermissions возвращает список прав роли.
+func RolePermissions(role string) []string {
+	return rolePermissions[role]
+}
+
+// HasPermission проверяет, есть ли у роли указанное право.
+func HasPermission(role, permission string) bool {
+	for _, p := range rolePermissions[role] {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
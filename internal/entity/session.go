@@ -0,0 +1,14 @@
+package entity
+
+import "time"
+
+// Session — сессия входа в панель. Сам токен не хранится, только его хеш.
+type Session struct {
+	ID        string    `json:"id"`
+	Username  string    `json:"username"`
+	TokenHash string    `json:"-"`
+	IP        string    `json:"ip"`
+	UserAgent string    `json:"userAgent"`
+	CreatedAt time.Time `json:"createdAt"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
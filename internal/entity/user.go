@@ -0,0 +1,25 @@
+package entity
+
+import "time"
+
+// User — учетная запись администратора панели.
+// Хеш пароля, секрет TOTP и коды восстановления наружу не отдаются.
+type User struct {
+	Username     string    `json:"username"`
+	PasswordHash string    `json:"-"`
+	Role         string    `json:"role"`
+	CreatedAt    time.Time `json:"createdAt"`
+	UpdatedAt    time.Time `json:"updatedAt"`
+	// Сессии, выданные до смены пароля, считаются недействительными
+	PasswordChangedAt time.Time `json:"passwordChangedAt"`
+
+	// Второй фактор (RFC 6238). Пока TOTPEnabled не установлен, вход только по паролю
+	TOTPEnabled bool   `json:"totpEnabled"`
+	TOTPSecret  string `json:"-"`
+	// Секрет, выданный при подключении, но еще не подтвержденный кодом
+	TOTPPendingSecret string `json:"-"`
+	// Последний принятый временной шаг: один и тот же код нельзя использовать дважды
+	TOTPLastStep int64 `json:"-"`
+	// SHA-256 неиспользованных кодов восстановления
+	RecoveryCodeHashes []string `json:"-"`
+}
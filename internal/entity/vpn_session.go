@@ -0,0 +1,18 @@
+package entity
+
+import "time"
+
+// VPNSession — текущее подключение клиента OpenVPN по данным management-интерфейса.
+// Не путать с Session — сессией входа в панель.
+type VPNSession struct {
+	// ID клиента панели; пустой, если профиль клиента не найден (например, уже удален)
+	ClientID string `json:"clientId,omitempty"`
+	Name     string `json:"name"`
+	// Инстанс OpenVPN: antizapret-udp, antizapret-tcp, vpn-udp или vpn-tcp
+	Instance       string     `json:"instance"`
+	RealAddress    string     `json:"realAddress"`
+	VirtualAddress string     `json:"virtualAddress,omitempty"`
+	BytesReceived  int64      `json:"bytesReceived"`
+	BytesSent      int64      `json:"bytesSent"`
+	ConnectedSince *time.Time `json:"connectedSince,omitempty"`
+}
@@ -1,25 +1,58 @@
 package middleware
 
 import (
+	"antizapret-admin-panel/internal/entity"
+	"antizapret-admin-panel/internal/service"
 	"net/http"
-	"os"
 
 	"github.com/gin-gonic/gin"
 )
 
-func AuthMiddleware() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		adminPassword := os.Getenv("ADMIN_PASSWORD")
-		if adminPassword == "" {
-			adminPassword = "password"
-		}
+// Ключи, под которыми AuthMiddleware кладет пользователя и сессию в gin.Context
+const (
+	ContextUserKey    = "user"
+	ContextSessionKey = "session"
+)
 
+func AuthMiddleware(auth service.AuthService) gin.HandlerFunc {
+	return func(c *gin.Context) {
 		token := c.GetHeader("X-Auth-Token")
-		if token != adminPassword {
+		user, session, err := auth.ValidateToken(token)
+		if err != nil {
 			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
 			return
 		}
 
+		c.Set(ContextUserKey, user)
+		c.Set(ContextSessionKey, session)
+		c.Next()
+	}
+}
+
+// CurrentUser возвращает пользователя, прошедшего AuthMiddleware.
+func CurrentUser(c *gin.Context) *entity.User {
+	user, _ := c.Get(ContextUserKey)
+	u, _ := user.(*entity.User)
+	return u
+}
+
+// CurrentSession возвращает сессию, с которой пришел запрос.
+func CurrentSession(c *gin.Context) *entity.Session {
+	session, _ := c.Get(ContextSessionKey)
+	s, _ := session.(*entity.Session)
+	return s
+}
+
+// RequirePermission пропускает запрос, только если роль текущего пользователя дает право permission.
+// Ставится после AuthMiddleware.
+func RequirePermission(permission string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user := CurrentUser(c)
+		if user == nil || !entity.HasPermission(user.Role, permission) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Forbidden"})
+			return
+		}
+
 		c.Next()
 	}
 }
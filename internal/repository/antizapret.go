@@ -0,0 +1,77 @@
+package repository
+
+import (
+	"antizapret-admin-panel/internal/entity"
+	"context"
+	"errors"
+	"io"
+	"os/exec"
+	"sync"
+)
+
+// AntiZapretRepository запускает doall.sh и хранит сведения о последних запусках.
+type AntiZapretRepository interface {
+	// Apply запускает doall.sh, вывод пишется в output по мере выполнения.
+	// Возвращает код выхода скрипта; -1, если он не запустился или был убит сигналом.
+	Apply(ctx context.Context, output io.Writer) (int, error)
+	// LastRuns возвращает последний и последний успешный запуски, если они были
+	LastRuns() (last, lastSuccess *entity.ApplyRun, err error)
+	SaveRun(run entity.ApplyRun) error
+}
+
+// applyRunsFile — формат файла с запусками doall.sh
+type applyRunsFile struct {
+	LastRun     *entity.ApplyRun `json:"lastRun,omitempty"`
+	LastSuccess *entity.ApplyRun `json:"lastSuccess,omitempty"`
+}
+
+type fileAntiZapretRepository struct {
+	doallScript string
+	scripts     ScriptRunner
+	path        string
+	mu          sync.Mutex
+}
+
+// NewAntiZapretRepository — конструктор. path — JSON-файл с последними запусками в директории данных.
+func NewAntiZapretRepository(doallScript string, scripts ScriptRunner, path string) AntiZapretRepository {
+	return &fileAntiZapretRepository{doallScript: doallScript, scripts: scripts, path: path}
+}
+
+func (r *fileAntiZapretRepository) Apply(ctx context.Context, output io.Writer) (int, error) {
+	err := r.scripts.Stream(ctx, output, r.doallScript)
+	if err == nil {
+		return 0, nil
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode(), err
+	}
+	return -1, err
+}
+
+func (r *fileAntiZapretRepository) LastRuns() (*entity.ApplyRun, *entity.ApplyRun, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var runs applyRunsFile
+	if err := readJSONFile(r.path, &runs); err != nil {
+		return nil, nil, err
+	}
+	return runs.LastRun, runs.LastSuccess, nil
+}
+
+// SaveRun запоминает запуск как последний, а успешный — еще и как последний успешный.
+func (r *fileAntiZapretRepository) SaveRun(run entity.ApplyRun) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var runs applyRunsFile
+	if err := readJSONFile(r.path, &runs); err != nil {
+		return err
+	}
+	runs.LastRun = &run
+	if run.ExitCode == 0 && run.Error == "" {
+		runs.LastSuccess = &run
+	}
+	return writeJSONFile(r.path, runs)
+}
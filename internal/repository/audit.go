@@ -0,0 +1,159 @@
+package repository
+
+import (
+	"antizapret-admin-panel/internal/entity"
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Максимальная длина строки журнала при чтении (с учетом вывода скрипта)
+const maxAuditLineSize = 1 << 20
+
+// AuditRepository — контракт журнала аудита. Записи только добавляются.
+type AuditRepository interface {
+	Append(entry entity.AuditEntry) error
+	Find(filter entity.AuditFilter, page, limit int) (*entity.PaginatedAuditEntries, error)
+}
+
+// NewAuditRepository — конструктор журнала аудита в формате JSON Lines (одна запись на строку).
+func NewAuditRepository(path string) AuditRepository {
+	return &fileAuditRepository{path: path}
+}
+
+type fileAuditRepository struct {
+	path string
+	mu   sync.Mutex
+}
+
+// Append дописывает запись в конец файла. Файл открывается с O_APPEND,
+// поэтому существующие записи не переписываются.
+func (r *fileAuditRepository) Append(entry entity.AuditEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(r.path), 0o700); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(r.path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0o600)
+	if err != nil {
+		return err
+	}
+	// Если прошлая запись оборвалась на полуслове, новая начинается с новой строки,
+	// иначе при чтении пропадет и она
+	if info, err := file.Stat(); err == nil && info.Size() > 0 {
+		last := make([]byte, 1)
+		if _, err := file.ReadAt(last, info.Size()-1); err == nil && last[0] != '\n' {
+			data = append([]byte{'\n'}, data...)
+		}
+	}
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// Find читает журнал целиком и возвращает страницу подходящих записей, новые первыми.
+func (r *fileAuditRepository) Find(filter entity.AuditFilter, page, limit int) (*entity.PaginatedAuditEntries, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	file, err := os.Open(r.path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return &entity.PaginatedAuditEntries{Total: 0, Entries: []entity.AuditEntry{}}, nil
+		}
+		return nil, err
+	}
+	defer file.Close()
+
+	var matched []entity.AuditEntry
+	skipped := 0
+	reader := bufio.NewReader(file)
+	for line := 1; ; line++ {
+		data, err := reader.ReadBytes('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return nil, err
+		}
+		data = bytes.TrimSpace(data)
+		if len(data) > 0 {
+			// Строку, оборванную сбоем посреди записи, пропускаем: остальной журнал читается
+			var entry entity.AuditEntry
+			if len(data) > maxAuditLineSize {
+				log.Printf("Skipping audit log %s line %d: longer than %d bytes", r.path, line, maxAuditLineSize)
+				skipped++
+			} else if err := json.Unmarshal(data, &entry); err != nil {
+				log.Printf("Skipping audit log %s line %d: %v", r.path, line, err)
+				skipped++
+			} else if matchAuditEntry(entry, filter) {
+				matched = append(matched, entry)
+			}
+		}
+		if err != nil {
+			break
+		}
+	}
+
+	// В файле записи идут по возрастанию времени
+	for i, j := 0, len(matched)-1; i < j; i, j = i+1, j-1 {
+		matched[i], matched[j] = matched[j], matched[i]
+	}
+
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 {
+		limit = 50
+	}
+
+	total := len(matched)
+	start := (page - 1) * limit
+	if start > total {
+		start = total
+	}
+	end := start + limit
+	if end > total {
+		end = total
+	}
+
+	return &entity.PaginatedAuditEntries{
+		Total:   total,
+		Entries: append([]entity.AuditEntry{}, matched[start:end]...),
+		Skipped: skipped,
+	}, nil
+}
+
+func matchAuditEntry(entry entity.AuditEntry, filter entity.AuditFilter) bool {
+	if filter.Actor != "" && entry.Actor != filter.Actor {
+		return false
+	}
+	if filter.Action != "" && entry.Action != filter.Action {
+		return false
+	}
+	if filter.Client != "" && entry.ClientID != filter.Client && entry.ClientName != filter.Client {
+		return false
+	}
+	if filter.Outcome != "" && entry.Outcome != filter.Outcome {
+		return false
+	}
+	if !filter.From.IsZero() && entry.Time.Before(filter.From) {
+		return false
+	}
+	if !filter.To.IsZero() && !entry.Time.Before(filter.To) {
+		return false
+	}
+	return true
+}
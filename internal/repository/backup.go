@@ -0,0 +1,264 @@
+package repository
+
+import (
+	"antizapret-admin-panel/internal/entity"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ErrBackupNotFound возвращается для архива, которого нет в директории резервных копий.
+var ErrBackupNotFound = errors.New("backup not found")
+
+// Имя архива в директории резервных копий: backup-<хост>-<время создания>.tar.gz
+var backupNameRegex = regexp.MustCompile(`^backup-[A-Za-z0-9._:-]+\.tar\.gz$`)
+
+// Символы хоста, которые не допускает backupNameRegex
+var backupHostUnsafe = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// Формат времени в имени архива
+const backupTimeLayout = "20060102-150405"
+
+// Префиксы имен архивов, созданных не POST /api/backups
+const (
+	backupUploadPrefix     = "backup-upload"
+	backupPreRestorePrefix = "backup-pre-restore"
+)
+
+// BackupRepository создает архивы резервных копий в формате client.sh backup() с manifest.json,
+// хранит их в отдельной директории и восстанавливает файлы из них.
+type BackupRepository interface {
+	// Create собирает архив из текущих файлов сервера, ход работы пишется в output.
+	// kind — entity.BackupKindCreated или entity.BackupKindPreRestore, от него зависит имя архива.
+	Create(ctx context.Context, kind string, output io.Writer) (*entity.Backup, error)
+	// Save проверяет загруженный архив и сохраняет его рядом с остальными
+	Save(r io.Reader) (*entity.Backup, *BackupArchive, error)
+	// FindAll возвращает архивы, новые первыми
+	FindAll() ([]entity.Backup, error)
+	// FindPath возвращает путь к архиву для скачивания
+	FindPath(name string) (string, error)
+	Delete(name string) error
+	// Open читает и проверяет архив. Неверный архив — ErrInvalidBackup.
+	Open(name string) (*BackupArchive, error)
+	// Diff сравнивает архив с файлами сервера, ничего не меняя
+	Diff(archive *BackupArchive) (*entity.RestorePreview, error)
+	// Restore записывает файлы архива на место и возвращает, что изменилось
+	Restore(archive *BackupArchive, output io.Writer) (*entity.RestorePreview, error)
+}
+
+// BackupPaths — директория архивов и расположение файлов, которые попадают в резервную копию.
+type BackupPaths struct {
+	Backups          string // директория архивов
+	EasyRSA          string // /etc/openvpn/easyrsa3 целиком
+	WireGuard        string // antizapret.conf, vpn.conf и key
+	AntiZapretConfig string // списки маршрутизации *.txt
+	KnotResolver     string // *.lua
+	AntiZapretRoot   string // пользовательские скрипты custom*.sh
+}
+
+type fileBackupRepository struct {
+	paths BackupPaths
+}
+
+// NewBackupRepository — конструктор.
+func NewBackupRepository(paths BackupPaths) BackupRepository {
+	return &fileBackupRepository{paths: paths}
+}
+
+func (r *fileBackupRepository) Create(ctx context.Context, kind string, output io.Writer) (*entity.Backup, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(r.paths.Backups, 0o700); err != nil {
+		return nil, err
+	}
+
+	tmp, err := os.CreateTemp(r.paths.Backups, ".backup-*.tmp")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmp.Name())
+
+	// В архиве закрытые ключи PKI и WireGuard
+	if err := tmp.Chmod(0o600); err != nil {
+		tmp.Close()
+		return nil, err
+	}
+	if err := writeBackupArchive(tmp, r.sections(), output); err != nil {
+		tmp.Close()
+		return nil, err
+	}
+	if err := tmp.Close(); err != nil {
+		return nil, err
+	}
+
+	hostname, _ := os.Hostname()
+	host := strings.Trim(backupHostUnsafe.ReplaceAllString(hostname, "-"), "-")
+	if host == "" {
+		host = "antizapret"
+	}
+	prefix := "backup-" + host
+	if kind == entity.BackupKindPreRestore {
+		prefix = backupPreRestorePrefix + "-" + host
+	}
+	backup, err := r.publish(tmp.Name(), prefix)
+	if err != nil {
+		return nil, err
+	}
+	fmt.Fprintf(output, "Backup %s created (%d bytes)\n", backup.Name, backup.Size)
+	return backup, nil
+}
+
+func (r *fileBackupRepository) Save(reader io.Reader) (*entity.Backup, *BackupArchive, error) {
+	if err := os.MkdirAll(r.paths.Backups, 0o700); err != nil {
+		return nil, nil, err
+	}
+
+	tmp, err := os.CreateTemp(r.paths.Backups, ".upload-*.tmp")
+	if err != nil {
+		return nil, nil, err
+	}
+	defer os.Remove(tmp.Name())
+
+	// Архив проверяется по мере записи на диск: сохраняется ровно то, что загрузили
+	archive, err := readBackupArchive(io.TeeReader(reader, tmp), r.sections())
+	if err == nil {
+		// Остаток потока после конца tar тоже должен попасть в файл
+		_, err = io.Copy(tmp, reader)
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := os.Chmod(tmp.Name(), 0o600); err != nil {
+		return nil, nil, err
+	}
+
+	backup, err := r.publish(tmp.Name(), backupUploadPrefix)
+	if err != nil {
+		return nil, nil, err
+	}
+	archive.Name = backup.Name
+	return backup, archive, nil
+}
+
+// publish дает готовому временному файлу имя <prefix>-<время>.tar.gz, не перезаписывая существующие архивы.
+func (r *fileBackupRepository) publish(tmpPath, prefix string) (*entity.Backup, error) {
+	base := prefix + "-" + time.Now().Format(backupTimeLayout)
+	for i := 1; i <= 100; i++ {
+		name := base + ".tar.gz"
+		if i > 1 {
+			name = fmt.Sprintf("%s-%d.tar.gz", base, i)
+		}
+		target := filepath.Join(r.paths.Backups, name)
+		// Link, в отличие от Rename, не перезаписывает существующий файл
+		err := os.Link(tmpPath, target)
+		if errors.Is(err, os.ErrExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		info, err := os.Stat(target)
+		if err != nil {
+			return nil, err
+		}
+		return &entity.Backup{Name: name, Kind: backupKind(name), Size: info.Size(), CreatedAt: info.ModTime()}, nil
+	}
+	return nil, fmt.Errorf("failed to pick a unique name for %s", base)
+}
+
+func (r *fileBackupRepository) FindAll() ([]entity.Backup, error) {
+	files, err := os.ReadDir(r.paths.Backups)
+	if errors.Is(err, os.ErrNotExist) {
+		return []entity.Backup{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	backups := []entity.Backup{}
+	for _, file := range files {
+		if !file.Type().IsRegular() || !backupNameRegex.MatchString(file.Name()) {
+			continue
+		}
+		info, err := file.Info()
+		if err != nil {
+			continue
+		}
+		backups = append(backups, entity.Backup{Name: file.Name(), Kind: backupKind(file.Name()), Size: info.Size(), CreatedAt: info.ModTime()})
+	}
+
+	sort.Slice(backups, func(i, j int) bool {
+		return backups[i].CreatedAt.After(backups[j].CreatedAt)
+	})
+	return backups, nil
+}
+
+// backupKind определяет происхождение архива по префиксу имени
+func backupKind(name string) string {
+	switch {
+	case strings.HasPrefix(name, backupUploadPrefix+"-"):
+		return entity.BackupKindUpload
+	case strings.HasPrefix(name, backupPreRestorePrefix+"-"):
+		return entity.BackupKindPreRestore
+	default:
+		return entity.BackupKindCreated
+	}
+}
+
+func (r *fileBackupRepository) FindPath(name string) (string, error) {
+	// Имя приходит из URL: регулярка не пропускает разделители путей и ".."
+	if !backupNameRegex.MatchString(name) || strings.Contains(name, "..") {
+		return "", ErrBackupNotFound
+	}
+	path := filepath.Join(r.paths.Backups, name)
+	info, err := os.Stat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return "", ErrBackupNotFound
+	}
+	if err != nil {
+		return "", err
+	}
+	if !info.Mode().IsRegular() {
+		return "", ErrBackupNotFound
+	}
+	return path, nil
+}
+
+func (r *fileBackupRepository) Delete(name string) error {
+	path, err := r.FindPath(name)
+	if err != nil {
+		return err
+	}
+	return os.Remove(path)
+}
+
+func (r *fileBackupRepository) Open(name string) (*BackupArchive, error) {
+	path, err := r.FindPath(name)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	archive, err := readBackupArchive(file, r.sections())
+	if err != nil {
+		return nil, err
+	}
+	archive.Name = name
+	return archive, nil
+}
@@ -0,0 +1,573 @@
+package repository
+
+import (
+	"antizapret-admin-panel/internal/entity"
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ErrInvalidBackup возвращается для архива, который нельзя восстановить: поврежден,
+// не совпадают контрольные суммы или в нем есть пути вне известных каталогов.
+var ErrInvalidBackup = errors.New("invalid backup archive")
+
+// Архив читается в память целиком, поэтому его содержимое ограничено
+const (
+	maxBackupContentSize = 256 << 20
+	maxBackupEntries     = 100000
+)
+
+// Имя файла с описанием архива в его корне
+const backupManifestName = "manifest.json"
+
+// backupSection — каталог верхнего уровня архива и откуда на сервере берутся его файлы.
+// Тот же набор, что копирует client.sh backup().
+type backupSection struct {
+	dir  string
+	path string
+	// Шаблоны имен первого уровня в path; подходящий каталог берется целиком
+	patterns []string
+}
+
+func (r *fileBackupRepository) sections() []backupSection {
+	return []backupSection{
+		{dir: "easyrsa3", path: r.paths.EasyRSA, patterns: []string{"*"}},
+		{dir: "wireguard", path: r.paths.WireGuard, patterns: []string{"antizapret.conf", "vpn.conf", "key"}},
+		{dir: "config", path: r.paths.AntiZapretConfig, patterns: []string{"*.txt"}},
+		{dir: "knot-resolver", path: r.paths.KnotResolver, patterns: []string{"*.lua"}},
+		{dir: "custom", path: r.paths.AntiZapretRoot, patterns: []string{"custom*.sh"}},
+	}
+}
+
+// backupEntry — файл или каталог. У файлов сервера заполнен localPath, у прочитанных из архива — data.
+type backupEntry struct {
+	path      string
+	localPath string
+	dir       bool
+	mode      fs.FileMode
+	size      int64
+	modTime   time.Time
+	data      []byte
+}
+
+// BackupArchive — проверенный архив, прочитанный в память.
+type BackupArchive struct {
+	Name string
+	// nil для архива client.sh
+	Manifest *entity.BackupManifest
+	Warnings []string
+	entries  []backupEntry
+}
+
+// collectSection перечисляет файлы и каталоги раздела на сервере. Ссылки и специальные файлы
+// пропускаются с предупреждением: восстановить их из архива панель все равно не даст.
+func collectSection(section backupSection) ([]backupEntry, []string, error) {
+	var (
+		entries  []backupEntry
+		warnings []string
+	)
+	seen := make(map[string]bool)
+	for _, pattern := range section.patterns {
+		matches, err := filepath.Glob(filepath.Join(section.path, pattern))
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, match := range matches {
+			err := filepath.WalkDir(match, func(localPath string, d fs.DirEntry, err error) error {
+				if err != nil {
+					return err
+				}
+				rel, err := filepath.Rel(section.path, localPath)
+				if err != nil {
+					return err
+				}
+				archivePath := path.Join(section.dir, filepath.ToSlash(rel))
+				if seen[archivePath] {
+					return nil
+				}
+				seen[archivePath] = true
+
+				info, err := d.Info()
+				if err != nil {
+					return err
+				}
+				if !info.IsDir() && !info.Mode().IsRegular() {
+					warnings = append(warnings, fmt.Sprintf("%s skipped: not a regular file", localPath))
+					return nil
+				}
+				entries = append(entries, backupEntry{
+					path:      archivePath,
+					localPath: localPath,
+					dir:       info.IsDir(),
+					mode:      info.Mode().Perm(),
+					size:      info.Size(),
+					modTime:   info.ModTime(),
+				})
+				return nil
+			})
+			if err != nil {
+				return nil, nil, err
+			}
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].path < entries[j].path })
+	return entries, warnings, nil
+}
+
+// writeBackupArchive пишет tar.gz с каталогами разделов в корне, как client.sh backup(),
+// и manifest.json с контрольными суммами в конце.
+func writeBackupArchive(w io.Writer, sections []backupSection, output io.Writer) error {
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+
+	hostname, _ := os.Hostname()
+	manifest := entity.BackupManifest{
+		FormatVersion: entity.BackupFormatVersion,
+		CreatedAt:     time.Now(),
+		Hostname:      hostname,
+		PanelVersion:  panelVersion(),
+		GoVersion:     runtime.Version(),
+		Files:         []entity.BackupManifestFile{},
+	}
+
+	for _, section := range sections {
+		entries, warnings, err := collectSection(section)
+		if err != nil {
+			return err
+		}
+		for _, warning := range warnings {
+			fmt.Fprintln(output, "Warning: "+warning)
+		}
+
+		// Каталог раздела есть в архиве, даже если пуст, как у client.sh
+		if err := tw.WriteHeader(&tar.Header{Typeflag: tar.TypeDir, Name: section.dir + "/", Mode: 0o755, ModTime: manifest.CreatedAt}); err != nil {
+			return err
+		}
+
+		files := 0
+		for _, entry := range entries {
+			if entry.dir {
+				err = tw.WriteHeader(&tar.Header{Typeflag: tar.TypeDir, Name: entry.path + "/", Mode: int64(entry.mode), ModTime: entry.modTime})
+			} else {
+				var file *entity.BackupManifestFile
+				file, err = writeBackupFile(tw, entry)
+				if file != nil {
+					manifest.Files = append(manifest.Files, *file)
+					files++
+				}
+			}
+			if err != nil {
+				return fmt.Errorf("%s: %w", entry.localPath, err)
+			}
+		}
+		fmt.Fprintf(output, "%s: %d files from %s\n", section.dir, files, section.path)
+	}
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := tw.WriteHeader(&tar.Header{Typeflag: tar.TypeReg, Name: backupManifestName, Mode: 0o600, Size: int64(len(data)), ModTime: manifest.CreatedAt}); err != nil {
+		return err
+	}
+	if _, err := tw.Write(data); err != nil {
+		return err
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+// writeBackupFile копирует файл в архив, считая контрольную сумму.
+func writeBackupFile(tw *tar.Writer, entry backupEntry) (*entity.BackupManifestFile, error) {
+	file, err := os.Open(entry.localPath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	header := &tar.Header{Typeflag: tar.TypeReg, Name: entry.path, Mode: int64(entry.mode), Size: entry.size, ModTime: entry.modTime}
+	if err := tw.WriteHeader(header); err != nil {
+		return nil, err
+	}
+	hash := sha256.New()
+	// Ровно размер из заголовка: файл мог измениться после stat
+	if _, err := io.CopyN(tw, io.TeeReader(file, hash), entry.size); err != nil {
+		return nil, err
+	}
+	return &entity.BackupManifestFile{
+		Path:   entry.path,
+		Size:   entry.size,
+		Mode:   formatMode(entry.mode),
+		SHA256: hex.EncodeToString(hash.Sum(nil)),
+	}, nil
+}
+
+// readBackupArchive читает архив и проверяет его: только файлы и каталоги внутри известных разделов,
+// без абсолютных путей и "..", контрольные суммы — по manifest.json, если он есть.
+func readBackupArchive(r io.Reader, sections []backupSection) (*BackupArchive, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("%w: not a gzip file: %w", ErrInvalidBackup, err)
+	}
+	defer gz.Close()
+
+	known := make(map[string]backupSection, len(sections))
+	for _, section := range sections {
+		known[section.dir] = section
+	}
+
+	archive := &BackupArchive{Warnings: []string{}}
+	var (
+		manifestData []byte
+		total        int64
+	)
+	seen := make(map[string]bool)
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidBackup, err)
+		}
+
+		name := strings.TrimSuffix(strings.TrimPrefix(header.Name, "./"), "/")
+		if name == "" || name == "." {
+			continue
+		}
+		if path.IsAbs(name) || path.Clean(name) != name || name == ".." || strings.HasPrefix(name, "../") {
+			return nil, fmt.Errorf("%w: unsafe path %q", ErrInvalidBackup, header.Name)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("%w: duplicate entry %q", ErrInvalidBackup, name)
+		}
+		seen[name] = true
+		if len(seen) > maxBackupEntries {
+			return nil, fmt.Errorf("%w: more than %d entries", ErrInvalidBackup, maxBackupEntries)
+		}
+
+		isDir := header.Typeflag == tar.TypeDir
+		if !isDir && header.Typeflag != tar.TypeReg {
+			return nil, fmt.Errorf("%w: %q is not a regular file or directory", ErrInvalidBackup, name)
+		}
+
+		total += header.Size
+		if total > maxBackupContentSize {
+			return nil, fmt.Errorf("%w: content is larger than %d MB", ErrInvalidBackup, maxBackupContentSize>>20)
+		}
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidBackup, err)
+		}
+
+		if name == backupManifestName && !isDir {
+			manifestData = data
+			continue
+		}
+		if !allowedBackupPath(known, name) {
+			return nil, fmt.Errorf("%w: unexpected entry %q", ErrInvalidBackup, name)
+		}
+		archive.entries = append(archive.entries, backupEntry{
+			path:    name,
+			dir:     isDir,
+			mode:    fs.FileMode(header.Mode).Perm(),
+			size:    int64(len(data)),
+			modTime: header.ModTime,
+			data:    data,
+		})
+	}
+	// Дочитывание проверяет контрольную сумму gzip
+	if _, err := io.Copy(io.Discard, gz); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidBackup, err)
+	}
+
+	files, pkiFiles := 0, 0
+	for _, entry := range archive.entries {
+		if entry.dir {
+			continue
+		}
+		files++
+		if strings.HasPrefix(entry.path, "easyrsa3/") {
+			pkiFiles++
+		}
+	}
+	if files == 0 {
+		return nil, fmt.Errorf("%w: archive contains no files", ErrInvalidBackup)
+	}
+	if pkiFiles == 0 {
+		archive.Warnings = append(archive.Warnings, "archive has no easyrsa3 directory: OpenVPN PKI will not be restored")
+	}
+
+	if manifestData == nil {
+		archive.Warnings = append(archive.Warnings, "manifest.json is missing (archive created by client.sh?): checksums are not verified")
+		return archive, nil
+	}
+	if err := archive.verify(manifestData); err != nil {
+		return nil, err
+	}
+	return archive, nil
+}
+
+// allowedBackupPath проверяет, что путь лежит в известном разделе и подходит под его шаблоны.
+func allowedBackupPath(known map[string]backupSection, name string) bool {
+	parts := strings.SplitN(name, "/", 3)
+	section, ok := known[parts[0]]
+	if !ok {
+		return false
+	}
+	if len(parts) == 1 {
+		return true
+	}
+	for _, pattern := range section.patterns {
+		if matched, _ := path.Match(pattern, parts[1]); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// verify сверяет файлы архива с manifest.json: каждый файл описан, размеры и SHA-256 совпадают.
+func (a *BackupArchive) verify(data []byte) error {
+	var manifest entity.BackupManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return fmt.Errorf("%w: manifest.json: %v", ErrInvalidBackup, err)
+	}
+	if manifest.FormatVersion < 1 || manifest.FormatVersion > entity.BackupFormatVersion {
+		return fmt.Errorf("%w: unsupported format version %d", ErrInvalidBackup, manifest.FormatVersion)
+	}
+
+	listed := make(map[string]entity.BackupManifestFile, len(manifest.Files))
+	for _, file := range manifest.Files {
+		listed[file.Path] = file
+	}
+	for _, entry := range a.entries {
+		if entry.dir {
+			continue
+		}
+		file, ok := listed[entry.path]
+		if !ok {
+			return fmt.Errorf("%w: %s is not listed in manifest.json", ErrInvalidBackup, entry.path)
+		}
+		sum := sha256.Sum256(entry.data)
+		if file.Size != entry.size || !strings.EqualFold(file.SHA256, hex.EncodeToString(sum[:])) {
+			return fmt.Errorf("%w: checksum mismatch for %s", ErrInvalidBackup, entry.path)
+		}
+		delete(listed, entry.path)
+	}
+	if len(listed) > 0 {
+		missing := make([]string, 0, len(listed))
+		for name := range listed {
+			missing = append(missing, name)
+		}
+		sort.Strings(missing)
+		return fmt.Errorf("%w: %s is listed in manifest.json but missing", ErrInvalidBackup, missing[0])
+	}
+
+	a.Manifest = &manifest
+	return nil
+}
+
+func (r *fileBackupRepository) Diff(archive *BackupArchive) (*entity.RestorePreview, error) {
+	preview := &entity.RestorePreview{
+		Backup:   archive.Name,
+		Verified: archive.Manifest != nil,
+		Changes:  []entity.RestoreChange{},
+		Warnings: append([]string{}, archive.Warnings...),
+	}
+	if archive.Manifest != nil {
+		manifest := *archive.Manifest
+		manifest.Files = nil
+		preview.Manifest = &manifest
+	}
+
+	sections := r.sections()
+	inArchive := make(map[string]bool, len(archive.entries))
+	for _, entry := range archive.entries {
+		inArchive[entry.path] = true
+		if entry.dir {
+			continue
+		}
+		target := backupTarget(sections, entry.path)
+		action, err := restoreAction(target, entry)
+		if err != nil {
+			return nil, err
+		}
+		switch action {
+		case entity.RestoreActionCreate:
+			preview.Created++
+		case entity.RestoreActionUpdate:
+			preview.Updated++
+		case entity.RestoreActionUnchanged:
+			preview.Unchanged++
+			continue
+		}
+		preview.Changes = append(preview.Changes, entity.RestoreChange{Path: entry.path, Target: target, Action: action, Size: entry.size})
+	}
+
+	// Лишние файлы не удаляются: восстановление только создает и перезаписывает
+	for _, section := range sections {
+		local, _, err := collectSection(section)
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range local {
+			if entry.dir || inArchive[entry.path] {
+				continue
+			}
+			preview.Extra++
+			preview.Changes = append(preview.Changes, entity.RestoreChange{Path: entry.path, Target: entry.localPath, Action: entity.RestoreActionExtra, Size: entry.size})
+		}
+	}
+	return preview, nil
+}
+
+func (r *fileBackupRepository) Restore(archive *BackupArchive, output io.Writer) (*entity.RestorePreview, error) {
+	preview, err := r.Diff(archive)
+	if err != nil {
+		return nil, err
+	}
+
+	sections := r.sections()
+	for _, entry := range archive.entries {
+		target := backupTarget(sections, entry.path)
+		if entry.dir {
+			if _, err := os.Stat(target); errors.Is(err, os.ErrNotExist) {
+				if err := os.MkdirAll(target, entry.mode|0o700); err != nil {
+					return nil, err
+				}
+			}
+			continue
+		}
+
+		action, err := restoreAction(target, entry)
+		if err != nil {
+			return nil, err
+		}
+		if action == entity.RestoreActionUnchanged {
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(target), 0o700); err != nil {
+			return nil, err
+		}
+		if err := writeFileAtomic(target, entry.data, entry.mode, entry.modTime); err != nil {
+			return nil, fmt.Errorf("%s: %w", target, err)
+		}
+		fmt.Fprintf(output, "%s %s\n", action, target)
+	}
+	return preview, nil
+}
+
+// backupTarget возвращает путь на сервере для пути внутри архива.
+func backupTarget(sections []backupSection, name string) string {
+	dir, rel, _ := strings.Cut(name, "/")
+	for _, section := range sections {
+		if section.dir == dir {
+			return filepath.Join(section.path, filepath.FromSlash(rel))
+		}
+	}
+	return ""
+}
+
+// restoreAction сравнивает файл архива с файлом на сервере по содержимому и правам.
+func restoreAction(target string, entry backupEntry) (string, error) {
+	info, err := os.Stat(target)
+	if errors.Is(err, os.ErrNotExist) {
+		return entity.RestoreActionCreate, nil
+	}
+	if err != nil {
+		return "", err
+	}
+	if !info.Mode().IsRegular() {
+		return "", fmt.Errorf("%w: %s exists and is not a regular file", ErrInvalidBackup, target)
+	}
+	if info.Size() != entry.size || info.Mode().Perm() != entry.mode {
+		return entity.RestoreActionUpdate, nil
+	}
+	current, err := os.ReadFile(target)
+	if err != nil {
+		return "", err
+	}
+	if !bytes.Equal(current, entry.data) {
+		return entity.RestoreActionUpdate, nil
+	}
+	return entity.RestoreActionUnchanged, nil
+}
+
+// writeFileAtomic заменяет файл через временный в той же директории.
+func writeFileAtomic(target string, data []byte, mode fs.FileMode, modTime time.Time) error {
+	tmp, err := os.CreateTemp(filepath.Dir(target), "."+filepath.Base(target)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(mode); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if !modTime.IsZero() {
+		os.Chtimes(tmp.Name(), modTime, modTime)
+	}
+	return os.Rename(tmp.Name(), target)
+}
+
+func formatMode(mode fs.FileMode) string {
+	return fmt.Sprintf("%04o", uint32(mode.Perm()))
+}
+
+// panelVersion возвращает версию панели из сведений о сборке: тег модуля или ревизию git.
+func panelVersion() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "unknown"
+	}
+	if info.Main.Version != "" && info.Main.Version != "(devel)" {
+		return info.Main.Version
+	}
+	var revision string
+	dirty := false
+	for _, setting := range info.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			revision = setting.Value
+		case "vcs.modified":
+			dirty = setting.Value == "true"
+		}
+	}
+	if revision == "" {
+		return "devel"
+	}
+	if len(revision) > 12 {
+		revision = revision[:12]
+	}
+	if dirty {
+		revision += "-dirty"
+	}
+	return "devel+" + revision
+}
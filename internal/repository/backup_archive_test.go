@@ -0,0 +1,346 @@
+package repository
+
+import (
+	"antizapret-admin-panel/internal/entity"
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestBackupRepository копирует нужные части mock_fs во временную директорию
+// и возвращает репозиторий, который собирает и восстанавливает архивы в ней.
+func newTestBackupRepository(t *testing.T) (*fileBackupRepository, BackupPaths) {
+	t.Helper()
+	root := t.TempDir()
+	for _, dir := range []string{"etc/openvpn/easyrsa3", "etc/wireguard", "root/antizapret/config"} {
+		copyTestDir(t, filepath.Join("..", "..", "mock_fs", dir), filepath.Join(root, dir))
+	}
+	paths := BackupPaths{
+		Backups:          filepath.Join(root, "backups"),
+		EasyRSA:          filepath.Join(root, "etc/openvpn/easyrsa3"),
+		WireGuard:        filepath.Join(root, "etc/wireguard"),
+		AntiZapretConfig: filepath.Join(root, "root/antizapret/config"),
+		KnotResolver:     filepath.Join(root, "etc/knot-resolver"),
+		AntiZapretRoot:   filepath.Join(root, "root/antizapret"),
+	}
+	writeTestFile(t, filepath.Join(paths.KnotResolver, "kresd.conf.lua"), "-- kresd\n")
+	writeTestFile(t, filepath.Join(paths.AntiZapretRoot, "custom-up.sh"), "#!/bin/sh\n")
+	return &fileBackupRepository{paths: paths}, paths
+}
+
+func copyTestDir(t *testing.T, src, dst string) {
+	t.Helper()
+	err := filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		if d.IsDir() {
+			return os.MkdirAll(target, 0o755)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(target, data, 0o644)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func writeTestFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func readTestFile(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestBackupRoundTrip(t *testing.T) {
+	repo, paths := newTestBackupRepository(t)
+	includeHosts := filepath.Join(paths.AntiZapretConfig, "include-hosts.txt")
+	vpnConf := filepath.Join(paths.WireGuard, "vpn.conf")
+	originalHosts := readTestFile(t, includeHosts)
+	originalVPN := readTestFile(t, vpnConf)
+
+	var output bytes.Buffer
+	backup, err := repo.Create(context.Background(), entity.BackupKindCreated, &output)
+	if err != nil {
+		t.Fatalf("Create: %v\n%s", err, output.String())
+	}
+	if backup.Kind != entity.BackupKindCreated || !strings.HasPrefix(backup.Name, "backup-") {
+		t.Errorf("unexpected backup: %+v", backup)
+	}
+	info, err := os.Stat(filepath.Join(paths.Backups, backup.Name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0o600 {
+		t.Errorf("archive mode = %v, want 0600", info.Mode().Perm())
+	}
+
+	archive, err := repo.Open(backup.Name)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	if archive.Manifest == nil || archive.Manifest.FormatVersion != entity.BackupFormatVersion {
+		t.Fatalf("manifest = %+v, want format version %d", archive.Manifest, entity.BackupFormatVersion)
+	}
+	// PKI easyrsa, два конфига WireGuard, три списка, kresd.conf.lua и custom-up.sh
+	files := len(archive.Manifest.Files)
+	listed := make(map[string]bool, files)
+	for _, file := range archive.Manifest.Files {
+		listed[file.Path] = true
+	}
+	for _, path := range []string{"easyrsa3/pki/index.txt", "wireguard/vpn.conf", "config/include-hosts.txt", "knot-resolver/kresd.conf.lua", "custom/custom-up.sh"} {
+		if !listed[path] {
+			t.Errorf("%s is not in manifest.json", path)
+		}
+	}
+
+	preview, err := repo.Diff(archive)
+	if err != nil {
+		t.Fatalf("Diff: %v", err)
+	}
+	if !preview.Verified || preview.Created+preview.Updated+preview.Extra != 0 || preview.Unchanged != files {
+		t.Errorf("diff against the same files: %+v", preview)
+	}
+
+	writeTestFile(t, includeHosts, "changed.example\n")
+	if err := os.Remove(vpnConf); err != nil {
+		t.Fatal(err)
+	}
+	extra := filepath.Join(paths.AntiZapretConfig, "extra.txt")
+	writeTestFile(t, extra, "extra.example\n")
+
+	preview, err = repo.Diff(archive)
+	if err != nil {
+		t.Fatalf("Diff: %v", err)
+	}
+	if preview.Created != 1 || preview.Updated != 1 || preview.Extra != 1 || preview.Unchanged != files-2 {
+		t.Errorf("diff after changes: %+v", preview)
+	}
+	actions := make(map[string]string)
+	for _, change := range preview.Changes {
+		actions[change.Path] = change.Action
+	}
+	want := map[string]string{
+		"wireguard/vpn.conf":       entity.RestoreActionCreate,
+		"config/include-hosts.txt": entity.RestoreActionUpdate,
+		"config/extra.txt":         entity.RestoreActionExtra,
+	}
+	for path, action := range want {
+		if actions[path] != action {
+			t.Errorf("%s: action %q, want %q", path, actions[path], action)
+		}
+	}
+
+	output.Reset()
+	if _, err := repo.Restore(archive, &output); err != nil {
+		t.Fatalf("Restore: %v", err)
+	}
+	if got := readTestFile(t, includeHosts); got != originalHosts {
+		t.Errorf("include-hosts.txt = %q, want %q", got, originalHosts)
+	}
+	if got := readTestFile(t, vpnConf); got != originalVPN {
+		t.Errorf("vpn.conf = %q, want %q", got, originalVPN)
+	}
+	// Лишние файлы восстановление не удаляет
+	if _, err := os.Stat(extra); err != nil {
+		t.Errorf("extra file: %v", err)
+	}
+
+	preview, err = repo.Diff(archive)
+	if err != nil {
+		t.Fatalf("Diff: %v", err)
+	}
+	if preview.Created != 0 || preview.Updated != 0 || preview.Unchanged != files {
+		t.Errorf("diff after restore: %+v", preview)
+	}
+}
+
+// testArchiveEntry — запись tar для собранных вручную архивов
+type testArchiveEntry struct {
+	name     string
+	content  string
+	typeflag byte
+	linkname string
+}
+
+func testFile(name, content string) testArchiveEntry {
+	return testArchiveEntry{name: name, content: content, typeflag: tar.TypeReg}
+}
+
+// buildTestArchive собирает tar.gz из записей и, если manifest не nil, добавляет manifest.json
+func buildTestArchive(t *testing.T, entries []testArchiveEntry, manifest *entity.BackupManifest) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	write := func(entry testArchiveEntry) {
+		header := &tar.Header{Typeflag: entry.typeflag, Name: entry.name, Linkname: entry.linkname, Mode: 0o644, ModTime: time.Now()}
+		if entry.typeflag == tar.TypeReg {
+			header.Size = int64(len(entry.content))
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := io.WriteString(tw, entry.content); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, entry := range entries {
+		write(entry)
+	}
+	if manifest != nil {
+		data, err := json.Marshal(manifest)
+		if err != nil {
+			t.Fatal(err)
+		}
+		write(testFile(backupManifestName, string(data)))
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// testManifest описывает файлы с их настоящими размерами и контрольными суммами
+func testManifest(files ...testArchiveEntry) *entity.BackupManifest {
+	manifest := &entity.BackupManifest{FormatVersion: entity.BackupFormatVersion, CreatedAt: time.Now(), Files: []entity.BackupManifestFile{}}
+	for _, file := range files {
+		sum := sha256.Sum256([]byte(file.content))
+		manifest.Files = append(manifest.Files, entity.BackupManifestFile{
+			Path:   file.name,
+			Size:   int64(len(file.content)),
+			Mode:   "0644",
+			SHA256: hex.EncodeToString(sum[:]),
+		})
+	}
+	return manifest
+}
+
+func TestReadBackupArchiveRejects(t *testing.T) {
+	repo, paths := newTestBackupRepository(t)
+	index := testFile("easyrsa3/pki/index.txt", "V\t350531120000Z\t\t01\tunknown\t/CN=ivan\n")
+	hosts := testFile("config/include-hosts.txt", "example.com\n")
+
+	tampered := testManifest(index, hosts)
+	tampered.Files[1].SHA256 = strings.Repeat("0", 64)
+
+	tests := []struct {
+		name string
+		data []byte
+		want string
+	}{
+		{"parent directory", buildTestArchive(t, []testArchiveEntry{index, testFile("config/../../etc/passwd.txt", "x")}, nil), "unsafe path"},
+		{"leading dotdot", buildTestArchive(t, []testArchiveEntry{index, testFile("../passwd", "x")}, nil), "unsafe path"},
+		{"absolute path", buildTestArchive(t, []testArchiveEntry{index, testFile("/etc/passwd", "x")}, nil), "unsafe path"},
+		{"symlink", buildTestArchive(t, []testArchiveEntry{index, {name: "config/include-ips.txt", typeflag: tar.TypeSymlink, linkname: "/etc/shadow"}}, nil), "not a regular file"},
+		{"hard link", buildTestArchive(t, []testArchiveEntry{index, {name: "config/include-ips.txt", typeflag: tar.TypeLink, linkname: "easyrsa3/pki/index.txt"}}, nil), "not a regular file"},
+		{"duplicate", buildTestArchive(t, []testArchiveEntry{index, hosts, hosts}, nil), "duplicate entry"},
+		{"unknown section", buildTestArchive(t, []testArchiveEntry{index, testFile("etc/passwd", "x")}, nil), "unexpected entry"},
+		{"pattern mismatch", buildTestArchive(t, []testArchiveEntry{index, testFile("config/run.sh", "x")}, nil), "unexpected entry"},
+		{"checksum mismatch", buildTestArchive(t, []testArchiveEntry{index, hosts}, tampered), "checksum mismatch"},
+		{"unlisted file", buildTestArchive(t, []testArchiveEntry{index, hosts}, testManifest(index)), "not listed in manifest.json"},
+		{"missing file", buildTestArchive(t, []testArchiveEntry{index}, testManifest(index, hosts)), "listed in manifest.json but missing"},
+		{"future format", buildTestArchive(t, []testArchiveEntry{index}, &entity.BackupManifest{FormatVersion: entity.BackupFormatVersion + 1}), "unsupported format version"},
+		{"no files", buildTestArchive(t, []testArchiveEntry{{name: "config/", typeflag: tar.TypeDir}}, nil), "no files"},
+		{"not gzip", []byte("not an archive"), "not a gzip file"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := readBackupArchive(bytes.NewReader(tt.data), repo.sections())
+			if !errors.Is(err, ErrInvalidBackup) || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("err = %v, want ErrInvalidBackup with %q", err, tt.want)
+			}
+
+			// Отклоненная загрузка не остается в директории архивов
+			if _, _, err := repo.Save(bytes.NewReader(tt.data)); !errors.Is(err, ErrInvalidBackup) {
+				t.Fatalf("Save err = %v, want ErrInvalidBackup", err)
+			}
+			files, _ := os.ReadDir(paths.Backups)
+			if len(files) != 0 {
+				t.Errorf("backups directory is not empty: %v", files)
+			}
+		})
+	}
+}
+
+func TestClientScriptBackupWithoutManifest(t *testing.T) {
+	repo, paths := newTestBackupRepository(t)
+	// Так архив собирает client.sh backup(): каталоги разделов в корне, без manifest.json
+	entries := []testArchiveEntry{
+		{name: "easyrsa3/", typeflag: tar.TypeDir},
+		{name: "easyrsa3/pki/", typeflag: tar.TypeDir},
+		testFile("easyrsa3/pki/index.txt", readTestFile(t, filepath.Join(paths.EasyRSA, "pki/index.txt"))),
+		{name: "wireguard/", typeflag: tar.TypeDir},
+		testFile("wireguard/vpn.conf", "[Interface]\n"),
+		{name: "config/", typeflag: tar.TypeDir},
+		{name: "knot-resolver/", typeflag: tar.TypeDir},
+		{name: "custom/", typeflag: tar.TypeDir},
+	}
+
+	backup, archive, err := repo.Save(bytes.NewReader(buildTestArchive(t, entries, nil)))
+	if err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	if backup.Kind != entity.BackupKindUpload {
+		t.Errorf("kind = %q, want %q", backup.Kind, entity.BackupKindUpload)
+	}
+	if archive.Manifest != nil {
+		t.Errorf("manifest = %+v, want nil", archive.Manifest)
+	}
+
+	preview, err := repo.Diff(archive)
+	if err != nil {
+		t.Fatalf("Diff: %v", err)
+	}
+	if preview.Verified {
+		t.Error("archive without manifest.json is reported as verified")
+	}
+	if len(preview.Warnings) == 0 || !strings.Contains(preview.Warnings[0], "manifest.json is missing") {
+		t.Errorf("warnings = %q, want missing manifest warning", preview.Warnings)
+	}
+	if preview.Unchanged != 1 || preview.Updated != 1 {
+		t.Errorf("unexpected preview: %+v", preview)
+	}
+
+	if _, err := repo.Restore(archive, io.Discard); err != nil {
+		t.Fatalf("Restore: %v", err)
+	}
+	if got := readTestFile(t, filepath.Join(paths.WireGuard, "vpn.conf")); got != "[Interface]\n" {
+		t.Errorf("vpn.conf = %q after restore", got)
+	}
+}
@@ -2,39 +2,94 @@ package repository
 
 import (
 	"antizapret-admin-panel/internal/entity"
+	"bufio"
+	"context"
 	"errors"
 	"fmt"
 	"log"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"regexp"
 	"sort"
 	"strconv"
+	"strings"
+	"time"
 )
 
+// ErrClientNotFound возвращается, когда клиент с указанным ID или именем не найден
+var ErrClientNotFound = errors.New("client not found")
+
+// ErrConfigNotFound возвращается, когда файла профиля клиента нет на диске
+var ErrConfigNotFound = errors.New("config file not found")
+
 // ClientRepository — контракт
 type ClientRepository interface {
 	FindAll() ([]entity.Client, error)
 	FindAllPaginated(page, limit int) (*entity.PaginatedClients, error)
+	FindByID(id string) (*entity.Client, error)
+	FindByName(name, clientType string) (*entity.Client, error)
 	FindConfigPathByName(name string) (string, error)
 	FindConfigPathByNameAndType(name, configType string) (string, error)
-	Create(name string, expiresIn int) error
-	DeleteByName(name string) error
+	FindWireGuardConfigPath(name, configType, flavor string) (string, error)
+	FindOpenVPNConfigPath(name, variant string) (string, error)
+	FindProfiles(name string) ([]entity.ClientProfile, error)
+	// Методы, запускающие client.sh, возвращают его вывод, в том числе при ошибке
+	Create(ctx context.Context, name, clientType string, expiresIn int) (string, error)
+	Renew(ctx context.Context, name string, expiresIn int) (string, error)
+	DeleteByName(ctx context.Context, name, clientType string) (string, error)
+}
+
+// ClientPaths — расположение файлов, с которыми работает репозиторий клиентов.
+type ClientPaths struct {
+	OpenVPNClients    string // профили OpenVPN, по которым строится список клиентов
+	OpenVPNAntizapret string // профили OpenVPN Антизапрет
+	OpenVPNStatus     string // директория *-status.log
+	OpenVPNPKI        string // PKI easyrsa
+	WireGuardConfig   string // директория antizapret.conf и vpn.conf
+	ClientProfiles    string // корень профилей client.sh: openvpn/, wireguard/, amneziawg/
+	Metadata          string // clients.json с метаданными панели
 }
 
 // NewClientRepository — конструктор
-func NewClientRepository(openvpnClientsPath string, openvpnAntizapretPath string, clientScriptPath string) ClientRepository {
+func NewClientRepository(paths ClientPaths, scripts ScriptRunner) ClientRepository {
 	return &fileClientRepository{
-		openvpnClientsPath:    openvpnClientsPath,
-		openvpnAntizapretPath: openvpnAntizapretPath,
-		clientScriptPath:      clientScriptPath,
+		openvpnClientsPath:    paths.OpenVPNClients,
+		openvpnAntizapretPath: paths.OpenVPNAntizapret,
+		openvpnStatusPath:     paths.OpenVPNStatus,
+		openvpnPKIPath:        paths.OpenVPNPKI,
+		wireguardConfigPath:   paths.WireGuardConfig,
+		clientProfilesPath:    paths.ClientProfiles,
+		scripts:               scripts,
+		metadata:              newClientMetadataStore(paths.Metadata),
 	}
 }
 
+// Опции client.sh
+const (
+	scriptOptionAddOpenVPN      = "1"
+	scriptOptionDeleteOpenVPN   = "2"
+	scriptOptionAddWireGuard    = "4"
+	scriptOptionDeleteWireGuard = "5"
+)
+
+// Конфиги WireGuard/AmneziaWG, в которых client.sh хранит пиров
+var wireguardConfigFiles = []string{"antizapret.conf", "vpn.conf"}
+
 // Глобальная регулярка
 var clientNameRegex = regexp.MustCompile(`^(?:vpn|antizapret)-(.+)-\(.*\)(?:-(?:udp|tcp))?\.ovpn$`)
 
+// Имя файла клиентского профиля WireGuard/AmneziaWG: antizapret-<имя>-(<хост>)-wg.conf
+var wireguardProfileRegex = regexp.MustCompile(`^(?:vpn|antizapret)-(.+)-\(.*\)-(?:wg|am)\.conf$`)
+
+// Суффиксы файлов профилей по вариантам
+var wireguardProfileSuffixes = map[string]string{
+	entity.WireGuardFlavorWireGuard: "-wg.conf",
+	entity.WireGuardFlavorAmneziaWG: "-am.conf",
+}
+
+// Заголовок блока пира, который client.sh пишет перед [Peer]
+var wireguardClientRegex = regexp.MustCompile(`^# Client = (.+)$`)
+
 // Хелпер для парсинга имени
 func getClientName(filename string) (string, bool) {
 	matches := clientNameRegex.FindStringSubmatch(filename)
@@ -48,16 +103,89 @@ func getClientName(filename string) (string, bool) {
 type fileClientRepository struct {
 	openvpnClientsPath    string
 	openvpnAntizapretPath string
-	clientScriptPath      string
+	openvpnStatusPath     string
+	openvpnPKIPath        string
+	wireguardConfigPath   string
+	clientProfilesPath    string
+	scripts               ScriptRunner
+	metadata              *clientMetadataStore
 }
 
 // FindAll читает все файлы
 func (r *fileClientRepository) FindAll() ([]entity.Client, error) {
+	clients, err := r.findOpenVPNClients()
+	if err != nil {
+		return nil, err
+	}
+
+	wireguardClients, err := r.findWireGuardClients()
+	if err != nil {
+		return nil, err
+	}
+	clients = append(clients, wireguardClients...)
+
+	// Постоянные ID из хранилища метаданных
+	if err := r.metadata.assign(clients); err != nil {
+		return nil, err
+	}
+
+	// Сортировка (новые сверху)
+	sort.Slice(clients, func(i, j int) bool {
+		return clients[i].CreatedAt.After(clients[j].CreatedAt)
+	})
+
+	return clients, nil
+}
+
+// FindByID ищет клиента по постоянному ID
+func (r *fileClientRepository) FindByID(id string) (*entity.Client, error) {
+	clients, err := r.FindAll()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, client := range clients {
+		if client.ID == id {
+			return &client, nil
+		}
+	}
+
+	return nil, ErrClientNotFound
+}
+
+// FindByName ищет клиента по имени и типу
+func (r *fileClientRepository) FindByName(name, clientType string) (*entity.Client, error) {
+	clients, err := r.FindAll()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, client := range clients {
+		if client.Name == name && client.Type == clientType {
+			return &client, nil
+		}
+	}
+
+	return nil, ErrClientNotFound
+}
+
+// findOpenVPNClients собирает клиентов OpenVPN по файлам .ovpn
+func (r *fileClientRepository) findOpenVPNClients() ([]entity.Client, error) {
 	files, err := os.ReadDir(r.openvpnClientsPath)
 	if err != nil {
+		// Директории еще нет, пока не создан ни один клиент OpenVPN
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
 		return nil, err
 	}
 
+	sessions, err := readOpenVPNSessions(r.openvpnStatusPath)
+	if err != nil {
+		// Без статуса список клиентов всё равно полезен
+		log.Printf("failed to read OpenVPN status logs: %v", err)
+	}
+
 	var clients []entity.Client
 	for _, file := range files {
 		if file.IsDir() {
@@ -75,27 +203,203 @@ func (r *fileClientRepository) FindAll() ([]entity.Client, error) {
 			continue
 		}
 
-		clients = append(clients, entity.Client{
+		client := entity.Client{
 			Name:      clientName,
-			Type:      "OpenVPN",
-			Status:    "Active",
+			Type:      entity.ClientTypeOpenVPN,
+			Status:    entity.ClientStatusOffline,
 			CreatedAt: fileInfo.ModTime(),
-		})
+		}
+		applyOpenVPNSessions(&client, sessions)
+		r.applyCertificate(&client)
+		clients = append(clients, client)
 	}
 
-	// Сортировка (новые сверху)
-	sort.Slice(clients, func(i, j int) bool {
-		return clients[i].CreatedAt.After(clients[j].CreatedAt)
-	})
+	revoked, err := r.findRevokedOpenVPNClients(clients)
+	if err != nil {
+		log.Printf("failed to read PKI index: %v", err)
+	}
+	clients = append(clients, revoked...)
+
+	return clients, nil
+}
+
+// applyCertificate заполняет данные сертификата из pki/issued и помечает просроченных клиентов
+func (r *fileClientRepository) applyCertificate(client *entity.Client) {
+	cert, err := readIssuedCertificate(r.openvpnPKIPath, client.Name)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			log.Printf("failed to read certificate for %s: %v", client.Name, err)
+		}
+		return
+	}
+
+	client.Serial = cert.Serial
+	client.NotBefore = &cert.NotBefore
+	client.NotAfter = &cert.NotAfter
+
+	daysRemaining := int(time.Until(cert.NotAfter).Hours() / 24)
+	if daysRemaining < 0 {
+		daysRemaining = 0
+	}
+	client.DaysRemaining = &daysRemaining
+
+	if time.Now().After(cert.NotAfter) {
+		client.Status = entity.ClientStatusExpired
+	}
+}
+
+// findRevokedOpenVPNClients возвращает отозванных клиентов из pki/index.txt.
+// Клиент, у которого уже есть действующий сертификат с тем же именем, не дублируется,
+// а из нескольких отзывов одного имени берется последний.
+func (r *fileClientRepository) findRevokedOpenVPNClients(active []entity.Client) ([]entity.Client, error) {
+	entries, err := readPKIIndex(r.openvpnPKIPath)
+	if err != nil {
+		return nil, err
+	}
+
+	activeNames := make(map[string]bool, len(active))
+	for _, client := range active {
+		activeNames[client.Name] = true
+	}
+
+	latest := make(map[string]pkiIndexEntry)
+	var names []string
+	for _, entry := range entries {
+		if entry.Status != pkiIndexRevoked || entry.Name == "" || activeNames[entry.Name] {
+			continue
+		}
+		previous, ok := latest[entry.Name]
+		if !ok {
+			names = append(names, entry.Name)
+		}
+		if !ok || entry.RevokedAt.After(previous.RevokedAt) {
+			latest[entry.Name] = entry
+		}
+	}
+
+	var clients []entity.Client
+	for _, name := range names {
+		entry := latest[name]
+		notAfter := entry.NotAfter
+		revokedAt := entry.RevokedAt
+
+		client := entity.Client{
+			Name:      name,
+			Type:      entity.ClientTypeOpenVPN,
+			Status:    entity.ClientStatusRevoked,
+			CreatedAt: revokedAt,
+			Serial:    entry.Serial,
+			NotAfter:  &notAfter,
+			RevokedAt: &revokedAt,
+		}
+
+		// Отозванный сертификат easyrsa хранит по серийному номеру, из него берем дату выпуска
+		if cert, err := readRevokedCertificate(r.openvpnPKIPath, entry.Serial); err == nil {
+			client.NotBefore = &cert.NotBefore
+			client.CreatedAt = cert.NotBefore
+		}
 
-	// Простановка ID
-	for i := range clients {
-		clients[i].ID = i + 1
+		clients = append(clients, client)
 	}
 
 	return clients, nil
 }
 
+// applyOpenVPNSessions заполняет статус клиента по его подключениям.
+// Клиент может быть подключен к нескольким инстансам сразу (несколько устройств):
+// трафик суммируется, адреса берутся из самого свежего подключения.
+func applyOpenVPNSessions(client *entity.Client, sessions []openvpnSession) {
+	var latest *openvpnSession
+	for i := range sessions {
+		session := &sessions[i]
+		if session.CommonName != client.Name {
+			continue
+		}
+
+		client.BytesReceived += session.BytesReceived
+		client.BytesSent += session.BytesSent
+		if latest == nil || session.ConnectedSince.After(latest.ConnectedSince) {
+			latest = session
+		}
+	}
+
+	if latest == nil {
+		return
+	}
+
+	client.Status = entity.ClientStatusOnline
+	client.RealAddress = latest.RealAddress
+	client.VirtualAddress = latest.VirtualAddress
+	if !latest.ConnectedSince.IsZero() {
+		connectedSince := latest.ConnectedSince
+		client.ConnectedSince = &connectedSince
+	}
+}
+
+// findWireGuardClients собирает клиентов WireGuard/AmneziaWG по блокам "# Client = name"
+// в antizapret.conf и vpn.conf. Один и тот же клиент обычно есть в обоих файлах.
+func (r *fileClientRepository) findWireGuardClients() ([]entity.Client, error) {
+	seen := make(map[string]bool)
+	var clients []entity.Client
+
+	for _, fileName := range wireguardConfigFiles {
+		path := filepath.Join(r.wireguardConfigPath, fileName)
+
+		names, modTime, err := parseWireGuardClients(path)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			return nil, err
+		}
+
+		for _, name := range names {
+			if seen[name] {
+				continue
+			}
+			seen[name] = true
+
+			clients = append(clients, entity.Client{
+				Name:      name,
+				Type:      entity.ClientTypeWireGuard,
+				Status:    entity.ClientStatusActive,
+				CreatedAt: modTime,
+			})
+		}
+	}
+
+	return clients, nil
+}
+
+// parseWireGuardClients возвращает имена клиентов из конфига WireGuard и время его изменения
+func parseWireGuardClients(path string) ([]string, time.Time, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	defer file.Close()
+
+	fileInfo, err := file.Stat()
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+
+	var names []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		matches := wireguardClientRegex.FindStringSubmatch(scanner.Text())
+		if matches == nil {
+			continue
+		}
+		names = append(names, matches[1])
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, time.Time{}, err
+	}
+
+	return names, fileInfo.ModTime(), nil
+}
+
 func (r *fileClientRepository) FindAllPaginated(page, limit int) (*entity.PaginatedClients, error) {
 	allClients, err := r.FindAll()
 	if err != nil {
@@ -163,31 +467,144 @@ func (r *fileClientRepository) FindConfigPathByNameAndType(name, configType stri
 	return "", errors.New("config file not found for client: " + name)
 }
 
-// Create
-func (r *fileClientRepository) Create(name string, expiresIn int) error {
-	expiresInStr := strconv.Itoa(expiresIn)
-	if expiresIn <= 0 {
-		expiresInStr = "3650"
+// FindWireGuardConfigPath ищет клиентский профиль WireGuard или AmneziaWG.
+// client.sh раскладывает их по <flavor>/<configType>/ внутри clientProfilesPath.
+func (r *fileClientRepository) FindWireGuardConfigPath(name, configType, flavor string) (string, error) {
+	if _, ok := wireguardProfileSuffixes[flavor]; !ok {
+		return "", fmt.Errorf("unsupported WireGuard flavor: %s", flavor)
+	}
+	if configType != "antizapret" {
+		configType = "vpn"
+	}
+
+	return findProfileFile(filepath.Join(r.clientProfilesPath, flavor, configType), name, func(fileName string) (string, bool) {
+		return getWireGuardProfileName(fileName, flavor)
+	})
+}
+
+// FindOpenVPNConfigPath ищет профиль OpenVPN нужного варианта в openvpn/<variant>/ внутри clientProfilesPath.
+func (r *fileClientRepository) FindOpenVPNConfigPath(name, variant string) (string, error) {
+	if !entity.IsOpenVPNVariant(variant) {
+		return "", fmt.Errorf("unsupported OpenVPN variant: %s", variant)
+	}
+	return findProfileFile(filepath.Join(r.clientProfilesPath, "openvpn", variant), name, getClientName)
+}
+
+// FindProfiles собирает все профили клиента с таким именем: варианты OpenVPN и профили WireGuard/AmneziaWG.
+// Отсутствующие каталоги и файлы пропускаются.
+func (r *fileClientRepository) FindProfiles(name string) ([]entity.ClientProfile, error) {
+	var profiles []entity.ClientProfile
+	add := func(kind, variant, path string, err error) error {
+		if err == nil {
+			profiles = append(profiles, entity.ClientProfile{Kind: kind, Variant: variant, Path: path})
+			return nil
+		}
+		if errors.Is(err, ErrConfigNotFound) || errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+
+	for _, variant := range entity.OpenVPNVariants {
+		path, err := r.FindOpenVPNConfigPath(name, variant)
+		if err := add("openvpn", variant, path, err); err != nil {
+			return nil, err
+		}
+	}
+	for _, flavor := range []string{entity.WireGuardFlavorWireGuard, entity.WireGuardFlavorAmneziaWG} {
+		for _, configType := range []string{"antizapret", "vpn"} {
+			path, err := r.FindWireGuardConfigPath(name, configType, flavor)
+			if err := add(flavor, configType, path, err); err != nil {
+				return nil, err
+			}
+		}
 	}
 
-	cmd := exec.Command(r.clientScriptPath, "1", name, expiresInStr)
-	log.Printf("Running command: %s", cmd.String())
+	return profiles, nil
+}
 
-	output, err := cmd.CombinedOutput()
+// getWireGuardProfileName извлекает имя клиента из файла профиля WireGuard/AmneziaWG нужного варианта.
+func getWireGuardProfileName(fileName, flavor string) (string, bool) {
+	if !strings.HasSuffix(fileName, wireguardProfileSuffixes[flavor]) {
+		return "", false
+	}
+	matches := wireguardProfileRegex.FindStringSubmatch(fileName)
+	if matches == nil {
+		return "", false
+	}
+	return matches[1], true
+}
+
+// findProfileFile ищет в каталоге файл профиля клиента, имя которого извлекает parse.
+func findProfileFile(dir, name string, parse func(fileName string) (string, bool)) (string, error) {
+	files, err := os.ReadDir(dir)
 	if err != nil {
-		return fmt.Errorf("failed to create client: %w; output: %s", err, string(output))
+		return "", err
+	}
+
+	for _, file := range files {
+		if file.IsDir() {
+			continue
+		}
+
+		extractedName, ok := parse(file.Name())
+		if ok && extractedName == name {
+			return filepath.Join(dir, file.Name()), nil
+		}
+	}
+
+	return "", fmt.Errorf("%w for client: %s", ErrConfigNotFound, name)
+}
+
+// Create
+func (r *fileClientRepository) Create(ctx context.Context, name, clientType string, expiresIn int) (string, error) {
+	switch clientType {
+	case entity.ClientTypeOpenVPN:
+		expiresInStr := strconv.Itoa(expiresIn)
+		if expiresIn <= 0 {
+			expiresInStr = "3650"
+		}
+		return r.runScript(ctx, "failed to create client", scriptOptionAddOpenVPN, name, expiresInStr)
+	case entity.ClientTypeWireGuard:
+		// Срок действия у WireGuard/AmneziaWG не поддерживается скриптом
+		return r.runScript(ctx, "failed to create client", scriptOptionAddWireGuard, name)
+	default:
+		return "", fmt.Errorf("unsupported client type: %s", clientType)
 	}
-	return nil
+}
+
+// Renew перевыпускает сертификат OpenVPN с новым сроком действия.
+// client.sh делает это той же опцией, что и добавление, если клиент с таким именем уже есть.
+func (r *fileClientRepository) Renew(ctx context.Context, name string, expiresIn int) (string, error) {
+	return r.runScript(ctx, "failed to renew client certificate", scriptOptionAddOpenVPN, name, strconv.Itoa(expiresIn))
 }
 
 // DeleteByName
-func (r *fileClientRepository) DeleteByName(name string) error {
-	cmd := exec.Command(r.clientScriptPath, "2", name)
-	log.Printf("Running command: %s", cmd.String())
+func (r *fileClientRepository) DeleteByName(ctx context.Context, name, clientType string) (string, error) {
+	var (
+		output string
+		err    error
+	)
+	switch clientType {
+	case entity.ClientTypeOpenVPN:
+		output, err = r.runScript(ctx, "failed to delete client", scriptOptionDeleteOpenVPN, name)
+	case entity.ClientTypeWireGuard:
+		output, err = r.runScript(ctx, "failed to delete client", scriptOptionDeleteWireGuard, name)
+	default:
+		return "", fmt.Errorf("unsupported client type: %s", clientType)
+	}
+	if err != nil {
+		return output, err
+	}
+
+	return output, r.metadata.remove(name, clientType)
+}
 
-	output, err := cmd.CombinedOutput()
+// runScript запускает client.sh с указанными аргументами и возвращает его вывод
+func (r *fileClientRepository) runScript(ctx context.Context, errPrefix string, args ...string) (string, error) {
+	output, err := r.scripts.Run(ctx, args...)
 	if err != nil {
-		return fmt.Errorf("failed to delete client: %w; output: %s", err, string(output))
+		return output, fmt.Errorf("%s: %w; output: %s", errPrefix, err, output)
 	}
-	return nil
+	return output, nil
 }
@@ -0,0 +1,163 @@
+package repository
+
+import (
+	"antizapret-admin-panel/internal/entity"
+	"crypto/rand"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// clientMetadata — запись о клиенте, которую панель хранит сама.
+// client.sh ничего не знает об ID, поэтому постоянный идентификатор живет здесь.
+type clientMetadata struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Type      string    `json:"type"`
+	CreatedAt time.Time `json:"created_at"`
+	// Серийный номер отозванного сертификата. У отозванных записей свой ключ,
+	// чтобы новый клиент с тем же именем не унаследовал их ID.
+	RevokedSerial string `json:"revoked_serial,omitempty"`
+}
+
+// clientMetadataStore — небольшое JSON-хранилище метаданных клиентов.
+type clientMetadataStore struct {
+	path string
+	mu   sync.Mutex
+}
+
+func newClientMetadataStore(path string) *clientMetadataStore {
+	return &clientMetadataStore{path: path}
+}
+
+func clientMetadataKey(name, clientType string) string {
+	return clientType + "/" + name
+}
+
+// key возвращает ключ записи: имя для действующих клиентов, серийный номер для отозванных.
+func (m clientMetadata) key() string {
+	if m.RevokedSerial != "" {
+		return m.Type + "-revoked/" + m.RevokedSerial
+	}
+	return clientMetadataKey(m.Name, m.Type)
+}
+
+// revokedSerial возвращает серийный номер, по которому хранится запись отозванного клиента.
+func revokedSerial(client entity.Client) string {
+	if client.Status == entity.ClientStatusRevoked {
+		return client.Serial
+	}
+	return ""
+}
+
+// assign проставляет клиентам постоянные ID и дату создания из хранилища.
+// Файл перезаписывается, только если появились неизвестные клиенты: тогда же из него
+// выпадают записи о клиентах, которых больше нет. Чтение списка без новых клиентов
+// ничего не пишет и работает с директорией данных только для чтения.
+func (s *clientMetadataStore) assign(clients []entity.Client) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	records, err := s.load()
+	if err != nil {
+		return err
+	}
+
+	byKey := make(map[string]clientMetadata, len(records))
+	for _, record := range records {
+		byKey[record.key()] = record
+	}
+
+	changed := false
+	actual := make([]clientMetadata, 0, len(clients))
+	for i := range clients {
+		wanted := clientMetadata{Name: clients[i].Name, Type: clients[i].Type, RevokedSerial: revokedSerial(clients[i])}
+		key := wanted.key()
+		record, ok := byKey[key]
+		if !ok && wanted.RevokedSerial != "" {
+			// Раньше отозванные клиенты хранились по имени. Такая запись переходит к отозванному
+			// сертификату, чтобы его ID не менялся: действующего клиента с этим именем
+			// в списке нет, иначе отозванный был бы скрыт.
+			legacyKey := clientMetadataKey(clients[i].Name, clients[i].Type)
+			if record, ok = byKey[legacyKey]; ok {
+				delete(byKey, legacyKey)
+				record.RevokedSerial = wanted.RevokedSerial
+				changed = true
+			}
+		}
+		if !ok {
+			id, err := newClientID()
+			if err != nil {
+				return err
+			}
+			record = clientMetadata{
+				ID:            id,
+				Name:          clients[i].Name,
+				Type:          clients[i].Type,
+				CreatedAt:     clients[i].CreatedAt,
+				RevokedSerial: wanted.RevokedSerial,
+			}
+			changed = true
+		}
+		delete(byKey, key)
+
+		clients[i].ID = record.ID
+		clients[i].CreatedAt = record.CreatedAt
+		actual = append(actual, record)
+	}
+
+	// Записи, оставшиеся в byKey, относятся к клиентам, удаленным мимо панели.
+	// Ради них одних файл не переписывается.
+	if !changed {
+		return nil
+	}
+	return s.save(actual)
+}
+
+// remove удаляет запись о действующем клиенте, чтобы клиент с тем же именем получил новый ID.
+// Отозванный при удалении сертификат получит свою запись по серийному номеру при следующем чтении списка.
+func (s *clientMetadataStore) remove(name, clientType string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	records, err := s.load()
+	if err != nil {
+		return err
+	}
+
+	key := clientMetadataKey(name, clientType)
+	filtered := records[:0]
+	for _, record := range records {
+		if record.key() != key {
+			filtered = append(filtered, record)
+		}
+	}
+
+	if len(filtered) == len(records) {
+		return nil
+	}
+	return s.save(filtered)
+}
+
+func (s *clientMetadataStore) load() ([]clientMetadata, error) {
+	var records []clientMetadata
+	if err := readJSONFile(s.path, &records); err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+func (s *clientMetadataStore) save(records []clientMetadata) error {
+	return writeJSONFile(s.path, records)
+}
+
+// newClientID генерирует случайный UUID версии 4.
+func newClientID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:]), nil
+}
@@ -0,0 +1,81 @@
+package repository
+
+import (
+	"antizapret-admin-panel/internal/entity"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func activeOpenVPNClient(name string, createdAt time.Time) entity.Client {
+	return entity.Client{Name: name, Type: entity.ClientTypeOpenVPN, Status: entity.ClientStatusOffline, Serial: "AA01", CreatedAt: createdAt}
+}
+
+func revokedOpenVPNClient(name, serial string, createdAt time.Time) entity.Client {
+	return entity.Client{Name: name, Type: entity.ClientTypeOpenVPN, Status: entity.ClientStatusRevoked, Serial: serial, CreatedAt: createdAt}
+}
+
+func assignIDs(t *testing.T, store *clientMetadataStore, clients ...entity.Client) []entity.Client {
+	t.Helper()
+	if err := store.assign(clients); err != nil {
+		t.Fatalf("assign: %v", err)
+	}
+	return clients
+}
+
+// Отозванный сертификат хранится по серийному номеру: новый клиент с тем же именем
+// не получает ни его ID, ни дату создания
+func TestClientMetadataRevokedKeepsOwnID(t *testing.T) {
+	store := newClientMetadataStore(filepath.Join(t.TempDir(), "clients.json"))
+	issued := time.Date(2025, 1, 10, 0, 0, 0, 0, time.UTC)
+	recreated := time.Date(2026, 2, 8, 0, 0, 0, 0, time.UTC)
+
+	active := assignIDs(t, store, activeOpenVPNClient("ivan", issued))[0]
+
+	// Удаление через панель: запись действующего клиента удаляется, сертификат отозван
+	if err := store.remove("ivan", entity.ClientTypeOpenVPN); err != nil {
+		t.Fatal(err)
+	}
+	revoked := assignIDs(t, store, revokedOpenVPNClient("ivan", "BB02", issued))[0]
+	if revoked.ID == "" || revoked.ID == active.ID {
+		t.Fatalf("revoked ID = %q, active ID was %q", revoked.ID, active.ID)
+	}
+
+	// Новый клиент с тем же именем скрывает отозванный в списке
+	newcomer := assignIDs(t, store, activeOpenVPNClient("ivan", recreated))[0]
+	if newcomer.ID == revoked.ID || newcomer.ID == active.ID {
+		t.Errorf("new client inherited ID %q", newcomer.ID)
+	}
+	if !newcomer.CreatedAt.Equal(recreated) {
+		t.Errorf("new client CreatedAt = %v, want %v", newcomer.CreatedAt, recreated)
+	}
+
+	// После повторного чтения ID не меняются
+	again := assignIDs(t, store, activeOpenVPNClient("ivan", recreated), revokedOpenVPNClient("petr", "CC03", issued))
+	if again[0].ID != newcomer.ID {
+		t.Errorf("ID changed on reread: %q, want %q", again[0].ID, newcomer.ID)
+	}
+}
+
+// Запись, сохраненная по имени до появления ключей по серийному номеру, переходит
+// к отозванному сертификату, и его ID не меняется
+func TestClientMetadataLegacyRevokedRecord(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "clients.json")
+	issued := time.Date(2025, 1, 10, 0, 0, 0, 0, time.UTC)
+	legacy := `[{"id":"legacy-id","name":"ivan","type":"OpenVPN","created_at":"2025-01-10T00:00:00Z"}]`
+	if err := os.WriteFile(path, []byte(legacy), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	store := newClientMetadataStore(path)
+
+	revoked := assignIDs(t, store, revokedOpenVPNClient("ivan", "BB02", issued))[0]
+	if revoked.ID != "legacy-id" {
+		t.Fatalf("revoked ID = %q, want legacy-id", revoked.ID)
+	}
+
+	newcomer := assignIDs(t, store, activeOpenVPNClient("ivan", time.Now()))[0]
+	if newcomer.ID == "legacy-id" {
+		t.Error("new client inherited the legacy ID of the revoked certificate")
+	}
+}
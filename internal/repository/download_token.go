@@ -0,0 +1,214 @@
+package repository
+
+import (
+	"antizapret-admin-panel/internal/entity"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ErrTokenNotFound возвращается для неизвестного, просроченного или исчерпанного токена скачивания
+var ErrTokenNotFound = errors.New("download token not found")
+
+// TokenStore — контракт хранилища токенов для скачивания конфигов
+type TokenStore interface {
+	FindAll() ([]entity.DownloadToken, error)
+	Create(token entity.DownloadToken) error
+	// Use засчитывает одно скачивание по токену. Просроченный или исчерпанный токен удаляется.
+	Use(tokenHash string, now time.Time) (*entity.DownloadToken, error)
+	Delete(id string) error
+	// DeleteByClient удаляет все токены клиента и возвращает удаленные
+	DeleteByClient(clientID string) ([]entity.DownloadToken, error)
+	DeleteExpired(now time.Time) error
+}
+
+// downloadTokenRecord — запись в download_tokens.json
+type downloadTokenRecord struct {
+	ID         string    `json:"id"`
+	TokenHash  string    `json:"token_hash"`
+	FilePath   string    `json:"file_path"`
+	FileName   string    `json:"file_name"`
+	ClientID   string    `json:"client_id"`
+	ClientName string    `json:"client_name"`
+	ClientType string    `json:"client_type"`
+	CreatedBy  string    `json:"created_by"`
+	CreatedAt  time.Time `json:"created_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	MaxUses    int       `json:"max_uses"`
+	Uses       int       `json:"uses"`
+}
+
+// NewMemoryTokenStore — хранилище токенов в памяти. Токены пропадают при перезапуске.
+func NewMemoryTokenStore() TokenStore {
+	return &tokenStore{tokens: make(map[string]entity.DownloadToken)}
+}
+
+// NewFileTokenStore — хранилище токенов в памяти с сохранением в файл,
+// чтобы выданные ссылки переживали перезапуск сервиса.
+func NewFileTokenStore(path string) (TokenStore, error) {
+	var records []downloadTokenRecord
+	if err := readJSONFile(path, &records); err != nil {
+		return nil, err
+	}
+
+	s := &tokenStore{path: path, tokens: make(map[string]entity.DownloadToken, len(records))}
+	for _, record := range records {
+		s.tokens[record.ID] = record.toEntity()
+	}
+	return s, nil
+}
+
+// tokenStore реализует оба варианта: без path изменения в файл не пишутся
+type tokenStore struct {
+	path   string
+	mu     sync.Mutex
+	tokens map[string]entity.DownloadToken // ID -> токен
+}
+
+func (s *tokenStore) FindAll() ([]entity.DownloadToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tokens := make([]entity.DownloadToken, 0, len(s.tokens))
+	for _, token := range s.tokens {
+		tokens = append(tokens, token)
+	}
+	sort.Slice(tokens, func(i, j int) bool {
+		return tokens[i].CreatedAt.After(tokens[j].CreatedAt)
+	})
+	return tokens, nil
+}
+
+func (s *tokenStore) Create(token entity.DownloadToken) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.tokens[token.ID] = token
+	return s.save()
+}
+
+func (s *tokenStore) Use(tokenHash string, now time.Time) (*entity.DownloadToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id, token := range s.tokens {
+		if token.TokenHash != tokenHash {
+			continue
+		}
+
+		if !now.Before(token.ExpiresAt) || token.Uses >= token.MaxUses {
+			delete(s.tokens, id)
+			if err := s.save(); err != nil {
+				return nil, err
+			}
+			return nil, ErrTokenNotFound
+		}
+
+		token.Uses++
+		if token.Uses >= token.MaxUses {
+			delete(s.tokens, id)
+		} else {
+			s.tokens[id] = token
+		}
+		if err := s.save(); err != nil {
+			return nil, err
+		}
+		return &token, nil
+	}
+	return nil, ErrTokenNotFound
+}
+
+func (s *tokenStore) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.tokens[id]; !ok {
+		return ErrTokenNotFound
+	}
+	delete(s.tokens, id)
+	return s.save()
+}
+
+func (s *tokenStore) DeleteByClient(clientID string) ([]entity.DownloadToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var deleted []entity.DownloadToken
+	for id, token := range s.tokens {
+		if token.ClientID == clientID {
+			delete(s.tokens, id)
+			deleted = append(deleted, token)
+		}
+	}
+
+	if len(deleted) == 0 {
+		return nil, nil
+	}
+	return deleted, s.save()
+}
+
+func (s *tokenStore) DeleteExpired(now time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	changed := false
+	for id, token := range s.tokens {
+		if !now.Before(token.ExpiresAt) {
+			delete(s.tokens, id)
+			changed = true
+		}
+	}
+
+	if !changed {
+		return nil
+	}
+	return s.save()
+}
+
+// save вызывается под блокировкой
+func (s *tokenStore) save() error {
+	if s.path == "" {
+		return nil
+	}
+
+	records := make([]downloadTokenRecord, 0, len(s.tokens))
+	for _, token := range s.tokens {
+		records = append(records, newDownloadTokenRecord(token))
+	}
+	return writeJSONFile(s.path, records)
+}
+
+func newDownloadTokenRecord(token entity.DownloadToken) downloadTokenRecord {
+	return downloadTokenRecord{
+		ID:         token.ID,
+		TokenHash:  token.TokenHash,
+		FilePath:   token.FilePath,
+		FileName:   token.FileName,
+		ClientID:   token.ClientID,
+		ClientName: token.ClientName,
+		ClientType: token.ClientType,
+		CreatedBy:  token.CreatedBy,
+		CreatedAt:  token.CreatedAt,
+		ExpiresAt:  token.ExpiresAt,
+		MaxUses:    token.MaxUses,
+		Uses:       token.Uses,
+	}
+}
+
+func (r downloadTokenRecord) toEntity() entity.DownloadToken {
+	return entity.DownloadToken{
+		ID:         r.ID,
+		TokenHash:  r.TokenHash,
+		FilePath:   r.FilePath,
+		FileName:   r.FileName,
+		ClientID:   r.ClientID,
+		ClientName: r.ClientName,
+		ClientType: r.ClientType,
+		CreatedBy:  r.CreatedBy,
+		CreatedAt:  r.CreatedAt,
+		ExpiresAt:  r.ExpiresAt,
+		MaxUses:    r.MaxUses,
+		Uses:       r.Uses,
+	}
+}
@@ -0,0 +1,87 @@
+package repository
+
+import (
+	"antizapret-admin-panel/internal/entity"
+	"bufio"
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ErrHostListNotFound возвращается для имени, которого нет среди списков маршрутизации.
+var ErrHostListNotFound = errors.New("host list not found")
+
+// HostListRepository читает и пишет файлы списков маршрутизации AntiZapret (<name>.txt).
+// Файлы хранятся построчно как есть, вместе с комментариями; разбор строк — задача сервиса.
+type HostListRepository interface {
+	ReadLines(name string) ([]string, error)
+	WriteLines(name string, lines []string) error
+}
+
+type fileHostListRepository struct {
+	configPath string
+}
+
+// NewHostListRepository — конструктор. configPath — директория config/ AntiZapret.
+func NewHostListRepository(configPath string) HostListRepository {
+	return &fileHostListRepository{configPath: configPath}
+}
+
+func (r *fileHostListRepository) path(name string) (string, error) {
+	if _, ok := entity.HostListKinds[name]; !ok {
+		return "", ErrHostListNotFound
+	}
+	return filepath.Join(r.configPath, name+".txt"), nil
+}
+
+// ReadLines возвращает строки файла без завершающих переводов строк.
+// Отсутствующий файл считается пустым списком.
+func (r *fileHostListRepository) ReadLines(name string) ([]string, error) {
+	path, err := r.path(name)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var lines []string
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		lines = append(lines, strings.TrimRight(scanner.Text(), "\r"))
+	}
+	return lines, scanner.Err()
+}
+
+// WriteLines перезаписывает файл через временный, сохраняя права существующего файла.
+func (r *fileHostListRepository) WriteLines(name string, lines []string) error {
+	path, err := r.path(name)
+	if err != nil {
+		return err
+	}
+
+	mode := os.FileMode(0o644)
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode().Perm()
+	}
+
+	var buf bytes.Buffer
+	for _, line := range lines {
+		buf.WriteString(line)
+		buf.WriteByte('\n')
+	}
+
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, buf.Bytes(), mode); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, path)
+}
@@ -0,0 +1,192 @@
+package repository
+
+import (
+	"antizapret-admin-panel/internal/entity"
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// ErrManagementUnavailable возвращается, если ни один инстанс OpenVPN не отвечает на management-сокете.
+var ErrManagementUnavailable = errors.New("OpenVPN management interface is unavailable")
+
+// Сколько ждать ответа management-интерфейса одного инстанса
+const managementTimeout = 5 * time.Second
+
+// OpenVPNManagement — клиент management-интерфейса OpenVPN на UNIX-сокетах <instance>.sock
+// всех инстансов AntiZapret. Выключенные инстансы (сокета нет или он не принимает соединения) пропускаются.
+type OpenVPNManagement interface {
+	// Sessions возвращает текущие подключения всех инстансов
+	Sessions(ctx context.Context) ([]entity.VPNSession, error)
+	// Kill отключает все подключения клиента с указанным common name и возвращает их число.
+	// Клиент может сразу переподключиться: сертификат при этом не отзывается.
+	Kill(ctx context.Context, commonName string) (int, error)
+}
+
+type socketOpenVPNManagement struct {
+	socketPath string
+	// OpenVPN обслуживает одно соединение management за раз, поэтому обращения к инстансу идут по очереди
+	locks map[string]*sync.Mutex
+}
+
+// NewOpenVPNManagement — конструктор. socketPath — директория с сокетами, обычно /run/openvpn-server/.
+func NewOpenVPNManagement(socketPath string) OpenVPNManagement {
+	locks := make(map[string]*sync.Mutex, len(openvpnInstances))
+	for _, instance := range openvpnInstances {
+		locks[instance] = &sync.Mutex{}
+	}
+	return &socketOpenVPNManagement{socketPath: socketPath, locks: locks}
+}
+
+func (m *socketOpenVPNManagement) Sessions(ctx context.Context) ([]entity.VPNSession, error) {
+	sessions := []entity.VPNSession{}
+	available := false
+	for _, instance := range openvpnInstances {
+		// status 3 — тот же формат, что и в status.log с status-version 3
+		lines, err := m.command(ctx, instance, "status 3")
+		if errors.Is(err, errInstanceDown) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		available = true
+
+		parsed, err := parseOpenVPNStatus(strings.NewReader(strings.Join(lines, "\n")), instance)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", instance, err)
+		}
+		for _, s := range parsed {
+			session := entity.VPNSession{
+				Instance:       s.Instance,
+				Name:           s.CommonName,
+				RealAddress:    s.RealAddress,
+				VirtualAddress: s.VirtualAddress,
+				BytesReceived:  s.BytesReceived,
+				BytesSent:      s.BytesSent,
+			}
+			if !s.ConnectedSince.IsZero() {
+				connectedSince := s.ConnectedSince
+				session.ConnectedSince = &connectedSince
+			}
+			sessions = append(sessions, session)
+		}
+	}
+	if !available {
+		return nil, ErrManagementUnavailable
+	}
+	return sessions, nil
+}
+
+func (m *socketOpenVPNManagement) Kill(ctx context.Context, commonName string) (int, error) {
+	// Имя уходит в строку команды, пробелы и кавычки в нем изменили бы ее смысл
+	if commonName == "" || strings.ContainsAny(commonName, " \t\r\n\"'\\") {
+		return 0, fmt.Errorf("invalid common name %q", commonName)
+	}
+
+	killed := 0
+	available := false
+	for _, instance := range openvpnInstances {
+		lines, err := m.command(ctx, instance, "kill "+commonName)
+		if errors.Is(err, errInstanceDown) {
+			continue
+		}
+		if err != nil {
+			return killed, err
+		}
+		available = true
+		killed += parseKilledCount(lines)
+	}
+	if !available {
+		return 0, ErrManagementUnavailable
+	}
+	return killed, nil
+}
+
+// errInstanceDown — инстанс не запущен: сокета нет или соединение отклонено
+var errInstanceDown = errors.New("OpenVPN instance is not running")
+
+// command отправляет команду management-интерфейсу инстанса и возвращает строки ответа.
+// Многострочный ответ (status) заканчивается строкой END, остальные — одной строкой SUCCESS: или ERROR:.
+// Асинхронные уведомления (>INFO:, >CLIENT: и т.д.) пропускаются.
+func (m *socketOpenVPNManagement) command(ctx context.Context, instance, cmd string) ([]string, error) {
+	lock := m.locks[instance]
+	lock.Lock()
+	defer lock.Unlock()
+
+	ctx, cancel := context.WithTimeout(ctx, managementTimeout)
+	defer cancel()
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "unix", filepath.Join(m.socketPath, instance+".sock"))
+	if errors.Is(err, os.ErrNotExist) || errors.Is(err, syscall.ECONNREFUSED) {
+		return nil, errInstanceDown
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", instance, err)
+	}
+	defer conn.Close()
+
+	deadline, _ := ctx.Deadline()
+	conn.SetDeadline(deadline)
+	// Закрытие соединения прерывает чтение, если запрос отменили раньше срока
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	if _, err := fmt.Fprintf(conn, "%s\n", cmd); err != nil {
+		return nil, fmt.Errorf("%s: %w", instance, err)
+	}
+
+	multiline := strings.HasPrefix(cmd, "status")
+	var lines []string
+	scanner := bufio.NewScanner(conn)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if strings.HasPrefix(line, ">") {
+			continue
+		}
+		// Для kill и ERROR — обычный ответ ("not found"), его разбирает вызывающий
+		if !multiline {
+			return []string{line}, nil
+		}
+		if strings.HasPrefix(line, "ERROR:") {
+			return nil, fmt.Errorf("%s: %s", instance, strings.TrimSpace(strings.TrimPrefix(line, "ERROR:")))
+		}
+		if line == "END" {
+			return lines, nil
+		}
+		lines = append(lines, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", instance, err)
+	}
+	return nil, fmt.Errorf("%s: management connection closed before response", instance)
+}
+
+// parseKilledCount разбирает ответ на kill: "SUCCESS: common name 'x' found, 2 client(s) killed".
+// Ответ "ERROR: common name 'x' not found" означает, что на инстансе клиент не подключен.
+func parseKilledCount(lines []string) int {
+	if len(lines) == 0 || !strings.HasPrefix(lines[0], "SUCCESS:") {
+		return 0
+	}
+	_, rest, ok := strings.Cut(lines[0], "found, ")
+	if !ok {
+		return 1
+	}
+	count, _, _ := strings.Cut(rest, " ")
+	n, err := strconv.Atoi(count)
+	if err != nil {
+		return 1
+	}
+	return n
+}
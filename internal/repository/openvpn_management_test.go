@@ -0,0 +1,234 @@
+package repository
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeManagement — management-интерфейс одного инстанса OpenVPN на UNIX-сокете.
+// На каждое соединение отправляет приветствие >INFO, читает одну команду и отвечает из replies.
+type fakeManagement struct {
+	listener net.Listener
+	replies  map[string]string
+
+	mu       sync.Mutex
+	commands []string
+}
+
+func startFakeManagement(t *testing.T, dir, instance string, replies map[string]string) *fakeManagement {
+	t.Helper()
+	listener, err := net.Listen("unix", filepath.Join(dir, instance+".sock"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := &fakeManagement{listener: listener, replies: replies}
+	t.Cleanup(func() { listener.Close() })
+	go f.serve()
+	return f
+}
+
+func (f *fakeManagement) serve() {
+	for {
+		conn, err := f.listener.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			defer conn.Close()
+			conn.Write([]byte(">INFO:OpenVPN Management Interface Version 5 -- type 'help' for more info\r\n"))
+			cmd, err := bufio.NewReader(conn).ReadString('\n')
+			if err != nil {
+				return
+			}
+			cmd = strings.TrimSpace(cmd)
+			f.mu.Lock()
+			f.commands = append(f.commands, cmd)
+			f.mu.Unlock()
+
+			reply, ok := f.replies[cmd]
+			if !ok {
+				reply = "ERROR: unknown command, enter 'help' for more options\r\n"
+			}
+			conn.Write([]byte(reply))
+		}()
+	}
+}
+
+func (f *fakeManagement) received() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string{}, f.commands...)
+}
+
+// managementSocketDir возвращает короткую директорию: путь UNIX-сокета ограничен 108 байтами
+func managementSocketDir(t *testing.T) string {
+	t.Helper()
+	dir, err := os.MkdirTemp("", "ovpn")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	return dir
+}
+
+// statusReply — ответ на status 3: поля через табуляцию, асинхронное уведомление посреди ответа, END в конце
+func statusReply(clients ...string) string {
+	lines := []string{
+		"TITLE\tOpenVPN 2.6.12 x86_64-pc-linux-gnu",
+		"TIME\t2026-02-08 12:00:00\t1770552000",
+		"HEADER\tCLIENT_LIST\tCommon Name\tReal Address\tVirtual Address\tVirtual IPv6 Address\tBytes Received\tBytes Sent\tConnected Since\tConnected Since (time_t)\tUsername\tClient ID\tPeer ID\tData Channel Cipher",
+		">BYTECOUNT_CLI:3,100,200",
+	}
+	lines = append(lines, clients...)
+	lines = append(lines, "HEADER\tROUTING_TABLE\tVirtual Address\tCommon Name\tReal Address\tLast Ref\tLast Ref (time_t)", "END")
+	return strings.Join(lines, "\r\n") + "\r\n"
+}
+
+func TestOpenVPNManagementSessions(t *testing.T) {
+	dir := managementSocketDir(t)
+	startFakeManagement(t, dir, "antizapret-udp", map[string]string{
+		"status 3": statusReply(
+			"CLIENT_LIST\tivan\t203.0.113.5:51234\t10.29.0.6\t\t1000\t2000\t2026-02-08 11:00:00\t1770548400\tUNDEF\t3\t0\tAES-256-GCM",
+		),
+	})
+	startFakeManagement(t, dir, "vpn-tcp", map[string]string{
+		"status 3": statusReply(
+			"CLIENT_LIST\talexandr\t198.51.100.7:40000\t10.28.0.10\t\t10\t20\t2026-02-08 11:30:00\t1770550200\tUNDEF\t4\t1\tAES-256-GCM",
+		),
+	})
+	// Сокет остался от остановленного инстанса: соединения отклоняются
+	stale, err := net.Listen("unix", filepath.Join(dir, "vpn-udp.sock"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	stale.Close()
+	// antizapret-tcp.sock нет вовсе
+
+	sessions, err := NewOpenVPNManagement(dir).Sessions(context.Background())
+	if err != nil {
+		t.Fatalf("Sessions: %v", err)
+	}
+	if len(sessions) != 2 {
+		t.Fatalf("got %d sessions, want 2: %+v", len(sessions), sessions)
+	}
+
+	ivan := sessions[0]
+	if ivan.Name != "ivan" || ivan.Instance != "antizapret-udp" || ivan.RealAddress != "203.0.113.5:51234" ||
+		ivan.VirtualAddress != "10.29.0.6" || ivan.BytesReceived != 1000 || ivan.BytesSent != 2000 {
+		t.Errorf("unexpected session: %+v", ivan)
+	}
+	if ivan.ConnectedSince == nil || ivan.ConnectedSince.Unix() != 1770548400 {
+		t.Errorf("ConnectedSince = %v, want 1770548400", ivan.ConnectedSince)
+	}
+	if sessions[1].Name != "alexandr" || sessions[1].Instance != "vpn-tcp" {
+		t.Errorf("unexpected session: %+v", sessions[1])
+	}
+}
+
+func TestOpenVPNManagementSessionsError(t *testing.T) {
+	tests := []struct {
+		name  string
+		reply string
+		want  string
+	}{
+		{"error reply", ">INFO:notice\r\nERROR: status command failed\r\n", "status command failed"},
+		// Соединение закрылось до строки END: обрезанный список не выдается за полный
+		{"no END", "TITLE\tOpenVPN\r\nCLIENT_LIST\tivan\r\n", "closed before response"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := managementSocketDir(t)
+			startFakeManagement(t, dir, "antizapret-udp", map[string]string{"status 3": tt.reply})
+
+			_, err := NewOpenVPNManagement(dir).Sessions(context.Background())
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("err = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestOpenVPNManagementUnavailable(t *testing.T) {
+	management := NewOpenVPNManagement(managementSocketDir(t))
+
+	if _, err := management.Sessions(context.Background()); !errors.Is(err, ErrManagementUnavailable) {
+		t.Errorf("Sessions err = %v, want ErrManagementUnavailable", err)
+	}
+	if _, err := management.Kill(context.Background(), "ivan"); !errors.Is(err, ErrManagementUnavailable) {
+		t.Errorf("Kill err = %v, want ErrManagementUnavailable", err)
+	}
+}
+
+func TestOpenVPNManagementKill(t *testing.T) {
+	dir := managementSocketDir(t)
+	udp := startFakeManagement(t, dir, "antizapret-udp", map[string]string{
+		"kill ivan": "SUCCESS: common name 'ivan' found, 2 client(s) killed\r\n",
+	})
+	startFakeManagement(t, dir, "vpn-udp", map[string]string{
+		"kill ivan": ">CLIENT:DISCONNECT,3\r\nSUCCESS: common name 'ivan' found, 1 client(s) killed\r\n",
+	})
+	// На этом инстансе клиент не подключен
+	startFakeManagement(t, dir, "vpn-tcp", map[string]string{
+		"kill ivan": "ERROR: common name 'ivan' not found\r\n",
+	})
+
+	management := NewOpenVPNManagement(dir)
+	killed, err := management.Kill(context.Background(), "ivan")
+	if err != nil {
+		t.Fatalf("Kill: %v", err)
+	}
+	if killed != 3 {
+		t.Errorf("killed = %d, want 3", killed)
+	}
+	if got := udp.received(); len(got) != 1 || got[0] != "kill ivan" {
+		t.Errorf("commands = %q, want [kill ivan]", got)
+	}
+
+	killed, err = management.Kill(context.Background(), "nobody")
+	if err != nil || killed != 0 {
+		t.Errorf("Kill(nobody) = %d, %v, want 0, nil", killed, err)
+	}
+}
+
+func TestOpenVPNManagementKillRejectsUnsafeName(t *testing.T) {
+	dir := managementSocketDir(t)
+	udp := startFakeManagement(t, dir, "antizapret-udp", nil)
+
+	for _, name := range []string{"", "ivan petrov", "ivan\nstatus", `ivan"`} {
+		if _, err := NewOpenVPNManagement(dir).Kill(context.Background(), name); err == nil {
+			t.Errorf("Kill(%q): expected error", name)
+		}
+	}
+	if got := udp.received(); len(got) != 0 {
+		t.Errorf("commands were sent: %q", got)
+	}
+}
+
+func TestParseKilledCount(t *testing.T) {
+	tests := []struct {
+		reply string
+		want  int
+	}{
+		{"SUCCESS: common name 'ivan' found, 2 client(s) killed", 2},
+		{"SUCCESS: client-kill command succeeded", 1},
+		{"ERROR: common name 'ivan' not found", 0},
+		{"", 0},
+	}
+	for _, tt := range tests {
+		var lines []string
+		if tt.reply != "" {
+			lines = []string{tt.reply}
+		}
+		if got := parseKilledCount(lines); got != tt.want {
+			t.Errorf("parseKilledCount(%q) = %d, want %d", tt.reply, got, tt.want)
+		}
+	}
+}
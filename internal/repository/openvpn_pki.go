@@ -0,0 +1,127 @@
+package repository
+
+import (
+	"bufio"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// pkiCertificate — данные сертификата клиента из easyrsa
+type pkiCertificate struct {
+	Serial    string
+	NotBefore time.Time
+	NotAfter  time.Time
+}
+
+// Статус отозванного сертификата в pki/index.txt (V — действующий, E — просроченный)
+const pkiIndexRevoked = "R"
+
+// pkiIndexEntry — строка pki/index.txt (формат базы OpenSSL CA)
+type pkiIndexEntry struct {
+	Status    string
+	NotAfter  time.Time
+	RevokedAt time.Time
+	Serial    string
+	Name      string
+}
+
+// Формат дат в index.txt: YYMMDDHHMMSSZ, для дат после 2049 года — YYYYMMDDHHMMSSZ
+var pkiIndexTimeLayouts = []string{"060102150405Z", "20060102150405Z"}
+
+// readIssuedCertificate читает сертификат клиента из pki/issued/<name>.crt
+func readIssuedCertificate(pkiPath, name string) (*pkiCertificate, error) {
+	return readCertificate(filepath.Join(pkiPath, "issued", name+".crt"))
+}
+
+// readRevokedCertificate читает отозванный сертификат, который easyrsa сохраняет по серийному номеру
+func readRevokedCertificate(pkiPath, serial string) (*pkiCertificate, error) {
+	return readCertificate(filepath.Join(pkiPath, "revoked", "certs_by_serial", serial+".crt"))
+}
+
+func readCertificate(path string) (*pkiCertificate, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	// easyrsa пишет перед PEM текстовое описание сертификата, pem.Decode его пропускает
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != "CERTIFICATE" {
+		return nil, fmt.Errorf("no certificate found in %s", path)
+	}
+
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse certificate %s: %w", path, err)
+	}
+
+	return &pkiCertificate{
+		Serial:    fmt.Sprintf("%X", cert.SerialNumber.Bytes()),
+		NotBefore: cert.NotBefore,
+		NotAfter:  cert.NotAfter,
+	}, nil
+}
+
+// readPKIIndex читает pki/index.txt. Если файла нет, возвращает пустой список.
+func readPKIIndex(pkiPath string) ([]pkiIndexEntry, error) {
+	file, err := os.Open(filepath.Join(pkiPath, "index.txt"))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer file.Close()
+
+	var entries []pkiIndexEntry
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		// status \t notAfter \t revocationDate[,reason] \t serial \t filename \t subject
+		fields := strings.Split(scanner.Text(), "\t")
+		if len(fields) < 6 {
+			continue
+		}
+
+		entry := pkiIndexEntry{
+			Status:   fields[0],
+			NotAfter: parsePKIIndexTime(fields[1]),
+			Serial:   strings.ToUpper(fields[3]),
+			Name:     subjectCommonName(fields[5]),
+		}
+		if revokedAt, _, _ := strings.Cut(fields[2], ","); revokedAt != "" {
+			entry.RevokedAt = parsePKIIndexTime(revokedAt)
+		}
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+func parsePKIIndexTime(value string) time.Time {
+	for _, layout := range pkiIndexTimeLayouts {
+		t, err := time.Parse(layout, value)
+		if err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// subjectCommonName достает CN из subject вида /CN=name или /C=RU/CN=name
+func subjectCommonName(subject string) string {
+	for _, part := range strings.Split(subject, "/") {
+		if name, ok := strings.CutPrefix(part, "CN="); ok {
+			return name
+		}
+	}
+	return ""
+}
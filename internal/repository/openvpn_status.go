@@ -0,0 +1,207 @@
+package repository
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Инстансы OpenVPN, которые поднимает AntiZapret
+var openvpnInstances = []string{"antizapret-udp", "antizapret-tcp", "vpn-udp", "vpn-tcp"}
+
+// openvpnSession — одно подключение клиента из *-status.log
+type openvpnSession struct {
+	Instance       string
+	CommonName     string
+	RealAddress    string
+	VirtualAddress string
+	BytesReceived  int64
+	BytesSent      int64
+	ConnectedSince time.Time
+}
+
+// Форматы дат в status.log: OpenVPN 2.6+ и более старые версии
+var openvpnStatusTimeLayouts = []string{
+	"2006-01-02 15:04:05",
+	"Mon Jan _2 15:04:05 2006",
+}
+
+// readOpenVPNSessions читает status.log всех инстансов из директории statusPath.
+// Отсутствующие файлы пропускаются: инстанс может быть выключен.
+func readOpenVPNSessions(statusPath string) ([]openvpnSession, error) {
+	var sessions []openvpnSession
+	for _, instance := range openvpnInstances {
+		file, err := os.Open(filepath.Join(statusPath, instance+"-status.log"))
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			return nil, err
+		}
+
+		instanceSessions, err := parseOpenVPNStatus(file, instance)
+		file.Close()
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, instanceSessions...)
+	}
+	return sessions, nil
+}
+
+// parseOpenVPNStatus разбирает status.log в любом из форматов status-version 1, 2 или 3.
+func parseOpenVPNStatus(r io.Reader, instance string) ([]openvpnSession, error) {
+	var (
+		sessions []openvpnSession
+		// Колонки из строк HEADER (версии 2 и 3)
+		clientColumns  map[string]int
+		routingColumns map[string]int
+		// Текущая секция версии 1
+		section string
+		// Виртуальные адреса из ROUTING TABLE версии 1, ключ — имя и реальный адрес
+		virtualAddresses = make(map[string]string)
+	)
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if line == "" {
+			continue
+		}
+
+		// Версия 3 разделяет поля табуляцией, версии 1 и 2 — запятой
+		separator := ","
+		if strings.Contains(line, "\t") {
+			separator = "\t"
+		}
+		fields := strings.Split(line, separator)
+
+		switch fields[0] {
+		case "HEADER":
+			if len(fields) < 2 {
+				continue
+			}
+			switch fields[1] {
+			case "CLIENT_LIST":
+				clientColumns = columnIndexes(fields[2:])
+			case "ROUTING_TABLE":
+				routingColumns = columnIndexes(fields[2:])
+			}
+			continue
+		case "CLIENT_LIST":
+			if clientColumns != nil {
+				sessions = append(sessions, sessionFromColumns(fields[1:], clientColumns, instance))
+			}
+			continue
+		case "ROUTING_TABLE":
+			if routingColumns != nil {
+				row := fields[1:]
+				key := column(row, routingColumns, "Common Name") + "|" + column(row, routingColumns, "Real Address")
+				virtualAddresses[key] = column(row, routingColumns, "Virtual Address")
+			}
+			continue
+		}
+
+		// Версия 1: секции без префиксов
+		switch line {
+		case "OpenVPN CLIENT LIST", "ROUTING TABLE", "GLOBAL STATS", "END":
+			section = line
+			continue
+		}
+		if strings.HasPrefix(line, "Updated,") || strings.HasPrefix(line, "Common Name,") || strings.HasPrefix(line, "Virtual Address,") {
+			continue
+		}
+
+		switch section {
+		case "OpenVPN CLIENT LIST":
+			// Common Name,Real Address,Bytes Received,Bytes Sent,Connected Since
+			if len(fields) < 5 {
+				continue
+			}
+			sessions = append(sessions, openvpnSession{
+				Instance:       instance,
+				CommonName:     fields[0],
+				RealAddress:    fields[1],
+				BytesReceived:  parseInt64(fields[2]),
+				BytesSent:      parseInt64(fields[3]),
+				ConnectedSince: parseStatusTime(fields[4]),
+			})
+		case "ROUTING TABLE":
+			// Virtual Address,Common Name,Real Address,Last Ref
+			if len(fields) < 3 {
+				continue
+			}
+			virtualAddresses[fields[1]+"|"+fields[2]] = fields[0]
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range sessions {
+		if sessions[i].VirtualAddress == "" {
+			sessions[i].VirtualAddress = virtualAddresses[sessions[i].CommonName+"|"+sessions[i].RealAddress]
+		}
+	}
+
+	return sessions, nil
+}
+
+func sessionFromColumns(row []string, columns map[string]int, instance string) openvpnSession {
+	session := openvpnSession{
+		Instance:       instance,
+		CommonName:     column(row, columns, "Common Name"),
+		RealAddress:    column(row, columns, "Real Address"),
+		VirtualAddress: column(row, columns, "Virtual Address"),
+		BytesReceived:  parseInt64(column(row, columns, "Bytes Received")),
+		BytesSent:      parseInt64(column(row, columns, "Bytes Sent")),
+	}
+
+	// time_t точнее строки: не зависит от часового пояса сервера
+	if unix := parseInt64(column(row, columns, "Connected Since (time_t)")); unix > 0 {
+		session.ConnectedSince = time.Unix(unix, 0)
+	} else {
+		session.ConnectedSince = parseStatusTime(column(row, columns, "Connected Since"))
+	}
+
+	return session
+}
+
+func columnIndexes(header []string) map[string]int {
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[name] = i
+	}
+	return columns
+}
+
+func column(row []string, columns map[string]int, name string) string {
+	i, ok := columns[name]
+	if !ok || i >= len(row) {
+		return ""
+	}
+	return row[i]
+}
+
+func parseInt64(value string) int64 {
+	n, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+	if err != nil {
+		return 0
+	}
+	return n
+}
+
+func parseStatusTime(value string) time.Time {
+	for _, layout := range openvpnStatusTimeLayouts {
+		t, err := time.ParseInLocation(layout, strings.TrimSpace(value), time.Local)
+		if err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
@@ -0,0 +1,127 @@
+package repository
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log"
+	"os/exec"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// ErrShuttingDown возвращается при попытке запустить скрипт во время остановки панели
+var ErrShuttingDown = errors.New("panel is shutting down")
+
+// Сколько ждать выхода скрипта после SIGTERM, прежде чем убить его
+const scriptKillDelay = 5 * time.Second
+
+// Сколько после SIGKILL ждать, пока закроются stdout и stderr, прежде чем бросить их
+const scriptPipeDelay = time.Second
+
+// ScriptRunner запускает скрипты AntiZapret (client.sh, doall.sh) и отслеживает выполняющиеся.
+type ScriptRunner interface {
+	// Run запускает client.sh с аргументами и возвращает его вывод.
+	// Если ctx отменен до запуска, скрипт не запускается. Запущенный скрипт не прерывается
+	// вместе с ctx: недоделанный build-client-full или revoke хуже опоздавшего ответа.
+	Run(ctx context.Context, args ...string) (string, error)
+	// Stream запускает script так же, как Run, но пишет stdout и stderr в output по мере выполнения.
+	Stream(ctx context.Context, output io.Writer, script string, args ...string) error
+	// Shutdown запрещает новые запуски и ждет завершения текущих. Если ctx истекает раньше,
+	// скриптам отправляется SIGTERM, а через несколько секунд — SIGKILL.
+	Shutdown(ctx context.Context) error
+}
+
+type scriptRunner struct {
+	scriptPath string
+	killDelay  time.Duration
+
+	mu      sync.Mutex
+	closed  bool
+	running sync.WaitGroup
+
+	// Отменяется, только если скрипты не успели завершиться при остановке
+	kill       context.Context
+	cancelKill context.CancelFunc
+}
+
+// NewScriptRunner — конструктор.
+func NewScriptRunner(scriptPath string) ScriptRunner {
+	kill, cancelKill := context.WithCancel(context.Background())
+	return &scriptRunner{scriptPath: scriptPath, killDelay: scriptKillDelay, kill: kill, cancelKill: cancelKill}
+}
+
+func (r *scriptRunner) Run(ctx context.Context, args ...string) (string, error) {
+	var output bytes.Buffer
+	err := r.Stream(ctx, &output, r.scriptPath, args...)
+	return output.String(), err
+}
+
+func (r *scriptRunner) Stream(ctx context.Context, output io.Writer, script string, args ...string) error {
+	r.mu.Lock()
+	if r.closed {
+		r.mu.Unlock()
+		return ErrShuttingDown
+	}
+	if err := ctx.Err(); err != nil {
+		r.mu.Unlock()
+		return err
+	}
+	r.running.Add(1)
+	r.mu.Unlock()
+	defer r.running.Done()
+
+	exited := make(chan struct{})
+	defer close(exited)
+
+	cmd := exec.CommandContext(r.kill, script, args...)
+	// Скрипт и запущенные им easyrsa/openssl получают сигнал вместе, своей группой процессов
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		pgid := cmd.Process.Pid
+		// По истечении WaitDelay exec убивает только сам скрипт. Дочерние процессы, которые
+		// пережили SIGTERM, держали бы stdout открытым, поэтому SIGKILL получает вся группа.
+		go func() {
+			timer := time.NewTimer(r.killDelay)
+			defer timer.Stop()
+			select {
+			case <-timer.C:
+				syscall.Kill(-pgid, syscall.SIGKILL)
+			case <-exited:
+			}
+		}()
+		return syscall.Kill(-pgid, syscall.SIGTERM)
+	}
+	// Запас после SIGKILL группы, чтобы exec не бросил вывод раньше, чем процессы умрут
+	cmd.WaitDelay = r.killDelay + scriptPipeDelay
+	// Один и тот же writer: exec пишет в него из одной горутины
+	cmd.Stdout = output
+	cmd.Stderr = output
+	log.Printf("Running command: %s", cmd.String())
+
+	return cmd.Run()
+}
+
+func (r *scriptRunner) Shutdown(ctx context.Context) error {
+	r.mu.Lock()
+	r.closed = true
+	r.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		r.running.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		log.Println("Скрипты не завершились вовремя, отправляется SIGTERM")
+		r.cancelKill()
+		<-done
+		return ctx.Err()
+	}
+}
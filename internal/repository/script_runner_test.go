@@ -0,0 +1,80 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// processAlive сообщает, жив ли процесс. Зомби, которого еще не забрал родитель, считается мертвым.
+func processAlive(pid int) bool {
+	stat, err := os.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		return false
+	}
+	// Состояние идет после имени процесса в скобках
+	fields := strings.Fields(string(stat[strings.LastIndexByte(string(stat), ')')+1:]))
+	return len(fields) > 0 && fields[0] != "Z"
+}
+
+// При остановке дочерний процесс, который игнорирует SIGTERM и держит stdout, убивается вместе со скриптом
+func TestScriptRunnerKillsProcessGroup(t *testing.T) {
+	if _, err := os.Stat("/proc/self/stat"); err != nil {
+		t.Skip("needs /proc")
+	}
+	dir := t.TempDir()
+	pidFile := filepath.Join(dir, "child.pid")
+	script := filepath.Join(dir, "client.sh")
+	body := "#!/bin/sh\ntrap '' TERM\nsleep 60 &\necho $! > " + pidFile + "\necho started\nwait\n"
+	if err := os.WriteFile(script, []byte(body), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	runner := NewScriptRunner(script).(*scriptRunner)
+	runner.killDelay = 200 * time.Millisecond
+
+	result := make(chan error, 1)
+	go func() {
+		_, err := runner.Run(context.Background())
+		result <- err
+	}()
+
+	var childPID int
+	deadline := time.Now().Add(5 * time.Second)
+	for childPID == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("script did not start")
+		}
+		if data, err := os.ReadFile(pidFile); err == nil && strings.HasSuffix(string(data), "\n") {
+			childPID, _ = strconv.Atoi(strings.TrimSpace(string(data)))
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	started := time.Now()
+	if err := runner.Shutdown(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("Shutdown: err = %v, want context.Canceled", err)
+	}
+	if elapsed := time.Since(started); elapsed > runner.killDelay+scriptPipeDelay/2 {
+		t.Errorf("Shutdown took %s: the output pipe was held open until WaitDelay", elapsed)
+	}
+	if err := <-result; err == nil {
+		t.Error("killed script reported success")
+	}
+
+	deadline = time.Now().Add(2 * time.Second)
+	for processAlive(childPID) {
+		if time.Now().After(deadline) {
+			t.Fatalf("child process %d survived SIGKILL of the group", childPID)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,152 @@
+package repository
+
+import (
+	"antizapret-admin-panel/internal/entity"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ErrSessionNotFound возвращается, когда сессии с таким ID или токеном нет
+var ErrSessionNotFound = errors.New("session not found")
+
+// SessionRepository — контракт хранилища сессий входа в панель
+type SessionRepository interface {
+	FindAll() ([]entity.Session, error)
+	FindByTokenHash(tokenHash string) (*entity.Session, error)
+	Create(session entity.Session) error
+	Delete(id string) error
+	DeleteExpired(now time.Time) error
+}
+
+// sessionRecord — запись в sessions.json
+type sessionRecord struct {
+	ID        string    `json:"id"`
+	Username  string    `json:"username"`
+	TokenHash string    `json:"token_hash"`
+	IP        string    `json:"ip"`
+	UserAgent string    `json:"user_agent"`
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// NewSessionRepository — конструктор файлового хранилища сессий.
+// Сессии держатся в памяти (они проверяются на каждый запрос) и сохраняются в файл,
+// чтобы перезапуск сервиса не разлогинивал пользователей.
+func NewSessionRepository(path string) (SessionRepository, error) {
+	r := &fileSessionRepository{path: path}
+
+	var records []sessionRecord
+	if err := readJSONFile(path, &records); err != nil {
+		return nil, err
+	}
+	r.sessions = make(map[string]entity.Session, len(records))
+	for _, record := range records {
+		r.sessions[record.ID] = record.toEntity()
+	}
+
+	return r, nil
+}
+
+type fileSessionRepository struct {
+	path     string
+	mu       sync.RWMutex
+	sessions map[string]entity.Session // ID -> сессия
+}
+
+func (r *fileSessionRepository) FindAll() ([]entity.Session, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	sessions := make([]entity.Session, 0, len(r.sessions))
+	for _, session := range r.sessions {
+		sessions = append(sessions, session)
+	}
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].CreatedAt.After(sessions[j].CreatedAt)
+	})
+	return sessions, nil
+}
+
+func (r *fileSessionRepository) FindByTokenHash(tokenHash string) (*entity.Session, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, session := range r.sessions {
+		if session.TokenHash == tokenHash {
+			return &session, nil
+		}
+	}
+	return nil, ErrSessionNotFound
+}
+
+func (r *fileSessionRepository) Create(session entity.Session) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.sessions[session.ID] = session
+	return r.save()
+}
+
+func (r *fileSessionRepository) Delete(id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.sessions[id]; !ok {
+		return ErrSessionNotFound
+	}
+	delete(r.sessions, id)
+	return r.save()
+}
+
+func (r *fileSessionRepository) DeleteExpired(now time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	changed := false
+	for id, session := range r.sessions {
+		if !now.Before(session.ExpiresAt) {
+			delete(r.sessions, id)
+			changed = true
+		}
+	}
+
+	if !changed {
+		return nil
+	}
+	return r.save()
+}
+
+// save вызывается под блокировкой
+func (r *fileSessionRepository) save() error {
+	records := make([]sessionRecord, 0, len(r.sessions))
+	for _, session := range r.sessions {
+		records = append(records, newSessionRecord(session))
+	}
+	return writeJSONFile(r.path, records)
+}
+
+func newSessionRecord(session entity.Session) sessionRecord {
+	return sessionRecord{
+		ID:        session.ID,
+		Username:  session.Username,
+		TokenHash: session.TokenHash,
+		IP:        session.IP,
+		UserAgent: session.UserAgent,
+		CreatedAt: session.CreatedAt,
+		ExpiresAt: session.ExpiresAt,
+	}
+}
+
+func (r sessionRecord) toEntity() entity.Session {
+	return entity.Session{
+		ID:        r.ID,
+		Username:  r.Username,
+		TokenHash: r.TokenHash,
+		IP:        r.IP,
+		UserAgent: r.UserAgent,
+		CreatedAt: r.CreatedAt,
+		ExpiresAt: r.ExpiresAt,
+	}
+}
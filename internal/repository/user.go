@@ -0,0 +1,198 @@
+package repository
+
+import (
+	"antizapret-admin-panel/internal/entity"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrUserNotFound возвращается, когда пользователя с таким логином нет
+	ErrUserNotFound = errors.New("user not found")
+	// ErrUserExists возвращается при создании пользователя с занятым логином
+	ErrUserExists = errors.New("user already exists")
+)
+
+// UserRepository — контракт хранилища учетных записей панели
+type UserRepository interface {
+	FindAll() ([]entity.User, error)
+	FindByUsername(username string) (*entity.User, error)
+	Create(user entity.User) error
+	Update(user entity.User) error
+	Delete(username string) error
+}
+
+// userRecord — запись в users.json. В отличие от entity.User, хеш пароля и секреты TOTP сериализуются.
+type userRecord struct {
+	Username     string    `json:"username"`
+	PasswordHash string    `json:"password_hash"`
+	Role         string    `json:"role"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+
+	PasswordChangedAt time.Time `json:"password_changed_at"`
+
+	TOTPEnabled        bool     `json:"totp_enabled,omitempty"`
+	TOTPSecret         string   `json:"totp_secret,omitempty"`
+	TOTPPendingSecret  string   `json:"totp_pending_secret,omitempty"`
+	TOTPLastStep       int64    `json:"totp_last_step,omitempty"`
+	RecoveryCodeHashes []string `json:"recovery_code_hashes,omitempty"`
+}
+
+// NewUserRepository — конструктор файлового хранилища пользователей
+func NewUserRepository(path string) UserRepository {
+	return &fileUserRepository{path: path}
+}
+
+type fileUserRepository struct {
+	path string
+	mu   sync.Mutex
+}
+
+func (r *fileUserRepository) FindAll() ([]entity.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	records, err := r.load()
+	if err != nil {
+		return nil, err
+	}
+
+	users := make([]entity.User, 0, len(records))
+	for _, record := range records {
+		users = append(users, record.toEntity())
+	}
+	sort.Slice(users, func(i, j int) bool {
+		return users[i].Username < users[j].Username
+	})
+	return users, nil
+}
+
+func (r *fileUserRepository) FindByUsername(username string) (*entity.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	records, err := r.load()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, record := range records {
+		if record.Username == username {
+			user := record.toEntity()
+			return &user, nil
+		}
+	}
+	return nil, ErrUserNotFound
+}
+
+func (r *fileUserRepository) Create(user entity.User) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	records, err := r.load()
+	if err != nil {
+		return err
+	}
+
+	for _, record := range records {
+		if record.Username == user.Username {
+			return ErrUserExists
+		}
+	}
+
+	records = append(records, newUserRecord(user))
+	return r.save(records)
+}
+
+func (r *fileUserRepository) Update(user entity.User) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	records, err := r.load()
+	if err != nil {
+		return err
+	}
+
+	for i, record := range records {
+		if record.Username == user.Username {
+			records[i] = newUserRecord(user)
+			return r.save(records)
+		}
+	}
+	return ErrUserNotFound
+}
+
+func (r *fileUserRepository) Delete(username string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	records, err := r.load()
+	if err != nil {
+		return err
+	}
+
+	for i, record := range records {
+		if record.Username == username {
+			records = append(records[:i], records[i+1:]...)
+			return r.save(records)
+		}
+	}
+	return ErrUserNotFound
+}
+
+func (r *fileUserRepository) load() ([]userRecord, error) {
+	var records []userRecord
+	if err := readJSONFile(r.path, &records); err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+func (r *fileUserRepository) save(records []userRecord) error {
+	return writeJSONFile(r.path, records)
+}
+
+func newUserRecord(user entity.User) userRecord {
+	return userRecord{
+		Username:     user.Username,
+		PasswordHash: user.PasswordHash,
+		Role:         user.Role,
+		CreatedAt:    user.CreatedAt,
+		UpdatedAt:    user.UpdatedAt,
+
+		PasswordChangedAt: user.PasswordChangedAt,
+
+		TOTPEnabled:        user.TOTPEnabled,
+		TOTPSecret:         user.TOTPSecret,
+		TOTPPendingSecret:  user.TOTPPendingSecret,
+		TOTPLastStep:       user.TOTPLastStep,
+		RecoveryCodeHashes: user.RecoveryCodeHashes,
+	}
+}
+
+func (r userRecord) toEntity() entity.User {
+	// Пользователи, созданные до появления ролей, были полноправными администраторами
+	role := r.Role
+	if role == "" {
+		role = entity.RoleAdmin
+	}
+
+	return entity.User{
+		Username:     r.Username,
+		PasswordHash: r.PasswordHash,
+		Role:         role,
+		CreatedAt:    r.CreatedAt,
+		UpdatedAt:    r.UpdatedAt,
+
+		PasswordChangedAt: r.PasswordChangedAt,
+
+		TOTPEnabled:        r.TOTPEnabled,
+		TOTPSecret:         r.TOTPSecret,
+		TOTPPendingSecret:  r.TOTPPendingSecret,
+		TOTPLastStep:       r.TOTPLastStep,
+		RecoveryCodeHashes: r.RecoveryCodeHashes,
+	}
+}
@@ -0,0 +1,261 @@
+package server
+
+import (
+	"antizapret-admin-panel/internal/config"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// Время на чтение заголовков запроса, чтобы медленные клиенты не держали соединения
+const readHeaderTimeout = 10 * time.Second
+
+// Run запускает сервер панели по настройкам cfg и блокируется до отмены ctx или ошибки сервера.
+// Без TLS это обычный HTTP. С TLS на listen_addr принимаются и HTTPS, и HTTP-запросы,
+// которые перенаправляются на HTTPS; tls.http_addr, если задан, поднимает еще один HTTP-сервер
+// для перенаправления и проверки ACME HTTP-01.
+//
+// После отмены ctx серверы перестают принимать соединения и дожидаются текущих запросов,
+// затем вызывается drain. На все вместе отводится cfg.ShutdownTimeout.
+func Run(ctx context.Context, cfg *config.Config, handler http.Handler, drain func(context.Context) error) error {
+	ln, err := net.Listen("tcp", cfg.ListenAddr)
+	if err != nil {
+		return err
+	}
+	return serve(ctx, cfg, ln, handler, drain)
+}
+
+// serve — Run на уже открытом слушателе listen_addr. Слушатель закрывается при выходе.
+func serve(ctx context.Context, cfg *config.Config, ln net.Listener, handler http.Handler, drain func(context.Context) error) error {
+	var servers []*http.Server
+	errc := make(chan error, 3)
+	start := func(srv *http.Server, serve func() error) {
+		servers = append(servers, srv)
+		go func() { errc <- serve() }()
+	}
+
+	if !cfg.TLS.Enabled() {
+		srv := &http.Server{Handler: handler, ReadHeaderTimeout: readHeaderTimeout}
+		log.Printf("Server starting on http://%s", cfg.ListenAddr)
+		start(srv, func() error { return srv.Serve(ln) })
+	} else {
+		tlsConfig, httpHandler, err := newTLSConfig(cfg)
+		if err != nil {
+			ln.Close()
+			return err
+		}
+		_, httpsPort, _ := net.SplitHostPort(ln.Addr().String())
+		redirect := redirectHandler(httpsPort)
+
+		var httpLn net.Listener
+		if cfg.TLS.HTTPAddr != "" {
+			if httpLn, err = net.Listen("tcp", cfg.TLS.HTTPAddr); err != nil {
+				ln.Close()
+				return err
+			}
+		}
+
+		httpsLn, plainLn := splitTLS(ln)
+		httpsSrv := &http.Server{Handler: handler, TLSConfig: tlsConfig, ReadHeaderTimeout: readHeaderTimeout}
+		start(httpsSrv, func() error { return httpsSrv.Serve(tls.NewListener(httpsLn, tlsConfig)) })
+		plainSrv := &http.Server{Handler: redirect, ReadHeaderTimeout: readHeaderTimeout}
+		start(plainSrv, func() error { return plainSrv.Serve(plainLn) })
+		if httpLn != nil {
+			httpSrv := &http.Server{Handler: httpHandler(redirect), ReadHeaderTimeout: readHeaderTimeout}
+			log.Printf("HTTP redirect server starting on %s", cfg.TLS.HTTPAddr)
+			start(httpSrv, func() error { return httpSrv.Serve(httpLn) })
+		}
+		log.Printf("Server starting on https://%s (tls.mode = %s)", cfg.ListenAddr, cfg.TLS.Mode)
+	}
+
+	var serveErr error
+	select {
+	case serveErr = <-errc:
+		log.Printf("Server failed: %v", serveErr)
+	case <-ctx.Done():
+		log.Printf("Остановка: ожидание текущих запросов и скриптов (до %s)", time.Duration(cfg.ShutdownTimeout))
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), time.Duration(cfg.ShutdownTimeout))
+	defer cancel()
+
+	var errs []error
+	for _, srv := range servers {
+		if err := srv.Shutdown(shutdownCtx); err != nil {
+			errs = append(errs, fmt.Errorf("http shutdown: %w", err))
+		}
+	}
+	// Слушатель делится между серверами через splitTLS, поэтому закрывается отдельно
+	ln.Close()
+	if drain != nil {
+		if err := drain(shutdownCtx); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if serveErr != nil {
+		return serveErr
+	}
+	return errors.Join(errs...)
+}
+
+// newTLSConfig собирает настройки TLS и обработчик для обычного HTTP-сервера:
+// в режиме ACME он отвечает на проверки HTTP-01, остальное передает дальше.
+func newTLSConfig(cfg *config.Config) (*tls.Config, func(http.Handler) http.Handler, error) {
+	switch cfg.TLS.Mode {
+	case config.TLSModeFile:
+		cert, err := tls.LoadX509KeyPair(cfg.TLS.CertFile, cfg.TLS.KeyFile)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to load TLS certificate: %w", err)
+		}
+		tlsConfig := &tls.Config{
+			Certificates: []tls.Certificate{cert},
+			MinVersion:   tls.VersionTLS12,
+			NextProtos:   []string{"h2", "http/1.1"},
+		}
+		return tlsConfig, func(h http.Handler) http.Handler { return h }, nil
+
+	case config.TLSModeACME:
+		manager := &autocert.Manager{
+			Prompt:     autocert.AcceptTOS,
+			HostPolicy: autocert.HostWhitelist(cfg.TLS.ACME.Domains...),
+			Cache:      autocert.DirCache(cfg.TLS.ACME.CacheDir),
+			Email:      cfg.TLS.ACME.Email,
+			Client:     &acme.Client{DirectoryURL: cfg.TLS.ACME.DirectoryURL},
+		}
+		// TLSConfig уже включает протокол acme-tls/1 для проверки TLS-ALPN-01
+		tlsConfig := manager.TLSConfig()
+		tlsConfig.MinVersion = tls.VersionTLS12
+		return tlsConfig, manager.HTTPHandler, nil
+
+	default:
+		return nil, nil, fmt.Errorf("unsupported TLS mode: %s", cfg.TLS.Mode)
+	}
+}
+
+// redirectHandler перенаправляет запрос на тот же хост и путь по HTTPS на порт панели.
+func redirectHandler(httpsPort string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if host == "" {
+			http.Error(w, "Use HTTPS", http.StatusBadRequest)
+			return
+		}
+		if httpsPort != "443" {
+			host = net.JoinHostPort(host, httpsPort)
+		}
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusMovedPermanently)
+	})
+}
+
+// Время, за которое клиент должен прислать первый байт, чтобы соединение отнесли к TLS или HTTP
+const sniffTimeout = 10 * time.Second
+
+// splitTLS делит соединения одного порта на TLS и обычный HTTP по первому байту:
+// запись TLS Handshake всегда начинается с 0x16, HTTP-запрос — с буквы метода.
+func splitTLS(ln net.Listener) (net.Listener, net.Listener) {
+	tlsLn := newChanListener(ln.Addr())
+	plainLn := newChanListener(ln.Addr())
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				tlsLn.closeWithError(err)
+				plainLn.closeWithError(err)
+				return
+			}
+			go func() {
+				conn.SetReadDeadline(time.Now().Add(sniffTimeout))
+				first := make([]byte, 1)
+				if _, err := conn.Read(first); err != nil {
+					conn.Close()
+					return
+				}
+				conn.SetReadDeadline(time.Time{})
+
+				peeked := &peekedConn{Conn: conn, first: first}
+				if first[0] == 0x16 {
+					tlsLn.push(peeked)
+				} else {
+					plainLn.push(peeked)
+				}
+			}()
+		}
+	}()
+
+	return tlsLn, plainLn
+}
+
+// peekedConn возвращает уже прочитанный первый байт перед остальными данными соединения.
+type peekedConn struct {
+	net.Conn
+	first []byte
+}
+
+func (c *peekedConn) Read(b []byte) (int, error) {
+	if len(c.first) > 0 {
+		n := copy(b, c.first)
+		c.first = c.first[n:]
+		return n, nil
+	}
+	return c.Conn.Read(b)
+}
+
+// chanListener — net.Listener, который отдает соединения, переданные через push.
+type chanListener struct {
+	addr  net.Addr
+	conns chan net.Conn
+	done  chan struct{}
+	once  sync.Once
+	err   error
+}
+
+func newChanListener(addr net.Addr) *chanListener {
+	return &chanListener{addr: addr, conns: make(chan net.Conn), done: make(chan struct{})}
+}
+
+func (l *chanListener) push(conn net.Conn) {
+	select {
+	case l.conns <- conn:
+	case <-l.done:
+		conn.Close()
+	}
+}
+
+func (l *chanListener) closeWithError(err error) {
+	l.once.Do(func() {
+		l.err = err
+		close(l.done)
+	})
+}
+
+func (l *chanListener) Accept() (net.Conn, error) {
+	select {
+	case conn := <-l.conns:
+		return conn, nil
+	case <-l.done:
+		return nil, l.err
+	}
+}
+
+func (l *chanListener) Close() error {
+	l.closeWithError(net.ErrClosed)
+	return nil
+}
+
+func (l *chanListener) Addr() net.Addr {
+	return l.addr
+}
@@ -0,0 +1,486 @@
+package server
+
+import (
+	"antizapret-admin-panel/internal/config"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRedirectHandler(t *testing.T) {
+	tests := []struct {
+		name      string
+		httpsPort string
+		host      string
+		target    string
+		want      string
+	}{
+		{"same host on the panel port", "8443", "vpn.example.com:8443", "/api/clients?page=2", "https://vpn.example.com:8443/api/clients?page=2"},
+		{"port 80 to the panel port", "8443", "vpn.example.com", "/", "https://vpn.example.com:8443/"},
+		{"default HTTPS port is omitted", "443", "vpn.example.com:80", "/login", "https://vpn.example.com/login"},
+		{"IPv6 host", "8443", "[2001:db8::1]:8080", "/", "https://[2001:db8::1]:8443/"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.target, nil)
+			req.Host = tt.host
+			rec := httptest.NewRecorder()
+			redirectHandler(tt.httpsPort).ServeHTTP(rec, req)
+
+			if rec.Code != http.StatusMovedPermanently {
+				t.Fatalf("status = %d, want 301", rec.Code)
+			}
+			if got := rec.Header().Get("Location"); got != tt.want {
+				t.Errorf("Location = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Host = ""
+	rec := httptest.NewRecorder()
+	redirectHandler("8443").ServeHTTP(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("empty Host: status = %d, want 400", rec.Code)
+	}
+}
+
+// testCA — удостоверяющий центр для сертификатов в тестах
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pool *x509.CertPool
+}
+
+func newTestCA(t *testing.T) *testCA {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(365 * 24 * time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+	return &testCA{cert: cert, key: key, pool: pool}
+}
+
+// issue подписывает сертификат сервера для dnsNames и IP 127.0.0.1
+func (ca *testCA) issue(t *testing.T, pub any, dnsNames ...string) []byte {
+	t.Helper()
+	serial, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: dnsNames[0]},
+		DNSNames:     dnsNames,
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+		NotBefore:    time.Now().Add(-time.Hour),
+		// Дольше autocert.Manager.RenewBefore (30 дней), иначе сертификат сразу уходит на продление
+		NotAfter:    time.Now().Add(90 * 24 * time.Hour),
+		KeyUsage:    x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, pub, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return der
+}
+
+// writeKeyPair выпускает сертификат и записывает его и ключ в PEM-файлы, как для tls.mode = file
+func (ca *testCA) writeKeyPair(t *testing.T, dir string) (string, string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.issue(t, &key.PublicKey, "panel.example")})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	if err := os.WriteFile(certFile, certPEM, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, keyPEM, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return certFile, keyFile
+}
+
+// startServer запускает serve на свободном порту 127.0.0.1 и возвращает адрес.
+// Сервер останавливается в конце теста; проверяется, что он вышел без ошибки и вызвал drain.
+func startServer(t *testing.T, cfg *config.Config) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.ListenAddr = ln.Addr().String()
+	cfg.ShutdownTimeout = config.Duration(5 * time.Second)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	drained := make(chan struct{})
+	done := make(chan error, 1)
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "panel "+r.URL.Path)
+	})
+	drain := func(context.Context) error {
+		close(drained)
+		return nil
+	}
+	go func() { done <- serve(ctx, cfg, ln, handler, drain) }()
+
+	t.Cleanup(func() {
+		cancel()
+		if err := <-done; err != nil {
+			t.Errorf("serve: %v", err)
+		}
+		select {
+		case <-drained:
+		default:
+			t.Error("drain was not called")
+		}
+	})
+	return cfg.ListenAddr
+}
+
+func get(t *testing.T, client *http.Client, url string) *http.Response {
+	t.Helper()
+	res, err := client.Get(url)
+	if err != nil {
+		t.Fatalf("GET %s: %v", url, err)
+	}
+	t.Cleanup(func() { res.Body.Close() })
+	return res
+}
+
+func readBody(t *testing.T, res *http.Response) string {
+	t.Helper()
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(body)
+}
+
+// noRedirects — клиент, который возвращает ответ с перенаправлением как есть
+var noRedirects = &http.Client{
+	CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+	Timeout:       5 * time.Second,
+}
+
+func TestServePlainHTTP(t *testing.T) {
+	addr := startServer(t, &config.Config{TLS: config.TLSConfig{Mode: config.TLSModeOff}})
+
+	res := get(t, noRedirects, "http://"+addr+"/api/clients")
+	if res.StatusCode != http.StatusOK || readBody(t, res) != "panel /api/clients" {
+		t.Errorf("status = %d, want 200 from the panel", res.StatusCode)
+	}
+}
+
+// С TLS один порт принимает и HTTPS, и обычный HTTP, который перенаправляется на HTTPS
+func TestServeTLSFileSplitsPort(t *testing.T) {
+	ca := newTestCA(t)
+	certFile, keyFile := ca.writeKeyPair(t, t.TempDir())
+	addr := startServer(t, &config.Config{TLS: config.TLSConfig{Mode: config.TLSModeFile, CertFile: certFile, KeyFile: keyFile}})
+	_, port, _ := net.SplitHostPort(addr)
+
+	httpsClient := &http.Client{
+		Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: ca.pool}},
+		Timeout:   5 * time.Second,
+	}
+	res := get(t, httpsClient, "https://"+addr+"/api/clients")
+	if res.StatusCode != http.StatusOK || readBody(t, res) != "panel /api/clients" {
+		t.Errorf("HTTPS: status = %d, want 200 from the panel", res.StatusCode)
+	}
+	if res.TLS == nil || res.TLS.Version < tls.VersionTLS12 {
+		t.Errorf("HTTPS: connection state %+v", res.TLS)
+	}
+
+	res = get(t, noRedirects, "http://"+addr+"/api/clients?page=2")
+	if res.StatusCode != http.StatusMovedPermanently {
+		t.Fatalf("HTTP: status = %d, want 301", res.StatusCode)
+	}
+	if want := "https://127.0.0.1:" + port + "/api/clients?page=2"; res.Header.Get("Location") != want {
+		t.Errorf("HTTP: Location = %q, want %q", res.Header.Get("Location"), want)
+	}
+
+	// Соединения без данных не мешают остальным: каждое ждет первый байт отдельно
+	idle, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer idle.Close()
+	res = get(t, httpsClient, "https://"+addr+"/")
+	if res.StatusCode != http.StatusOK {
+		t.Errorf("HTTPS with an idle connection open: status = %d", res.StatusCode)
+	}
+}
+
+func TestServeTLSFileBadCertificate(t *testing.T) {
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "cert.pem")
+	if err := os.WriteFile(certFile, []byte("not a certificate"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := &config.Config{ListenAddr: ln.Addr().String(), TLS: config.TLSConfig{Mode: config.TLSModeFile, CertFile: certFile, KeyFile: certFile}}
+
+	err = serve(context.Background(), cfg, ln, http.NotFoundHandler(), nil)
+	if err == nil || !strings.Contains(err.Error(), "failed to load TLS certificate") {
+		t.Fatalf("err = %v, want a certificate error", err)
+	}
+	if _, err := ln.Accept(); !errors.Is(err, net.ErrClosed) {
+		t.Errorf("listener is not closed: %v", err)
+	}
+}
+
+// fakeACME — ACME-сервер (RFC 8555), который сразу выдает сертификат: заказ создается в статусе
+// ready, поэтому проверки домена не нужны. Подписи JWS не проверяются.
+type fakeACME struct {
+	t  *testing.T
+	ca *testCA
+	*httptest.Server
+
+	mu       sync.Mutex
+	contacts []string
+	orders   []string
+	chain    []byte
+}
+
+func newFakeACME(t *testing.T, ca *testCA) *fakeACME {
+	f := &fakeACME{t: t, ca: ca}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/directory", func(w http.ResponseWriter, r *http.Request) {
+		f.writeJSON(w, http.StatusOK, map[string]string{
+			"newNonce":   f.URL + "/nonce",
+			"newAccount": f.URL + "/account",
+			"newOrder":   f.URL + "/order",
+			"revokeCert": f.URL + "/revoke",
+			"keyChange":  f.URL + "/key-change",
+		})
+	})
+	mux.HandleFunc("/nonce", func(w http.ResponseWriter, r *http.Request) {
+		f.nonce(w)
+		w.WriteHeader(http.StatusOK)
+	})
+	mux.HandleFunc("/account", func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Contact []string `json:"contact"`
+		}
+		f.readPayload(r, &req)
+		f.mu.Lock()
+		f.contacts = append(f.contacts, req.Contact...)
+		f.mu.Unlock()
+		w.Header().Set("Location", f.URL+"/account/1")
+		f.writeJSON(w, http.StatusCreated, map[string]string{"status": "valid"})
+	})
+	mux.HandleFunc("/order", func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Identifiers []struct{ Value string } `json:"identifiers"`
+		}
+		f.readPayload(r, &req)
+		f.mu.Lock()
+		for _, id := range req.Identifiers {
+			f.orders = append(f.orders, id.Value)
+		}
+		f.mu.Unlock()
+		w.Header().Set("Location", f.URL+"/order/1")
+		f.writeJSON(w, http.StatusCreated, map[string]any{"status": "ready", "finalize": f.URL + "/finalize"})
+	})
+	mux.HandleFunc("/finalize", func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			CSR string `json:"csr"`
+		}
+		f.readPayload(r, &req)
+		der, err := base64.RawURLEncoding.DecodeString(req.CSR)
+		if err != nil {
+			t.Errorf("fake ACME: bad CSR encoding: %v", err)
+		}
+		csr, err := x509.ParseCertificateRequest(der)
+		if err != nil {
+			t.Errorf("fake ACME: bad CSR: %v", err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		chain := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.issue(t, csr.PublicKey, csr.DNSNames...)})
+		chain = append(chain, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.cert.Raw})...)
+		f.mu.Lock()
+		f.chain = chain
+		f.mu.Unlock()
+		w.Header().Set("Location", f.URL+"/order/1")
+		f.writeJSON(w, http.StatusOK, map[string]any{"status": "valid", "certificate": f.URL + "/cert"})
+	})
+	mux.HandleFunc("/cert", func(w http.ResponseWriter, r *http.Request) {
+		f.nonce(w)
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		w.Header().Set("Content-Type", "application/pem-certificate-chain")
+		w.Write(f.chain)
+	})
+	f.Server = httptest.NewServer(mux)
+	t.Cleanup(f.Close)
+	return f
+}
+
+func (f *fakeACME) nonce(w http.ResponseWriter) {
+	nonce := make([]byte, 16)
+	rand.Read(nonce)
+	w.Header().Set("Replay-Nonce", base64.RawURLEncoding.EncodeToString(nonce))
+}
+
+func (f *fakeACME) writeJSON(w http.ResponseWriter, status int, v any) {
+	f.nonce(w)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// readPayload разбирает полезную нагрузку JWS из тела запроса
+func (f *fakeACME) readPayload(r *http.Request, v any) {
+	var jws struct {
+		Payload string `json:"payload"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&jws); err != nil {
+		f.t.Errorf("fake ACME: bad JWS: %v", err)
+		return
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(jws.Payload)
+	if err != nil {
+		f.t.Errorf("fake ACME: bad payload: %v", err)
+		return
+	}
+	if err := json.Unmarshal(payload, v); err != nil {
+		f.t.Errorf("fake ACME: bad payload JSON: %v", err)
+	}
+}
+
+// В режиме acme сертификат запрашивается у directory_url при первом TLS-рукопожатии
+// с разрешенным доменом и сохраняется в cache_dir
+func TestServeTLSACME(t *testing.T) {
+	ca := newTestCA(t)
+	acmeServer := newFakeACME(t, ca)
+	cacheDir := t.TempDir()
+	addr := startServer(t, &config.Config{TLS: config.TLSConfig{
+		Mode: config.TLSModeACME,
+		ACME: config.ACMEConfig{
+			Domains:      []string{"panel.example"},
+			Email:        "admin@panel.example",
+			DirectoryURL: acmeServer.URL + "/directory",
+			CacheDir:     cacheDir,
+		},
+	}})
+
+	dialer := &net.Dialer{Timeout: 5 * time.Second}
+	conn, err := tls.DialWithDialer(dialer, "tcp", addr, &tls.Config{ServerName: "panel.example", RootCAs: ca.pool})
+	if err != nil {
+		t.Fatalf("TLS handshake for panel.example: %v", err)
+	}
+	leaf := conn.ConnectionState().PeerCertificates[0]
+	conn.Close()
+	if leaf.Issuer.CommonName != "Test CA" || len(leaf.DNSNames) != 1 || leaf.DNSNames[0] != "panel.example" {
+		t.Errorf("certificate: issuer %q, names %q", leaf.Issuer.CommonName, leaf.DNSNames)
+	}
+
+	acmeServer.mu.Lock()
+	if len(acmeServer.contacts) != 1 || acmeServer.contacts[0] != "mailto:admin@panel.example" {
+		t.Errorf("account contacts = %q", acmeServer.contacts)
+	}
+	if len(acmeServer.orders) != 1 || acmeServer.orders[0] != "panel.example" {
+		t.Errorf("orders = %q, want [panel.example]", acmeServer.orders)
+	}
+	acmeServer.mu.Unlock()
+
+	if _, err := os.Stat(filepath.Join(cacheDir, "panel.example")); err != nil {
+		t.Errorf("certificate is not cached: %v", err)
+	}
+
+	// Домена нет в tls.acme.domains: сертификат не запрашивается
+	if conn, err := tls.DialWithDialer(dialer, "tcp", addr, &tls.Config{ServerName: "other.example", RootCAs: ca.pool}); err == nil {
+		conn.Close()
+		t.Error("TLS handshake for other.example succeeded")
+	}
+	acmeServer.mu.Lock()
+	if len(acmeServer.orders) != 1 {
+		t.Errorf("orders = %q, want only panel.example", acmeServer.orders)
+	}
+	acmeServer.mu.Unlock()
+}
+
+// Обработчик для tls.http_addr в режиме acme отвечает на проверки HTTP-01, остальное перенаправляет
+func TestACMEHTTPHandler(t *testing.T) {
+	cfg := &config.Config{TLS: config.TLSConfig{
+		Mode: config.TLSModeACME,
+		ACME: config.ACMEConfig{Domains: []string{"panel.example"}, DirectoryURL: "https://acme.invalid/directory", CacheDir: t.TempDir()},
+	}}
+	tlsConfig, httpHandler, err := newTLSConfig(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	protos := strings.Join(tlsConfig.NextProtos, ",")
+	if !strings.Contains(protos, "acme-tls/1") || tlsConfig.MinVersion != tls.VersionTLS12 {
+		t.Errorf("TLS config: NextProtos %q, MinVersion %x", protos, tlsConfig.MinVersion)
+	}
+
+	handler := httpHandler(redirectHandler("8443"))
+
+	req := httptest.NewRequest(http.MethodGet, "/.well-known/acme-challenge/unknown-token", nil)
+	req.Host = "panel.example"
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("unknown challenge token: status = %d, want 404", rec.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/login", nil)
+	req.Host = "panel.example"
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusMovedPermanently || rec.Header().Get("Location") != "https://panel.example:8443/login" {
+		t.Errorf("redirect: status %d, Location %q", rec.Code, rec.Header().Get("Location"))
+	}
+}
@@ -0,0 +1,154 @@
+package service
+
+import (
+	"antizapret-admin-panel/internal/entity"
+	"antizapret-admin-panel/internal/repository"
+	"context"
+	"errors"
+	"io"
+	"log"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrApplyInProgress возвращается, если doall.sh уже ждет в очереди или выполняется.
+	ErrApplyInProgress = errors.New("doall.sh is already queued or running")
+	// ErrConfigBusy возвращается при попытке изменить файлы AntiZapret, пока работает doall.sh
+	// или их переписывает другая операция.
+	ErrConfigBusy = errors.New("AntiZapret configuration is being applied or changed, try again later")
+)
+
+// ConfigLock не дает менять файлы, которые читает doall.sh (списки маршрутизации,
+// восстановленные из архива файлы), пока он работает. doall.sh ждет окончания изменения,
+// а изменение во время doall.sh сразу отклоняется с ErrConfigBusy.
+type ConfigLock struct {
+	mu sync.Mutex
+}
+
+// NewConfigLock — конструктор. Одна блокировка передается всем сервисам, меняющим файлы AntiZapret.
+func NewConfigLock() *ConfigLock {
+	return &ConfigLock{}
+}
+
+// TryLock захватывает блокировку для изменения файлов или возвращает ErrConfigBusy.
+func (l *ConfigLock) TryLock() error {
+	if !l.mu.TryLock() {
+		return ErrConfigBusy
+	}
+	return nil
+}
+
+// Lock ждет окончания текущего изменения файлов. Им пользуется doall.sh.
+func (l *ConfigLock) Lock() {
+	l.mu.Lock()
+}
+
+func (l *ConfigLock) Unlock() {
+	l.mu.Unlock()
+}
+
+// AntiZapretService применяет списки маршрутизации запуском doall.sh.
+type AntiZapretService interface {
+	// Apply ставит doall.sh в очередь. Если запуск уже ждет или идет, возвращает его задачу и ErrApplyInProgress.
+	Apply(startedBy string) (*entity.Job, error)
+	Status() (*entity.ApplyStatus, error)
+}
+
+type antiZapretService struct {
+	repo repository.AntiZapretRepository
+	// Отдельная очередь: doall.sh идет минутами и не должен задерживать операции с клиентами
+	jobs JobQueue
+	lock *ConfigLock
+	now  func() time.Time
+
+	mu           sync.Mutex
+	currentJobID string
+}
+
+// NewAntiZapretService — конструктор. jobs — очередь, в которой выполняется doall.sh,
+// lock — блокировка файлов AntiZapret, общая с сервисами списков и резервных копий.
+func NewAntiZapretService(repo repository.AntiZapretRepository, jobs JobQueue, lock *ConfigLock) AntiZapretService {
+	return &antiZapretService{repo: repo, jobs: jobs, lock: lock, now: time.Now}
+}
+
+func (s *antiZapretService) Apply(startedBy string) (*entity.Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if current := s.currentJob(); current != nil {
+		return current, ErrApplyInProgress
+	}
+
+	// Задача начнет выполняться не раньше, чем Apply отпустит мьютекс и запишет currentJobID
+	job, err := s.jobs.Submit(entity.Job{Action: entity.AuditActionApply, CreatedBy: startedBy}, func(ctx context.Context, output io.Writer) (any, error) {
+		s.mu.Lock()
+		jobID := s.currentJobID
+		s.mu.Unlock()
+
+		run, err := s.run(ctx, output, jobID, startedBy)
+
+		s.mu.Lock()
+		s.currentJobID = ""
+		s.mu.Unlock()
+		return run, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.currentJobID = job.ID
+	return job, nil
+}
+
+// run выполняет doall.sh и сохраняет сведения о запуске.
+func (s *antiZapretService) run(ctx context.Context, output io.Writer, jobID, startedBy string) (*entity.ApplyRun, error) {
+	// Изменение списков или восстановление занимает доли секунды: doall.sh ждет его
+	s.lock.Lock()
+	started := s.now()
+	exitCode, err := s.repo.Apply(ctx, output)
+	finished := s.now()
+	s.lock.Unlock()
+
+	run := entity.ApplyRun{
+		JobID:      jobID,
+		StartedBy:  startedBy,
+		StartedAt:  started,
+		FinishedAt: finished,
+		DurationMs: finished.Sub(started).Milliseconds(),
+		ExitCode:   exitCode,
+	}
+	if err != nil {
+		run.Error = err.Error()
+	}
+	if saveErr := s.repo.SaveRun(run); saveErr != nil {
+		log.Printf("Failed to save doall.sh run: %v", saveErr)
+	}
+	return &run, err
+}
+
+// currentJob возвращает незавершенную задачу doall.sh. Вызывается под s.mu.
+func (s *antiZapretService) currentJob() *entity.Job {
+	if s.currentJobID == "" {
+		return nil
+	}
+	job, err := s.jobs.Get(s.currentJobID)
+	if err != nil || job.Finished() {
+		return nil
+	}
+	return job
+}
+
+func (s *antiZapretService) Status() (*entity.ApplyStatus, error) {
+	last, lastSuccess, err := s.repo.LastRuns()
+	if err != nil {
+		return nil, err
+	}
+
+	status := &entity.ApplyStatus{LastRun: last, LastSuccess: lastSuccess}
+	s.mu.Lock()
+	if current := s.currentJob(); current != nil {
+		status.CurrentJobID = current.ID
+	}
+	s.mu.Unlock()
+	return status, nil
+}
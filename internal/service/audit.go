@@ -0,0 +1,51 @@
+package service
+
+import (
+	"antizapret-admin-panel/internal/entity"
+	"antizapret-admin-panel/internal/repository"
+	"log"
+	"time"
+)
+
+// Вывод скрипта длиннее этого размера обрезается, чтобы журнал не разрастался
+const maxAuditOutputSize = 16 * 1024
+
+// AuditService записывает действия администраторов и отдает журнал.
+type AuditService interface {
+	Record(entry entity.AuditEntry)
+	List(filter entity.AuditFilter, page, limit int) (*entity.PaginatedAuditEntries, error)
+}
+
+type auditService struct {
+	repo repository.AuditRepository
+	now  func() time.Time
+}
+
+// NewAuditService — конструктор сервиса аудита.
+func NewAuditService(repo repository.AuditRepository) AuditService {
+	return &auditService{repo: repo, now: time.Now}
+}
+
+// Record проставляет записи ID и время и сохраняет ее. Ошибка записи журнала
+// не должна отменять уже выполненное действие, поэтому она только логируется.
+func (s *auditService) Record(entry entity.AuditEntry) {
+	id, err := randomHex(8)
+	if err != nil {
+		log.Printf("Failed to generate audit entry ID: %v", err)
+	}
+	entry.ID = id
+	entry.Time = s.now()
+
+	if len(entry.Output) > maxAuditOutputSize {
+		entry.Output = entry.Output[:maxAuditOutputSize] + "\n... (truncated)"
+	}
+
+	if err := s.repo.Append(entry); err != nil {
+		log.Printf("Failed to write audit entry %s for %s: %v", entry.Action, entry.Actor, err)
+	}
+}
+
+// List возвращает страницу журнала, новые записи первыми.
+func (s *auditService) List(filter entity.AuditFilter, page, limit int) (*entity.PaginatedAuditEntries, error) {
+	return s.repo.Find(filter, page, limit)
+}
@@ -0,0 +1,302 @@
+package service
+
+import (
+	"antizapret-admin-panel/internal/entity"
+	"antizapret-admin-panel/internal/repository"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// DefaultSessionTTL — время жизни сессии, если SESSION_TTL не задан
+const DefaultSessionTTL = 12 * time.Hour
+
+// Время на ввод кода TOTP после проверки пароля и число попыток на один вход
+const (
+	totpChallengeTTL      = 5 * time.Minute
+	totpChallengeAttempts = 5
+)
+
+var (
+	// ErrInvalidToken возвращается для неизвестного, просроченного или отозванного токена
+	ErrInvalidToken = errors.New("invalid token")
+	// ErrTOTPRequired — базовая ошибка для входа, которому нужен второй фактор, см. TOTPRequiredError
+	ErrTOTPRequired = errors.New("two-factor code required")
+	// ErrInvalidChallenge возвращается для неизвестного, просроченного или исчерпанного второго шага входа
+	ErrInvalidChallenge = errors.New("invalid or expired login challenge")
+)
+
+// TOTPRequiredError возвращается из Login, когда пароль верен, но у пользователя подключен TOTP.
+// Сессия выдается только после VerifyTOTP с этим Challenge.
+type TOTPRequiredError struct {
+	Challenge string
+	ExpiresAt time.Time
+}
+
+func (e *TOTPRequiredError) Error() string {
+	return fmt.Sprintf("%v until %s", ErrTOTPRequired, e.ExpiresAt.Format(time.RFC3339))
+}
+
+func (e *TOTPRequiredError) Is(target error) bool {
+	return target == ErrTOTPRequired
+}
+
+// totpChallenge — вход, прошедший проверку пароля и ожидающий код TOTP
+type totpChallenge struct {
+	username  string
+	ip        string
+	expiresAt time.Time
+	attempts  int
+}
+
+// AuthService выдает, проверяет и отзывает сессии входа в панель.
+type AuthService interface {
+	Login(username, password, ip, userAgent string) (string, *entity.Session, error)
+	VerifyTOTP(challenge, code, ip, userAgent string) (string, *entity.Session, error)
+	ValidateToken(token string) (*entity.User, *entity.Session, error)
+	Logout(token string) error
+	ListSessions() ([]entity.Session, error)
+	RevokeSession(id string) error
+	ListLoginLocks() []entity.LoginLock
+	UnlockLogin(kind, key string) bool
+}
+
+type authService struct {
+	users    UserService
+	totp     TOTPService
+	sessions repository.SessionRepository
+	limiter  LoginLimiter
+	ttl      time.Duration
+	now      func() time.Time
+
+	mu         sync.Mutex
+	challenges map[string]*totpChallenge // SHA-256 challenge -> вход
+}
+
+// NewAuthService — конструктор сервиса аутентификации.
+func NewAuthService(users UserService, totp TOTPService, sessions repository.SessionRepository, limiter LoginLimiter, ttl time.Duration) AuthService {
+	if ttl <= 0 {
+		ttl = DefaultSessionTTL
+	}
+	return &authService{
+		users:      users,
+		totp:       totp,
+		sessions:   sessions,
+		limiter:    limiter,
+		ttl:        ttl,
+		now:        time.Now,
+		challenges: make(map[string]*totpChallenge),
+	}
+}
+
+// Login проверяет пароль и открывает сессию. Клиенту возвращается случайный токен,
+// на сервере хранится только его SHA-256.
+// Пока IP или логин заблокированы после неудачных попыток, пароль не проверяется.
+// Если у пользователя подключен TOTP, вместо сессии возвращается *TOTPRequiredError.
+func (s *authService) Login(username, password, ip, userAgent string) (string, *entity.Session, error) {
+	if err := s.limiter.Check(ip, username); err != nil {
+		return "", nil, err
+	}
+
+	user, err := s.users.Authenticate(username, password)
+	if errors.Is(err, ErrInvalidCredentials) {
+		s.limiter.RecordFailure(ip, username)
+		log.Printf("Failed login for %q from %s", username, ip)
+		return "", nil, err
+	}
+	if err != nil {
+		s.limiter.Release(ip, username)
+		return "", nil, err
+	}
+
+	if user.TOTPEnabled {
+		// Пароль верен, но вход еще не завершен: счетчик не сбрасывается до кода TOTP
+		s.limiter.Release(ip, username)
+		return "", nil, s.newChallenge(user.Username, ip)
+	}
+
+	s.limiter.RecordSuccess(ip, username)
+	return s.createSession(user, ip, userAgent)
+}
+
+// VerifyTOTP завершает вход с TOTP: проверяет код или код восстановления и открывает сессию.
+// Неверные коды учитываются ограничителем попыток так же, как неверные пароли.
+func (s *authService) VerifyTOTP(challenge, code, ip, userAgent string) (string, *entity.Session, error) {
+	key := hashToken(challenge)
+
+	s.mu.Lock()
+	pending, ok := s.challenges[key]
+	if ok && (!s.now().Before(pending.expiresAt) || pending.ip != ip) {
+		delete(s.challenges, key)
+		ok = false
+	}
+	s.mu.Unlock()
+	if !ok {
+		return "", nil, ErrInvalidChallenge
+	}
+
+	if err := s.limiter.Check(ip, pending.username); err != nil {
+		return "", nil, err
+	}
+
+	err := s.totp.Verify(pending.username, code)
+	if errors.Is(err, ErrInvalidTOTPCode) {
+		s.limiter.RecordFailure(ip, pending.username)
+		log.Printf("Invalid two-factor code for %q from %s", pending.username, ip)
+
+		s.mu.Lock()
+		pending.attempts++
+		if pending.attempts >= totpChallengeAttempts {
+			delete(s.challenges, key)
+		}
+		s.mu.Unlock()
+		return "", nil, err
+	}
+	if err != nil {
+		s.limiter.Release(ip, pending.username)
+		return "", nil, err
+	}
+
+	s.mu.Lock()
+	delete(s.challenges, key)
+	s.mu.Unlock()
+
+	user, err := s.users.GetUser(pending.username)
+	if err != nil {
+		s.limiter.Release(ip, pending.username)
+		return "", nil, ErrInvalidChallenge
+	}
+
+	s.limiter.RecordSuccess(ip, pending.username)
+	return s.createSession(user, ip, userAgent)
+}
+
+// newChallenge запоминает вход, ожидающий второй фактор, и возвращает его как *TOTPRequiredError.
+func (s *authService) newChallenge(username, ip string) error {
+	challenge, err := randomHex(32)
+	if err != nil {
+		return err
+	}
+
+	now := s.now()
+	expiresAt := now.Add(totpChallengeTTL)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for key, pending := range s.challenges {
+		if !now.Before(pending.expiresAt) {
+			delete(s.challenges, key)
+		}
+	}
+	s.challenges[hashToken(challenge)] = &totpChallenge{username: username, ip: ip, expiresAt: expiresAt}
+
+	return &TOTPRequiredError{Challenge: challenge, ExpiresAt: expiresAt}
+}
+
+func (s *authService) createSession(user *entity.User, ip, userAgent string) (string, *entity.Session, error) {
+	now := s.now()
+	if err := s.sessions.DeleteExpired(now); err != nil {
+		log.Printf("Failed to delete expired sessions: %v", err)
+	}
+
+	token, err := randomHex(32)
+	if err != nil {
+		return "", nil, err
+	}
+	id, err := randomHex(8)
+	if err != nil {
+		return "", nil, err
+	}
+
+	session := entity.Session{
+		ID:        id,
+		Username:  user.Username,
+		TokenHash: hashToken(token),
+		IP:        ip,
+		UserAgent: userAgent,
+		CreatedAt: now,
+		ExpiresAt: now.Add(s.ttl),
+	}
+	if err := s.sessions.Create(session); err != nil {
+		return "", nil, err
+	}
+
+	return token, &session, nil
+}
+
+// ValidateToken возвращает пользователя и сессию по токену.
+// Сессия недействительна, если истекла, если пользователь удален
+// или если пароль был сменен после входа.
+func (s *authService) ValidateToken(token string) (*entity.User, *entity.Session, error) {
+	if token == "" {
+		return nil, nil, ErrInvalidToken
+	}
+
+	session, err := s.sessions.FindByTokenHash(hashToken(token))
+	if err != nil {
+		return nil, nil, ErrInvalidToken
+	}
+	if !s.now().Before(session.ExpiresAt) {
+		return nil, nil, ErrInvalidToken
+	}
+
+	user, err := s.users.GetUser(session.Username)
+	if err != nil {
+		return nil, nil, ErrInvalidToken
+	}
+	if session.CreatedAt.Before(user.PasswordChangedAt) {
+		return nil, nil, ErrInvalidToken
+	}
+
+	return user, session, nil
+}
+
+// Logout отзывает сессию, которой принадлежит токен.
+func (s *authService) Logout(token string) error {
+	session, err := s.sessions.FindByTokenHash(hashToken(token))
+	if err != nil {
+		return ErrInvalidToken
+	}
+	return s.sessions.Delete(session.ID)
+}
+
+// ListSessions возвращает все действующие сессии.
+func (s *authService) ListSessions() ([]entity.Session, error) {
+	if err := s.sessions.DeleteExpired(s.now()); err != nil {
+		return nil, err
+	}
+	return s.sessions.FindAll()
+}
+
+// RevokeSession отзывает сессию по ID.
+func (s *authService) RevokeSession(id string) error {
+	return s.sessions.Delete(id)
+}
+
+// ListLoginLocks возвращает IP-адреса и логины, заблокированные после неудачных попыток входа.
+func (s *authService) ListLoginLocks() []entity.LoginLock {
+	return s.limiter.Locks()
+}
+
+// UnlockLogin снимает блокировку входа досрочно.
+func (s *authService) UnlockLogin(kind, key string) bool {
+	return s.limiter.Unlock(kind, key)
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func randomHex(length int) (string, error) {
+	bytes := make([]byte, length)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(bytes), nil
+}
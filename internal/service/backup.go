@@ -0,0 +1,143 @@
+package service
+
+import (
+	"antizapret-admin-panel/internal/entity"
+	"antizapret-admin-panel/internal/repository"
+	"context"
+	"fmt"
+	"io"
+	"log"
+)
+
+// BackupService создает резервные копии AntiZapret, управляет архивами и восстанавливает из них файлы.
+type BackupService interface {
+	// Create собирает архив и удаляет старые сверх лимита. Загруженные архивы и архивы
+	// перед восстановлением в лимит не входят. Возвращает созданный архив и имена удаленных.
+	Create(ctx context.Context, output io.Writer) (*entity.Backup, []string, error)
+	// Upload проверяет загруженный архив, сохраняет его и показывает, что изменит восстановление.
+	// Неверный архив — repository.ErrInvalidBackup.
+	Upload(r io.Reader) (*entity.Backup, *entity.RestorePreview, error)
+	List() ([]entity.Backup, error)
+	// Path возвращает путь к архиву или repository.ErrBackupNotFound
+	Path(name string) (string, error)
+	Delete(name string) error
+	// Preview проверяет архив и сравнивает его с файлами сервера, ничего не меняя
+	Preview(name string) (*entity.RestorePreview, error)
+	// Restore сохраняет текущее состояние в новый архив и записывает файлы из указанного.
+	// Лишние файлы на сервере не удаляются. Во время doall.sh возвращает ErrConfigBusy.
+	Restore(ctx context.Context, name string, output io.Writer) (*entity.RestoreResult, error)
+}
+
+type backupService struct {
+	repo repository.BackupRepository
+	// Сколько последних архивов хранить, 0 — без ограничения
+	keep int
+	// Восстановление не переписывает списки, пока их читает doall.sh
+	lock *ConfigLock
+}
+
+// NewBackupService — конструктор. keep — сколько последних архивов оставлять после создания нового, 0 — все.
+// lock — блокировка файлов AntiZapret, общая с doall.sh.
+func NewBackupService(repo repository.BackupRepository, keep int, lock *ConfigLock) BackupService {
+	return &backupService{repo: repo, keep: keep, lock: lock}
+}
+
+func (s *backupService) Create(ctx context.Context, output io.Writer) (*entity.Backup, []string, error) {
+	backup, err := s.repo.Create(ctx, entity.BackupKindCreated, output)
+	if err != nil {
+		return nil, nil, err
+	}
+	return backup, s.prune(output), nil
+}
+
+// prune удаляет самые старые созданные архивы сверх лимита. Загруженный архив может ждать
+// восстановления, поэтому загруженные и архивы перед восстановлением удаляются только вручную.
+// Ошибка удаления не отменяет созданный архив.
+func (s *backupService) prune(output io.Writer) []string {
+	pruned := []string{}
+	if s.keep <= 0 {
+		return pruned
+	}
+	backups, err := s.repo.FindAll()
+	if err != nil {
+		log.Printf("Failed to list backups for pruning: %v", err)
+		return pruned
+	}
+	kept := 0
+	for _, backup := range backups {
+		if backup.Kind != entity.BackupKindCreated {
+			continue
+		}
+		// Архивы идут от новых к старым
+		kept++
+		if kept <= s.keep {
+			continue
+		}
+		if err := s.repo.Delete(backup.Name); err != nil {
+			log.Printf("Failed to prune backup %s: %v", backup.Name, err)
+			continue
+		}
+		fmt.Fprintf(output, "Old backup %s removed\n", backup.Name)
+		pruned = append(pruned, backup.Name)
+	}
+	return pruned
+}
+
+func (s *backupService) Upload(r io.Reader) (*entity.Backup, *entity.RestorePreview, error) {
+	backup, archive, err := s.repo.Save(r)
+	if err != nil {
+		return nil, nil, err
+	}
+	preview, err := s.repo.Diff(archive)
+	if err != nil {
+		return backup, nil, err
+	}
+	return backup, preview, nil
+}
+
+func (s *backupService) List() ([]entity.Backup, error) {
+	return s.repo.FindAll()
+}
+
+func (s *backupService) Path(name string) (string, error) {
+	return s.repo.FindPath(name)
+}
+
+func (s *backupService) Delete(name string) error {
+	return s.repo.Delete(name)
+}
+
+func (s *backupService) Preview(name string) (*entity.RestorePreview, error) {
+	archive, err := s.repo.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return s.repo.Diff(archive)
+}
+
+func (s *backupService) Restore(ctx context.Context, name string, output io.Writer) (*entity.RestoreResult, error) {
+	archive, err := s.repo.Open(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.lock.TryLock(); err != nil {
+		return nil, err
+	}
+	defer s.lock.Unlock()
+
+	// Без лимита: иначе новый архив мог бы вытеснить тот, из которого идет восстановление
+	fmt.Fprintln(output, "Saving current state before restore")
+	safety, err := s.repo.Create(ctx, entity.BackupKindPreRestore, output)
+	if err != nil {
+		return nil, fmt.Errorf("failed to back up current state: %w", err)
+	}
+
+	fmt.Fprintf(output, "Restoring %s\n", name)
+	preview, err := s.repo.Restore(archive, output)
+	if err != nil {
+		// Часть файлов могла быть уже записана: вернуть состояние можно из SafetyBackup
+		return &entity.RestoreResult{SafetyBackup: safety.Name}, err
+	}
+	return &entity.RestoreResult{RestorePreview: *preview, SafetyBackup: safety.Name}, nil
+}
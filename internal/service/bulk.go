@@ -0,0 +1,97 @@
+package service
+
+import (
+	"antizapret-admin-panel/internal/entity"
+	"antizapret-admin-panel/internal/repository"
+	"context"
+	"errors"
+	"fmt"
+)
+
+// MaxBulkClients — наибольшее число клиентов в одном пакете
+const MaxBulkClients = 100
+
+var (
+	// ErrBulkInvalid возвращается, если хотя бы одна строка пакета не прошла проверку
+	ErrBulkInvalid = errors.New("bulk request contains invalid rows")
+	// ErrBulkEmpty возвращается для пакета без строк
+	ErrBulkEmpty = errors.New("bulk request contains no clients")
+	// ErrBulkTooLarge возвращается для пакета больше MaxBulkClients строк
+	ErrBulkTooLarge = fmt.Errorf("bulk request is limited to %d clients", MaxBulkClients)
+)
+
+func (s *clientService) ValidateBulk(rows []entity.BulkClientRow) ([]entity.BulkClientResult, error) {
+	if len(rows) == 0 {
+		return nil, ErrBulkEmpty
+	}
+	if len(rows) > MaxBulkClients {
+		return nil, ErrBulkTooLarge
+	}
+
+	existing, err := s.repo.FindAll()
+	if err != nil {
+		return nil, err
+	}
+	taken := make(map[string]bool, len(existing)+len(rows))
+	for _, client := range existing {
+		if client.Status != entity.ClientStatusRevoked {
+			taken[client.Type+"/"+client.Name] = true
+		}
+	}
+
+	results := make([]entity.BulkClientResult, len(rows))
+	invalid := false
+	for i, row := range rows {
+		results[i] = entity.BulkClientResult{Row: i + 1, Name: row.Name, Type: row.Type, Status: entity.BulkRowValid}
+		key := row.Type + "/" + row.Name
+
+		err := validateClient(row.Name, row.Type, row.ExpiresIn)
+		if err == nil && taken[key] {
+			err = errors.New("client with this name already exists or is repeated in the request")
+		}
+		taken[key] = true
+
+		if err != nil {
+			results[i].Status = entity.BulkRowInvalid
+			results[i].Error = err.Error()
+			var validationErr *ValidationError
+			if errors.As(err, &validationErr) {
+				results[i].Fields = validationErr.Fields
+			}
+			invalid = true
+		}
+	}
+	if invalid {
+		return results, ErrBulkInvalid
+	}
+	return results, nil
+}
+
+func (s *clientService) CreateClients(ctx context.Context, rows []entity.BulkClientRow) *entity.BulkClientReport {
+	report := &entity.BulkClientReport{Results: make([]entity.BulkClientResult, len(rows))}
+	stopped := false
+	for i, row := range rows {
+		result := &report.Results[i]
+		*result = entity.BulkClientResult{Row: i + 1, Name: row.Name, Type: row.Type}
+		// После отказа в запуске скрипта (остановка панели) остальные строки не обрабатываются
+		if stopped {
+			result.Status = entity.BulkRowSkipped
+			report.Skipped++
+			continue
+		}
+
+		client, output, err := s.CreateClient(ctx, row.Name, row.Type, row.ExpiresIn)
+		result.Output = output
+		if err != nil {
+			result.Status = entity.BulkRowFailed
+			result.Error = err.Error()
+			report.Failed++
+			stopped = errors.Is(err, repository.ErrShuttingDown) || ctx.Err() != nil
+			continue
+		}
+		result.Status = entity.BulkRowCreated
+		result.Client = client
+		report.Created++
+	}
+	return report
+}
@@ -3,10 +3,23 @@ package service
 import (
 	"antizapret-admin-panel/internal/entity"
 	"antizapret-admin-panel/internal/repository"
-	"fmt"
+	"context"
+	"errors"
+	"log"
 	"time"
 )
 
+// ErrClientRevoked возвращается при попытке удалить уже отозванного клиента.
+// Повторный вызов client.sh отозвал бы новый сертификат с тем же именем.
+var ErrClientRevoked = errors.New("client certificate is already revoked")
+
+// ErrRenewNotSupported возвращается при попытке продлить клиента без сертификата (WireGuard/AmneziaWG).
+var ErrRenewNotSupported = errors.New("certificate renewal is only supported for OpenVPN clients")
+
+// ErrCertificateExpired возвращается при попытке продлить сертификат после его notAfter:
+// easyrsa не может переподписать просроченный сертификат.
+var ErrCertificateExpired = errors.New("certificate renewal is not possible after notAfter date")
+
 // ClientService — это интерфейс нашего сервиса.
 // Он определяет высокоуровневые бизнес-операции.
 // Хендлер будет зависеть именно от этого интерфейса.
@@ -15,9 +28,21 @@ type ClientService interface {
 	ListClientsPaginated(page, limit int) (*entity.PaginatedClients, error)
 	GetClientConfigPath(name string) (string, error)
 	GetClientConfigPathByType(name, configType string) (string, error)
-	CreateClient(name string, expiresIn int) (*entity.Client, error)
-	DeleteClient(id int) error
-	GetClientByID(id int) (*entity.Client, error)
+	GetWireGuardConfigPath(name, configType, flavor string) (string, error)
+	GetOpenVPNConfigPath(name, variant string) (string, error)
+	GetProfiles(name string) ([]entity.ClientProfile, error)
+	// ValidateClient проверяет нового клиента по правилам client.sh и на занятость имени.
+	// Возвращает *ValidationError или ErrClientExists.
+	ValidateClient(name, clientType string, expiresIn int) error
+	// Операции, запускающие client.sh, дополнительно возвращают его вывод для журнала аудита
+	CreateClient(ctx context.Context, name, clientType string, expiresIn int) (*entity.Client, string, error)
+	DeleteClient(ctx context.Context, id string) (*entity.Client, string, error)
+	RenewClient(ctx context.Context, id string, expiresIn int) (*entity.Client, string, error)
+	// ValidateBulk проверяет пакет целиком до запуска client.sh. При ErrBulkInvalid отчет содержит ошибки по строкам.
+	ValidateBulk(rows []entity.BulkClientRow) ([]entity.BulkClientResult, error)
+	// CreateClients создает клиентов пакета по одному; ошибка строки не останавливает остальные.
+	CreateClients(ctx context.Context, rows []entity.BulkClientRow) *entity.BulkClientReport
+	GetClientByID(id string) (*entity.Client, error)
 }
 
 // clientService — конкретная реализация сервиса.
@@ -55,46 +80,114 @@ func (s *clientService) GetClientConfigPathByType(name, configType string) (stri
 	return s.repo.FindConfigPathByNameAndType(name, configType)
 }
 
-// CreateClient создает нового клиента.
-func (s *clientService) CreateClient(name string, expiresIn int) (*entity.Client, error) {
-	err := s.repo.Create(name, expiresIn)
+// GetWireGuardConfigPath возвращает путь к профилю WireGuard или AmneziaWG.
+func (s *clientService) GetWireGuardConfigPath(name, configType, flavor string) (string, error) {
+	return s.repo.FindWireGuardConfigPath(name, configType, flavor)
+}
+
+// GetOpenVPNConfigPath возвращает путь к профилю OpenVPN указанного варианта (см. entity.OpenVPNVariants).
+func (s *clientService) GetOpenVPNConfigPath(name, variant string) (string, error) {
+	return s.repo.FindOpenVPNConfigPath(name, variant)
+}
+
+// GetProfiles возвращает все профили клиента, найденные на диске.
+func (s *clientService) GetProfiles(name string) ([]entity.ClientProfile, error) {
+	return s.repo.FindProfiles(name)
+}
+
+func (s *clientService) ValidateClient(name, clientType string, expiresIn int) error {
+	if err := validateClient(name, clientType, expiresIn); err != nil {
+		return err
+	}
+	// Для занятого имени client.sh перевыпустил бы сертификат существующего клиента.
+	// Имя отозванного клиента можно использовать снова.
+	existing, err := s.repo.FindByName(name, clientType)
+	if err == nil && existing.Status != entity.ClientStatusRevoked {
+		return ErrClientExists
+	}
+	if err != nil && !errors.Is(err, repository.ErrClientNotFound) {
+		return err
+	}
+	return nil
+}
+
+// CreateClient создает нового клиента указанного типа (entity.ClientTypeOpenVPN или entity.ClientTypeWireGuard).
+// Перед запуском client.sh клиент проверяется через ValidateClient.
+func (s *clientService) CreateClient(ctx context.Context, name, clientType string, expiresIn int) (*entity.Client, string, error) {
+	if err := s.ValidateClient(name, clientType, expiresIn); err != nil {
+		return nil, "", err
+	}
+
+	output, err := s.repo.Create(ctx, name, clientType, expiresIn)
 	if err != nil {
-		return nil, err
+		return nil, output, err
+	}
+	// После успешного создания скриптом клиент уже виден в репозитории
+	// и получает постоянный ID.
+	created, err := s.repo.FindByName(name, clientType)
+	if err == nil {
+		return created, output, nil
 	}
-	// После успешного создания скриптом, мы можем вернуть сущность.
-	// ID и другие поля здесь не так важны, фронтенд обычно просто обновляет список.
+	log.Printf("Client %s created but not found in repository: %v", name, err)
+
 	newClient := &entity.Client{
-		ID:        -1, // ID будет пересчитан при следующем вызове ListClients
 		Name:      name,
-		Type:      "OpenVPN", // TODO: сделать тип динамическим
-		Status:    "Active",
+		Type:      clientType,
+		Status:    entity.ClientStatusActive,
 		CreatedAt: time.Now(),
 	}
-	return newClient, nil
+	return newClient, output, nil
+}
+
+// GetClientByID находит клиента по его постоянному ID.
+func (s *clientService) GetClientByID(id string) (*entity.Client, error) {
+	return s.repo.FindByID(id)
 }
 
-// GetClientByID находит клиента по его ID.
-func (s *clientService) GetClientByID(id int) (*entity.Client, error) {
-	clients, err := s.repo.FindAll()
+// DeleteClient находит клиента по ID и удаляет его по имени.
+// Найденный клиент возвращается и при ошибке удаления.
+func (s *clientService) DeleteClient(ctx context.Context, id string) (*entity.Client, string, error) {
+	client, err := s.GetClientByID(id)
 	if err != nil {
-		return nil, err
+		return nil, "", err // Ошибка, если клиент не найден
 	}
-
-	for _, client := range clients {
-		if client.ID == id {
-			return &client, nil
-		}
+	if client.Status == entity.ClientStatusRevoked {
+		return client, "", ErrClientRevoked
 	}
 
-	return nil, fmt.Errorf("client with ID %d not found", id)
+	output, err := s.repo.DeleteByName(ctx, client.Name, client.Type)
+	return client, output, err
 }
 
-// DeleteClient находит клиента по ID и удаляет его по имени.
-func (s *clientService) DeleteClient(id int) error {
+// RenewClient перевыпускает сертификат OpenVPN-клиента на expiresIn дней
+// и возвращает клиента с новым сроком действия. Найденный клиент возвращается и при ошибке.
+func (s *clientService) RenewClient(ctx context.Context, id string, expiresIn int) (*entity.Client, string, error) {
 	client, err := s.GetClientByID(id)
 	if err != nil {
-		return err // Ошибка, если клиент не найден
+		return nil, "", err
 	}
 
-	return s.repo.DeleteByName(client.Name)
+	if err := ValidateExpiresIn(expiresIn); err != nil {
+		return client, "", err
+	}
+	if client.Type != entity.ClientTypeOpenVPN {
+		return client, "", ErrRenewNotSupported
+	}
+	if client.Status == entity.ClientStatusRevoked {
+		return client, "", ErrClientRevoked
+	}
+	if client.NotAfter != nil && time.Now().After(*client.NotAfter) {
+		return client, "", ErrCertificateExpired
+	}
+
+	output, err := s.repo.Renew(ctx, client.Name, expiresIn)
+	if err != nil {
+		return client, output, err
+	}
+
+	renewed, err := s.repo.FindByName(client.Name, client.Type)
+	if err != nil {
+		return client, output, err
+	}
+	return renewed, output, nil
 }
@@ -0,0 +1,160 @@
+package service
+
+import (
+	"antizapret-admin-panel/internal/entity"
+	"antizapret-admin-panel/internal/repository"
+	"errors"
+	"log"
+	"path/filepath"
+	"time"
+)
+
+// Ограничения ссылок на скачивание. По умолчанию ссылка живет 5 минут и работает один раз.
+const (
+	DefaultDownloadTokenTTL = 5 * time.Minute
+	MaxDownloadTokenTTL     = 30 * 24 * time.Hour
+	MaxDownloadTokenUses    = 100
+)
+
+var (
+	// ErrInvalidDownloadToken возвращается для неизвестной, просроченной или исчерпанной ссылки
+	ErrInvalidDownloadToken = errors.New("invalid or expired download token")
+	// ErrInvalidTokenTTL возвращается, если срок жизни ссылки вне допустимых границ
+	ErrInvalidTokenTTL = errors.New("token lifetime must be between 1 minute and 30 days")
+	// ErrInvalidTokenUses возвращается, если число скачиваний вне допустимых границ
+	ErrInvalidTokenUses = errors.New("token max uses must be between 1 and 100")
+)
+
+// DownloadTokenService выдает и проверяет ссылки на скачивание конфигов без входа в панель.
+type DownloadTokenService interface {
+	Issue(client *entity.Client, filePath, createdBy string, ttl time.Duration, maxUses int) (string, *entity.DownloadToken, error)
+	Redeem(token string) (*entity.DownloadToken, error)
+	// Lookup возвращает действующую ссылку, не засчитывая скачивание
+	Lookup(token string) (*entity.DownloadToken, error)
+	// List и Revoke с непустым createdBy работают только со ссылками этого пользователя
+	List(createdBy string) ([]entity.DownloadToken, error)
+	Revoke(id, createdBy string) (*entity.DownloadToken, error)
+	// RevokeByClient отзывает все ссылки клиента, например после его удаления
+	RevokeByClient(clientID string) ([]entity.DownloadToken, error)
+}
+
+type downloadTokenService struct {
+	store repository.TokenStore
+	now   func() time.Time
+}
+
+// NewDownloadTokenService — конструктор сервиса ссылок на скачивание.
+func NewDownloadTokenService(store repository.TokenStore) DownloadTokenService {
+	return &downloadTokenService{store: store, now: time.Now}
+}
+
+// Issue выпускает ссылку на файл конфига клиента, действующую ttl и maxUses скачиваний.
+// Нулевые значения означают значения по умолчанию.
+func (s *downloadTokenService) Issue(client *entity.Client, filePath, createdBy string, ttl time.Duration, maxUses int) (string, *entity.DownloadToken, error) {
+	if ttl == 0 {
+		ttl = DefaultDownloadTokenTTL
+	}
+	if ttl < time.Minute || ttl > MaxDownloadTokenTTL {
+		return "", nil, ErrInvalidTokenTTL
+	}
+	if maxUses == 0 {
+		maxUses = 1
+	}
+	if maxUses < 1 || maxUses > MaxDownloadTokenUses {
+		return "", nil, ErrInvalidTokenUses
+	}
+
+	now := s.now()
+	if err := s.store.DeleteExpired(now); err != nil {
+		log.Printf("Failed to delete expired download tokens: %v", err)
+	}
+
+	token, err := randomHex(20)
+	if err != nil {
+		return "", nil, err
+	}
+	id, err := randomHex(8)
+	if err != nil {
+		return "", nil, err
+	}
+
+	downloadToken := entity.DownloadToken{
+		ID:         id,
+		TokenHash:  hashToken(token),
+		FilePath:   filePath,
+		FileName:   filepath.Base(filePath),
+		ClientID:   client.ID,
+		ClientName: client.Name,
+		ClientType: client.Type,
+		CreatedBy:  createdBy,
+		CreatedAt:  now,
+		ExpiresAt:  now.Add(ttl),
+		MaxUses:    maxUses,
+	}
+	if err := s.store.Create(downloadToken); err != nil {
+		return "", nil, err
+	}
+	return token, &downloadToken, nil
+}
+
+// Redeem засчитывает скачивание по ссылке и возвращает ее данные.
+func (s *downloadTokenService) Redeem(token string) (*entity.DownloadToken, error) {
+	downloadToken, err := s.store.Use(hashToken(token), s.now())
+	if errors.Is(err, repository.ErrTokenNotFound) {
+		return nil, ErrInvalidDownloadToken
+	}
+	return downloadToken, err
+}
+
+func (s *downloadTokenService) Lookup(token string) (*entity.DownloadToken, error) {
+	tokens, err := s.store.FindAll()
+	if err != nil {
+		return nil, err
+	}
+	hash := hashToken(token)
+	now := s.now()
+	for _, downloadToken := range tokens {
+		if downloadToken.TokenHash == hash && now.Before(downloadToken.ExpiresAt) && downloadToken.Uses < downloadToken.MaxUses {
+			return &downloadToken, nil
+		}
+	}
+	return nil, ErrInvalidDownloadToken
+}
+
+// List возвращает действующие ссылки, новые первыми.
+func (s *downloadTokenService) List(createdBy string) ([]entity.DownloadToken, error) {
+	if err := s.store.DeleteExpired(s.now()); err != nil {
+		return nil, err
+	}
+	tokens, err := s.store.FindAll()
+	if err != nil || createdBy == "" {
+		return tokens, err
+	}
+
+	own := []entity.DownloadToken{}
+	for _, token := range tokens {
+		if token.CreatedBy == createdBy {
+			own = append(own, token)
+		}
+	}
+	return own, nil
+}
+
+// Revoke отзывает ссылку по ID и возвращает ее данные для журнала аудита.
+func (s *downloadTokenService) Revoke(id, createdBy string) (*entity.DownloadToken, error) {
+	tokens, err := s.store.FindAll()
+	if err != nil {
+		return nil, err
+	}
+	for _, token := range tokens {
+		// Чужая ссылка для такого пользователя не существует
+		if token.ID == id && (createdBy == "" || token.CreatedBy == createdBy) {
+			return &token, s.store.Delete(id)
+		}
+	}
+	return nil, repository.ErrTokenNotFound
+}
+
+func (s *downloadTokenService) RevokeByClient(clientID string) ([]entity.DownloadToken, error) {
+	return s.store.DeleteByClient(clientID)
+}
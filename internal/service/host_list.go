@@ -0,0 +1,324 @@
+package service
+
+import (
+	"antizapret-admin-panel/internal/entity"
+	"antizapret-admin-panel/internal/repository"
+	"fmt"
+	"net/netip"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"golang.org/x/net/idna"
+)
+
+// domainLabelPattern — метка доменного имени после перевода в punycode
+var domainLabelPattern = regexp.MustCompile(`^[a-z0-9_]([a-z0-9_-]{0,61}[a-z0-9_])?$`)
+
+// HostListService управляет списками маршрутизации AntiZapret.
+// Записи приводятся к одному виду (домены — к нижнему регистру и punycode, подсети — к адресу сети),
+// повторы убираются. Комментарии в файлах сохраняются. Изменения вступают в силу после doall.sh;
+// пока он работает, изменения отклоняются с ErrConfigBusy.
+type HostListService interface {
+	// ListAll возвращает списки без записей, только с их количеством
+	ListAll() ([]entity.HostList, error)
+	Get(name string) (*entity.HostList, error)
+	// Add, Remove и Replace возвращают *ValidationError для неверных записей
+	Add(name string, entries []string) (*entity.HostListChange, error)
+	Remove(name string, entries []string) (*entity.HostListChange, error)
+	Replace(name string, entries []string) (*entity.HostListChange, error)
+}
+
+type hostListService struct {
+	repo repository.HostListRepository
+	// Изменение списка — чтение и перезапись файла, они не должны перемежаться
+	mu sync.Mutex
+	// Списки не переписываются, пока их читает doall.sh
+	lock *ConfigLock
+}
+
+// NewHostListService — конструктор сервиса списков. lock — блокировка файлов AntiZapret, общая с doall.sh.
+func NewHostListService(repo repository.HostListRepository, lock *ConfigLock) HostListService {
+	return &hostListService{repo: repo, lock: lock}
+}
+
+func (s *hostListService) ListAll() ([]entity.HostList, error) {
+	names := make([]string, 0, len(entity.HostListKinds))
+	for name := range entity.HostListKinds {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	lists := make([]entity.HostList, 0, len(names))
+	for _, name := range names {
+		list, err := s.Get(name)
+		if err != nil {
+			return nil, err
+		}
+		list.Entries = nil
+		lists = append(lists, *list)
+	}
+	return lists, nil
+}
+
+func (s *hostListService) Get(name string) (*entity.HostList, error) {
+	kind, ok := entity.HostListKinds[name]
+	if !ok {
+		return nil, repository.ErrHostListNotFound
+	}
+	lines, err := s.repo.ReadLines(name)
+	if err != nil {
+		return nil, err
+	}
+	return newHostList(name, kind, lines), nil
+}
+
+func (s *hostListService) Add(name string, entries []string) (*entity.HostListChange, error) {
+	kind, ok := entity.HostListKinds[name]
+	if !ok {
+		return nil, repository.ErrHostListNotFound
+	}
+	normalized, err := normalizeHostListEntries(kind, entries)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	lines, err := s.repo.ReadLines(name)
+	if err != nil {
+		return nil, err
+	}
+	present := make(map[string]bool)
+	for _, line := range lines {
+		if key, ok := hostListLineKey(kind, line); ok {
+			present[key] = true
+		}
+	}
+
+	change := &entity.HostListChange{Added: []string{}, Removed: []string{}}
+	for _, entry := range normalized {
+		if !present[entry] {
+			lines = append(lines, entry)
+			change.Added = append(change.Added, entry)
+		}
+	}
+	return s.save(name, kind, lines, change)
+}
+
+func (s *hostListService) Remove(name string, entries []string) (*entity.HostListChange, error) {
+	kind, ok := entity.HostListKinds[name]
+	if !ok {
+		return nil, repository.ErrHostListNotFound
+	}
+	// Неверные записи удалить тоже можно: они сравниваются как есть, без приведения
+	remove := make(map[string]bool, len(entries))
+	for _, entry := range entries {
+		if key, ok := hostListLineKey(kind, entry); ok {
+			remove[key] = true
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	lines, err := s.repo.ReadLines(name)
+	if err != nil {
+		return nil, err
+	}
+
+	change := &entity.HostListChange{Added: []string{}, Removed: []string{}}
+	removed := make(map[string]bool)
+	kept := lines[:0]
+	for _, line := range lines {
+		if key, ok := hostListLineKey(kind, line); ok && remove[key] {
+			if !removed[key] {
+				removed[key] = true
+				change.Removed = append(change.Removed, key)
+			}
+			continue
+		}
+		kept = append(kept, line)
+	}
+	return s.save(name, kind, kept, change)
+}
+
+func (s *hostListService) Replace(name string, entries []string) (*entity.HostListChange, error) {
+	kind, ok := entity.HostListKinds[name]
+	if !ok {
+		return nil, repository.ErrHostListNotFound
+	}
+	normalized, err := normalizeHostListEntries(kind, entries)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	lines, err := s.repo.ReadLines(name)
+	if err != nil {
+		return nil, err
+	}
+
+	// Сохраняется только вводный комментарий файла: остальные комментарии относились к старым записям
+	var replaced []string
+	old := make(map[string]bool)
+	for i, line := range lines {
+		if key, ok := hostListLineKey(kind, line); ok {
+			if replaced == nil {
+				replaced = append([]string{}, lines[:i]...)
+			}
+			old[key] = true
+		}
+	}
+	if replaced == nil {
+		replaced = append([]string{}, lines...)
+	}
+
+	change := &entity.HostListChange{Added: []string{}, Removed: []string{}}
+	next := make(map[string]bool, len(normalized))
+	for _, entry := range normalized {
+		replaced = append(replaced, entry)
+		next[entry] = true
+		if !old[entry] {
+			change.Added = append(change.Added, entry)
+		}
+	}
+	for key := range old {
+		if !next[key] {
+			change.Removed = append(change.Removed, key)
+		}
+	}
+	sort.Strings(change.Removed)
+	return s.save(name, kind, replaced, change)
+}
+
+// save убирает повторяющиеся записи и перезаписывает файл, если список изменился.
+func (s *hostListService) save(name, kind string, lines []string, change *entity.HostListChange) (*entity.HostListChange, error) {
+	seen := make(map[string]bool)
+	deduped := make([]string, 0, len(lines))
+	duplicates := false
+	for _, line := range lines {
+		if key, ok := hostListLineKey(kind, line); ok {
+			if seen[key] {
+				duplicates = true
+				continue
+			}
+			seen[key] = true
+		}
+		deduped = append(deduped, line)
+	}
+
+	if len(change.Added) > 0 || len(change.Removed) > 0 || duplicates {
+		if err := s.lock.TryLock(); err != nil {
+			return nil, err
+		}
+		err := s.repo.WriteLines(name, deduped)
+		s.lock.Unlock()
+		if err != nil {
+			return nil, err
+		}
+	}
+	change.List = *newHostList(name, kind, deduped)
+	return change, nil
+}
+
+func newHostList(name, kind string, lines []string) *entity.HostList {
+	list := &entity.HostList{Name: name, Kind: kind, Entries: []string{}}
+	seen := make(map[string]bool)
+	for _, line := range lines {
+		if key, ok := hostListLineKey(kind, line); ok && !seen[key] {
+			seen[key] = true
+			list.Entries = append(list.Entries, key)
+		}
+	}
+	list.Count = len(list.Entries)
+	return list
+}
+
+// hostListLineKey возвращает запись строки файла без комментария для сравнения.
+// Неверные записи, добавленные в файл вручную, сравниваются в нижнем регистре как есть.
+func hostListLineKey(kind, line string) (string, bool) {
+	line, _, _ = strings.Cut(line, "#")
+	line = strings.TrimSpace(line)
+	if line == "" {
+		return "", false
+	}
+	if normalized, err := normalizeHostListEntry(kind, line); err == nil {
+		return normalized, true
+	}
+	return strings.ToLower(line), true
+}
+
+// normalizeHostListEntries проверяет и приводит записи запроса, убирая повторы.
+// Ошибки возвращаются одним *ValidationError с полями entries[i].
+func normalizeHostListEntries(kind string, entries []string) ([]string, error) {
+	fields := make(map[string]string)
+	seen := make(map[string]bool, len(entries))
+	normalized := make([]string, 0, len(entries))
+	for i, entry := range entries {
+		value, err := normalizeHostListEntry(kind, strings.TrimSpace(entry))
+		if err != nil {
+			fields[fmt.Sprintf("entries[%d]", i)] = err.Error()
+			continue
+		}
+		if !seen[value] {
+			seen[value] = true
+			normalized = append(normalized, value)
+		}
+	}
+	if len(fields) > 0 {
+		return nil, &ValidationError{Fields: fields}
+	}
+	return normalized, nil
+}
+
+func normalizeHostListEntry(kind, entry string) (string, error) {
+	if entry == "" {
+		return "", fmt.Errorf("must not be empty")
+	}
+	if kind == entity.HostListKindCIDR {
+		return normalizeCIDR(entry)
+	}
+	return normalizeDomain(entry)
+}
+
+// normalizeDomain приводит домен к нижнему регистру и punycode. Поддомены AntiZapret
+// включает сам, поэтому префикс "*." отбрасывается.
+func normalizeDomain(entry string) (string, error) {
+	domain := strings.TrimSuffix(strings.TrimPrefix(strings.ToLower(entry), "*."), ".")
+	if _, err := netip.ParseAddr(domain); err == nil {
+		return "", fmt.Errorf("%q is an IP address, add it to %s", entry, entity.HostListIncludeIPs)
+	}
+
+	ascii, err := idna.ToASCII(domain)
+	if err != nil {
+		return "", fmt.Errorf("%q is not a valid domain", entry)
+	}
+	if len(ascii) > 253 {
+		return "", fmt.Errorf("%q is longer than 253 characters", entry)
+	}
+	for _, label := range strings.Split(ascii, ".") {
+		if !domainLabelPattern.MatchString(label) {
+			return "", fmt.Errorf("%q is not a valid domain", entry)
+		}
+	}
+	return ascii, nil
+}
+
+// normalizeCIDR принимает IPv4-подсеть A.B.C.D/M или адрес A.B.C.D (как /32)
+// и возвращает адрес сети, например 10.1.2.3/8 → 10.0.0.0/8.
+func normalizeCIDR(entry string) (string, error) {
+	cidr := entry
+	if !strings.Contains(cidr, "/") {
+		cidr += "/32"
+	}
+	prefix, err := netip.ParsePrefix(cidr)
+	if err != nil || !prefix.Addr().Is4() {
+		return "", fmt.Errorf("%q is not an IPv4 subnet in A.B.C.D/M format", entry)
+	}
+	return prefix.Masked().String(), nil
+}
@@ -0,0 +1,265 @@
+package service
+
+import (
+	"antizapret-admin-panel/internal/entity"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+var (
+	// ErrJobNotFound возвращается для неизвестного или уже удаленного из истории ID задачи
+	ErrJobNotFound = errors.New("job not found")
+	// ErrJobQueueFull возвращается, когда в очереди слишком много невыполненных задач
+	ErrJobQueueFull = errors.New("job queue is full")
+)
+
+// Ограничения очереди: сколько задач может ждать выполнения и сколько завершенных хранится
+const (
+	maxQueuedJobs   = 100
+	maxFinishedJobs = 500
+	finishedJobTTL  = 24 * time.Hour
+)
+
+// JobFunc — тело задачи. Вывод скрипта пишется в output, его видно в Job.Output еще во время выполнения.
+// Возвращает результат для entity.Job.Result и ошибку.
+type JobFunc func(ctx context.Context, output io.Writer) (result any, err error)
+
+// JobQueue выполняет операции с client.sh по одной в порядке поступления:
+// скрипт меняет общие файлы (индекс easyrsa, CRL, конфиги WireGuard) и не допускает параллельного запуска.
+// История задач хранится только в памяти.
+type JobQueue interface {
+	// Submit ставит задачу в очередь. В job учитываются Action, поля клиента и CreatedBy.
+	Submit(job entity.Job, fn JobFunc) (*entity.Job, error)
+	Get(id string) (*entity.Job, error)
+	// Wait ждет завершения задачи или отмены ctx. Отмена ctx не отменяет саму задачу.
+	Wait(ctx context.Context, id string) (*entity.Job, error)
+	// Follow ждет, пока вывод задачи станет длиннее offset байт или задача завершится,
+	// и возвращает новый вывод и состояние задачи. Вывод режется только по границам символов UTF-8.
+	Follow(ctx context.Context, id string, offset int) (string, *entity.Job, error)
+}
+
+type queuedJob struct {
+	job    entity.Job
+	fn     JobFunc
+	done   chan struct{}
+	output bytes.Buffer
+	// Закрывается и заменяется новым при каждом изменении вывода или статуса
+	changed chan struct{}
+}
+
+// notify будит всех, кто ждет в Follow. Вызывается под q.mu.
+func (j *queuedJob) notify() {
+	close(j.changed)
+	j.changed = make(chan struct{})
+}
+
+// jobOutput пишет вывод задачи в ее буфер под мьютексом очереди.
+type jobOutput struct {
+	q   *jobQueue
+	job *queuedJob
+}
+
+func (w jobOutput) Write(p []byte) (int, error) {
+	w.q.mu.Lock()
+	defer w.q.mu.Unlock()
+	n, err := w.job.output.Write(p)
+	w.job.notify()
+	return n, err
+}
+
+type jobQueue struct {
+	mu      sync.Mutex
+	jobs    map[string]*queuedJob
+	order   []string
+	pending chan *queuedJob
+	now     func() time.Time
+}
+
+// NewJobQueue — конструктор. Запускает единственный обработчик очереди.
+func NewJobQueue() JobQueue {
+	q := &jobQueue{
+		jobs:    make(map[string]*queuedJob),
+		pending: make(chan *queuedJob, maxQueuedJobs),
+		now:     time.Now,
+	}
+	go q.work()
+	return q
+}
+
+func (q *jobQueue) Submit(job entity.Job, fn JobFunc) (*entity.Job, error) {
+	id, err := randomHex(8)
+	if err != nil {
+		return nil, err
+	}
+	job.ID = id
+	job.Status = entity.JobStatusQueued
+	job.CreatedAt = q.now()
+	job.StartedAt, job.FinishedAt, job.Output, job.Error, job.Result = nil, nil, "", "", nil
+
+	queued := &queuedJob{job: job, fn: fn, done: make(chan struct{}), changed: make(chan struct{})}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	select {
+	case q.pending <- queued:
+	default:
+		return nil, ErrJobQueueFull
+	}
+	q.prune()
+	q.jobs[id] = queued
+	q.order = append(q.order, id)
+
+	snapshot := queued.job
+	return &snapshot, nil
+}
+
+func (q *jobQueue) Get(id string) (*entity.Job, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	queued, ok := q.jobs[id]
+	if !ok {
+		return nil, ErrJobNotFound
+	}
+	return queued.snapshot(), nil
+}
+
+// snapshot возвращает копию задачи с выводом на текущий момент. Вызывается под q.mu.
+func (j *queuedJob) snapshot() *entity.Job {
+	snapshot := j.job
+	snapshot.Output = j.output.String()
+	return &snapshot
+}
+
+func (q *jobQueue) Wait(ctx context.Context, id string) (*entity.Job, error) {
+	q.mu.Lock()
+	queued, ok := q.jobs[id]
+	q.mu.Unlock()
+	if !ok {
+		return nil, ErrJobNotFound
+	}
+
+	select {
+	case <-queued.done:
+		return q.Get(id)
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (q *jobQueue) Follow(ctx context.Context, id string, offset int) (string, *entity.Job, error) {
+	for {
+		q.mu.Lock()
+		queued, ok := q.jobs[id]
+		if !ok {
+			q.mu.Unlock()
+			return "", nil, ErrJobNotFound
+		}
+		output := queued.output.Bytes()
+		if offset > len(output) {
+			offset = len(output)
+		}
+		chunk := output[offset:]
+		finished := queued.job.Finished()
+		if !finished {
+			chunk = chunk[:completeRunes(chunk)]
+		}
+		if len(chunk) > 0 || finished {
+			job := queued.snapshot()
+			q.mu.Unlock()
+			return string(chunk), job, nil
+		}
+		changed := queued.changed
+		q.mu.Unlock()
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return "", nil, ctx.Err()
+		}
+	}
+}
+
+// completeRunes возвращает длину начала p без оборванного в конце символа UTF-8.
+func completeRunes(p []byte) int {
+	for i := len(p) - 1; i >= 0 && i >= len(p)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(p[i]) {
+			if !utf8.FullRune(p[i:]) {
+				return i
+			}
+			break
+		}
+	}
+	return len(p)
+}
+
+// work выполняет задачи по одной. Контекст задачи не связан с запросом, который ее поставил:
+// ответ клиенту может не дождаться результата, но операция доводится до конца.
+func (q *jobQueue) work() {
+	for queued := range q.pending {
+		q.mu.Lock()
+		started := q.now()
+		queued.job.Status = entity.JobStatusRunning
+		queued.job.StartedAt = &started
+		queued.notify()
+		q.mu.Unlock()
+
+		result, err := q.run(queued)
+
+		q.mu.Lock()
+		finished := q.now()
+		queued.job.FinishedAt = &finished
+		queued.job.Result = result
+		if err != nil {
+			queued.job.Status = entity.JobStatusFailed
+			queued.job.Error = err.Error()
+		} else {
+			queued.job.Status = entity.JobStatusSucceeded
+		}
+		queued.notify()
+		q.mu.Unlock()
+		close(queued.done)
+
+		log.Printf("Задача %s (%s %s) завершена: %s за %s", queued.job.ID, queued.job.Action, queued.job.ClientName, queued.job.Status, finished.Sub(started).Round(time.Millisecond))
+	}
+}
+
+// run выполняет тело задачи. Паника превращается в ошибку задачи, чтобы не остановить очередь.
+func (q *jobQueue) run(queued *queuedJob) (result any, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("Задача %s завершилась паникой: %v", queued.job.ID, r)
+			err = errors.New("job panicked")
+		}
+	}()
+	return queued.fn(context.Background(), jobOutput{q: q, job: queued})
+}
+
+// prune удаляет из истории старые завершенные задачи. Вызывается под q.mu.
+func (q *jobQueue) prune() {
+	cutoff := q.now().Add(-finishedJobTTL)
+	finished := 0
+	for _, id := range q.order {
+		if q.jobs[id].job.Finished() {
+			finished++
+		}
+	}
+
+	kept := q.order[:0]
+	for _, id := range q.order {
+		job := q.jobs[id].job
+		if job.Finished() && (job.FinishedAt.Before(cutoff) || finished > maxFinishedJobs) {
+			delete(q.jobs, id)
+			finished--
+			continue
+		}
+		kept = append(kept, id)
+	}
+	q.order = kept
+}
@@ -0,0 +1,290 @@
+package service
+
+import (
+	"antizapret-admin-panel/internal/entity"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ErrLoginLocked — базовая ошибка для заблокированного входа, см. LoginLockedError
+var ErrLoginLocked = errors.New("too many failed login attempts")
+
+// LoginLockedError сообщает, через сколько можно повторить попытку входа.
+type LoginLockedError struct {
+	RetryAfter time.Duration
+}
+
+func (e *LoginLockedError) Error() string {
+	return fmt.Sprintf("%v, retry after %s", ErrLoginLocked, e.RetryAfter.Round(time.Second))
+}
+
+func (e *LoginLockedError) Is(target error) bool {
+	return target == ErrLoginLocked
+}
+
+// LoginLimitPolicy задает правила ограничения попыток входа.
+type LoginLimitPolicy struct {
+	// FreeAttempts неудачных попыток проходят без задержки
+	FreeAttempts int
+	// После каждой следующей неудачи вход блокируется на BaseDelay, удваивая задержку до MaxDelay
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	// После LockoutThreshold неудач подряд вход блокируется на LockoutDuration
+	LockoutThreshold int
+	LockoutDuration  time.Duration
+	// Счетчик сбрасывается, если неудачных попыток не было ResetAfter
+	ResetAfter time.Duration
+}
+
+// DefaultLoginLimitPolicy — политика по умолчанию: 3 попытки без задержки,
+// затем 1с, 2с, 4с... до 5 минут, после 10 неудач — блокировка на 15 минут.
+var DefaultLoginLimitPolicy = LoginLimitPolicy{
+	FreeAttempts:     3,
+	BaseDelay:        time.Second,
+	MaxDelay:         5 * time.Minute,
+	LockoutThreshold: 10,
+	LockoutDuration:  15 * time.Minute,
+	ResetAfter:       time.Hour,
+}
+
+// LoginLimiter считает неудачные попытки входа отдельно по IP и по логину.
+// Попытка, пропущенная Check, резервируется до RecordFailure, RecordSuccess или Release:
+// параллельные запросы видят ее как возможную неудачу и не проходят мимо задержки.
+type LoginLimiter interface {
+	// Check возвращает *LoginLockedError, если вход с этого IP или для этого логина заблокирован,
+	// иначе резервирует попытку. После nil обязателен один из RecordFailure, RecordSuccess, Release.
+	Check(ip, username string) error
+	RecordFailure(ip, username string)
+	RecordSuccess(ip, username string)
+	// Release снимает резерв попытки, которая не закончилась ни успехом, ни неудачей
+	Release(ip, username string)
+	Locks() []entity.LoginLock
+	Unlock(kind, key string) bool
+}
+
+type loginAttempts struct {
+	failures int
+	// pending — попытки, пропущенные Check и еще не завершенные
+	pending     int
+	lastFailure time.Time
+	lockedUntil time.Time
+}
+
+type loginLimiter struct {
+	policy LoginLimitPolicy
+	now    func() time.Time
+
+	mu       sync.Mutex
+	attempts map[string]*loginAttempts // kind + "|" + key
+}
+
+// NewLoginLimiter — конструктор ограничителя попыток входа.
+// now позволяет подменить часы в тестах; nil означает time.Now.
+func NewLoginLimiter(policy LoginLimitPolicy, now func() time.Time) LoginLimiter {
+	if now == nil {
+		now = time.Now
+	}
+	return &loginLimiter{
+		policy:   policy,
+		now:      now,
+		attempts: make(map[string]*loginAttempts),
+	}
+}
+
+func (l *loginLimiter) Check(ip, username string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	keys := loginAttemptKeys(ip, username)
+	var retryAfter time.Duration
+	for _, key := range keys {
+		a, ok := l.attempts[key]
+		if !ok {
+			continue
+		}
+		wait := a.lockedUntil.Sub(now)
+		// Незавершенные попытки считаются неудачными: если бы они уже провалились
+		// и включили задержку, эта попытка ждала бы ее
+		if a.pending > 0 {
+			if delay := l.delay(a.failures + a.pending); delay > wait {
+				wait = delay
+			}
+		}
+		if wait > retryAfter {
+			retryAfter = wait
+		}
+	}
+
+	if retryAfter > 0 {
+		return &LoginLockedError{RetryAfter: retryAfter}
+	}
+
+	for _, key := range keys {
+		a, ok := l.attempts[key]
+		if !ok {
+			a = &loginAttempts{}
+			l.attempts[key] = a
+		}
+		a.pending++
+	}
+	return nil
+}
+
+func (l *loginLimiter) RecordFailure(ip, username string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.prune(now)
+
+	for _, key := range loginAttemptKeys(ip, username) {
+		a, ok := l.attempts[key]
+		if !ok {
+			a = &loginAttempts{}
+			l.attempts[key] = a
+		}
+
+		a.settle()
+		a.failures++
+		a.lastFailure = now
+		a.lockedUntil = now.Add(l.delay(a.failures))
+	}
+}
+
+func (l *loginLimiter) RecordSuccess(ip, username string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for _, key := range loginAttemptKeys(ip, username) {
+		a, ok := l.attempts[key]
+		if !ok {
+			continue
+		}
+		// Счетчик сбрасывается, но резервы параллельных попыток остаются до их завершения
+		a.settle()
+		if a.pending == 0 {
+			delete(l.attempts, key)
+			continue
+		}
+		a.failures = 0
+		a.lockedUntil = time.Time{}
+	}
+}
+
+func (l *loginLimiter) Release(ip, username string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for _, key := range loginAttemptKeys(ip, username) {
+		a, ok := l.attempts[key]
+		if !ok {
+			continue
+		}
+		a.settle()
+		if a.pending == 0 && a.failures == 0 {
+			delete(l.attempts, key)
+		}
+	}
+}
+
+// settle снимает резерв одной попытки. Резерва может не быть, если счетчик сбросил Unlock.
+func (a *loginAttempts) settle() {
+	if a.pending > 0 {
+		a.pending--
+	}
+}
+
+// Locks возвращает IP-адреса и логины, вход для которых сейчас заблокирован.
+func (l *loginLimiter) Locks() []entity.LoginLock {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	var locks []entity.LoginLock
+	for key, a := range l.attempts {
+		if !now.Before(a.lockedUntil) {
+			continue
+		}
+		kind, value := splitLoginAttemptKey(key)
+		locks = append(locks, entity.LoginLock{
+			Kind:        kind,
+			Key:         value,
+			Failures:    a.failures,
+			LastFailure: a.lastFailure,
+			LockedUntil: a.lockedUntil,
+		})
+	}
+
+	sort.Slice(locks, func(i, j int) bool {
+		return locks[i].LockedUntil.After(locks[j].LockedUntil)
+	})
+	return locks
+}
+
+// Unlock снимает блокировку и сбрасывает счетчик. Возвращает false, если счетчика не было.
+func (l *loginLimiter) Unlock(kind, key string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	attemptKey := kind + "|" + key
+	if _, ok := l.attempts[attemptKey]; !ok {
+		return false
+	}
+	delete(l.attempts, attemptKey)
+	return true
+}
+
+// delay возвращает блокировку после failures неудач подряд.
+func (l *loginLimiter) delay(failures int) time.Duration {
+	p := l.policy
+	if failures >= p.LockoutThreshold {
+		return p.LockoutDuration
+	}
+	if failures <= p.FreeAttempts {
+		return 0
+	}
+
+	delay := p.BaseDelay
+	for i := p.FreeAttempts + 1; i < failures && delay < p.MaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+	return delay
+}
+
+// prune удаляет счетчики, которые уже не блокируют и давно не обновлялись. Вызывается под блокировкой.
+// Счетчик с незавершенными попытками не удаляется, а только обнуляется.
+func (l *loginLimiter) prune(now time.Time) {
+	for key, a := range l.attempts {
+		if now.Before(a.lockedUntil) || now.Sub(a.lastFailure) < l.policy.ResetAfter {
+			continue
+		}
+		if a.pending > 0 {
+			a.failures = 0
+			continue
+		}
+		delete(l.attempts, key)
+	}
+}
+
+func loginAttemptKeys(ip, username string) []string {
+	return []string{
+		entity.LoginLockKindIP + "|" + ip,
+		entity.LoginLockKindUsername + "|" + username,
+	}
+}
+
+func splitLoginAttemptKey(key string) (string, string) {
+	for i := 0; i < len(key); i++ {
+		if key[i] == '|' {
+			return key[:i], key[i+1:]
+		}
+	}
+	return key, ""
+}
@@ -0,0 +1,215 @@
+package service
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeClock — часы, которые двигаются только вручную
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Date(2026, 2, 8, 12, 0, 0, 0, time.UTC)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Add(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// fail проводит одну неудачную попытку входа, которую ограничитель пропустил
+func fail(t *testing.T, l LoginLimiter, ip, username string) {
+	t.Helper()
+	if err := l.Check(ip, username); err != nil {
+		t.Fatalf("Check(%s, %s): %v", ip, username, err)
+	}
+	l.RecordFailure(ip, username)
+}
+
+// retryAfter возвращает задержку из Check или 0, если попытка пропущена (резерв снимается)
+func retryAfter(t *testing.T, l LoginLimiter, ip, username string) time.Duration {
+	t.Helper()
+	err := l.Check(ip, username)
+	if err == nil {
+		l.Release(ip, username)
+		return 0
+	}
+	var locked *LoginLockedError
+	if !errors.As(err, &locked) || !errors.Is(err, ErrLoginLocked) {
+		t.Fatalf("Check: unexpected error %v", err)
+	}
+	return locked.RetryAfter
+}
+
+func TestLoginLimiterBackoff(t *testing.T) {
+	clock := newFakeClock()
+	l := NewLoginLimiter(DefaultLoginLimitPolicy, clock.Now)
+
+	for i := 0; i < 3; i++ {
+		fail(t, l, "203.0.113.5", "admin")
+	}
+	if wait := retryAfter(t, l, "203.0.113.5", "admin"); wait != 0 {
+		t.Fatalf("after 3 failures: retry after %s, want no delay", wait)
+	}
+
+	// Четвертая и следующие неудачи: 1с, 2с, 4с
+	for _, want := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second} {
+		fail(t, l, "203.0.113.5", "admin")
+		if wait := retryAfter(t, l, "203.0.113.5", "admin"); wait != want {
+			t.Fatalf("retry after %s, want %s", wait, want)
+		}
+		clock.Add(want - time.Millisecond)
+		if wait := retryAfter(t, l, "203.0.113.5", "admin"); wait != time.Millisecond {
+			t.Fatalf("just before the delay ends: retry after %s, want 1ms", wait)
+		}
+		clock.Add(time.Millisecond)
+	}
+}
+
+func TestLoginLimiterLockout(t *testing.T) {
+	clock := newFakeClock()
+	policy := DefaultLoginLimitPolicy
+	l := NewLoginLimiter(policy, clock.Now)
+
+	for i := 0; i < policy.LockoutThreshold; i++ {
+		fail(t, l, "203.0.113.5", "admin")
+		clock.Add(policy.MaxDelay)
+	}
+	clock.Add(-policy.MaxDelay)
+	if wait := retryAfter(t, l, "203.0.113.5", "admin"); wait != policy.LockoutDuration {
+		t.Fatalf("retry after %s, want %s", wait, policy.LockoutDuration)
+	}
+
+	// Логин заблокирован и с другого IP, IP — и для другого логина
+	if wait := retryAfter(t, l, "198.51.100.7", "admin"); wait != policy.LockoutDuration {
+		t.Errorf("other IP: retry after %s, want %s", wait, policy.LockoutDuration)
+	}
+	if wait := retryAfter(t, l, "203.0.113.5", "operator"); wait != policy.LockoutDuration {
+		t.Errorf("other username: retry after %s, want %s", wait, policy.LockoutDuration)
+	}
+	if wait := retryAfter(t, l, "198.51.100.7", "operator"); wait != 0 {
+		t.Errorf("unrelated IP and username: retry after %s, want no delay", wait)
+	}
+	if locks := l.Locks(); len(locks) != 2 {
+		t.Errorf("Locks() = %+v, want IP and username", locks)
+	}
+
+	clock.Add(policy.LockoutDuration)
+	if wait := retryAfter(t, l, "203.0.113.5", "admin"); wait != 0 {
+		t.Errorf("after lockout: retry after %s, want no delay", wait)
+	}
+	if locks := l.Locks(); len(locks) != 0 {
+		t.Errorf("Locks() = %+v, want none", locks)
+	}
+}
+
+func TestLoginLimiterResetAndUnlock(t *testing.T) {
+	clock := newFakeClock()
+	policy := DefaultLoginLimitPolicy
+	l := NewLoginLimiter(policy, clock.Now)
+
+	for i := 0; i < 4; i++ {
+		fail(t, l, "203.0.113.5", "admin")
+	}
+	clock.Add(policy.ResetAfter)
+	// Старый счетчик сбрасывается при следующей неудаче: она снова первая
+	fail(t, l, "203.0.113.5", "admin")
+	if wait := retryAfter(t, l, "203.0.113.5", "admin"); wait != 0 {
+		t.Fatalf("after reset: retry after %s, want no delay", wait)
+	}
+
+	for i := 0; i < 3; i++ {
+		fail(t, l, "203.0.113.5", "admin")
+	}
+	if wait := retryAfter(t, l, "203.0.113.5", "admin"); wait != time.Second {
+		t.Fatalf("retry after %s, want 1s", wait)
+	}
+	if !l.Unlock("ip", "203.0.113.5") || !l.Unlock("username", "admin") {
+		t.Fatal("Unlock: counters not found")
+	}
+	if l.Unlock("ip", "203.0.113.5") {
+		t.Error("second Unlock found a counter")
+	}
+	if wait := retryAfter(t, l, "203.0.113.5", "admin"); wait != 0 {
+		t.Errorf("after Unlock: retry after %s, want no delay", wait)
+	}
+
+	for i := 0; i < 3; i++ {
+		fail(t, l, "203.0.113.5", "admin")
+	}
+	if err := l.Check("203.0.113.5", "admin"); err != nil {
+		t.Fatal(err)
+	}
+	l.RecordSuccess("203.0.113.5", "admin")
+	fail(t, l, "203.0.113.5", "admin")
+	if wait := retryAfter(t, l, "203.0.113.5", "admin"); wait != 0 {
+		t.Errorf("after success: retry after %s, want no delay", wait)
+	}
+}
+
+// Параллельные попытки резервируются в Check: пока они не завершены, лишние получают задержку,
+// как если бы незавершенные уже провалились
+func TestLoginLimiterReservesParallelAttempts(t *testing.T) {
+	clock := newFakeClock()
+	policy := DefaultLoginLimitPolicy
+	l := NewLoginLimiter(policy, clock.Now)
+
+	const attempts = 50
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		allowed int
+	)
+	start := make(chan struct{})
+	for i := 0; i < attempts; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			if err := l.Check("203.0.113.5", "admin"); err != nil {
+				return
+			}
+			mu.Lock()
+			allowed++
+			mu.Unlock()
+		}()
+	}
+	close(start)
+	wg.Wait()
+
+	// Последовательно без задержки проходят FreeAttempts неудач и еще одна попытка после них
+	if allowed != policy.FreeAttempts+1 {
+		t.Fatalf("%d parallel attempts passed Check, want %d", allowed, policy.FreeAttempts+1)
+	}
+	for i := 0; i < policy.FreeAttempts; i++ {
+		l.RecordFailure("203.0.113.5", "admin")
+	}
+	l.Release("203.0.113.5", "admin")
+	if wait := retryAfter(t, l, "203.0.113.5", "admin"); wait != 0 {
+		t.Fatalf("after %d failures: retry after %s, want no delay", policy.FreeAttempts, wait)
+	}
+
+	// Следующая неудача включает задержку, поэтому вторая параллельная попытка не проходит
+	if err := l.Check("203.0.113.5", "admin"); err != nil {
+		t.Fatal(err)
+	}
+	if wait := retryAfter(t, l, "203.0.113.5", "admin"); wait != time.Second {
+		t.Errorf("while an attempt is in flight: retry after %s, want 1s", wait)
+	}
+	l.Release("203.0.113.5", "admin")
+	if wait := retryAfter(t, l, "203.0.113.5", "admin"); wait != 0 {
+		t.Errorf("after Release: retry after %s, want no delay", wait)
+	}
+}
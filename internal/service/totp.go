@@ -0,0 +1,304 @@
+package service
+
+import (
+	"antizapret-admin-panel/internal/repository"
+	"bytes"
+	"crypto/subtle"
+	"errors"
+	"image/png"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pquerna/otp"
+	"github.com/pquerna/otp/totp"
+)
+
+// Издатель, который приложение-аутентификатор показывает рядом с логином
+const totpIssuer = "AntiZapret Admin"
+
+// Количество кодов восстановления, выдаваемых при подключении TOTP
+const recoveryCodeCount = 10
+
+// Параметры TOTP по RFC 6238: 6 цифр, шаг 30 секунд, допускается расхождение часов на один шаг
+var totpOptions = totp.ValidateOpts{
+	Period:    30,
+	Skew:      1,
+	Digits:    otp.DigitsSix,
+	Algorithm: otp.AlgorithmSHA1,
+}
+
+var (
+	// ErrTOTPAlreadyEnabled возвращается при повторном подключении TOTP
+	ErrTOTPAlreadyEnabled = errors.New("two-factor authentication is already enabled")
+	// ErrTOTPNotEnabled возвращается, если у пользователя TOTP не подключен
+	ErrTOTPNotEnabled = errors.New("two-factor authentication is not enabled")
+	// ErrTOTPNotPending возвращается при подтверждении без начатого подключения
+	ErrTOTPNotPending = errors.New("two-factor enrollment was not started")
+	// ErrInvalidTOTPCode возвращается для неверного, устаревшего или уже использованного кода
+	ErrInvalidTOTPCode = errors.New("invalid two-factor code")
+)
+
+// TOTPEnrollment — данные для подключения приложения-аутентификатора.
+type TOTPEnrollment struct {
+	Secret string `json:"secret"`
+	URI    string `json:"uri"`
+	// PNG с QR-кодом otpauth:// URI
+	QRCode []byte `json:"-"`
+}
+
+// TOTPService управляет вторым фактором пользователей панели.
+type TOTPService interface {
+	BeginEnrollment(username string) (*TOTPEnrollment, error)
+	ConfirmEnrollment(username, code string) ([]string, error)
+	RegenerateRecoveryCodes(username, code string) ([]string, error)
+	Disable(username, password string) error
+	Reset(username string) error
+	Verify(username, code string) error
+}
+
+type totpService struct {
+	repo  repository.UserRepository
+	users UserService
+	now   func() time.Time
+
+	mu    sync.Mutex
+	locks map[string]*sync.Mutex // логин -> блокировка чтения и записи его TOTP
+}
+
+// NewTOTPService — конструктор сервиса двухфакторной аутентификации.
+func NewTOTPService(repo repository.UserRepository, users UserService) TOTPService {
+	return &totpService{repo: repo, users: users, now: time.Now, locks: make(map[string]*sync.Mutex)}
+}
+
+// lockUser блокирует изменение TOTP пользователя до вызова возвращенной функции.
+// Без нее два параллельных запроса с одним кодом оба прочли бы старые TOTPLastStep
+// и коды восстановления, и одноразовый код сработал бы дважды.
+func (s *totpService) lockUser(username string) func() {
+	s.mu.Lock()
+	lock, ok := s.locks[username]
+	if !ok {
+		lock = &sync.Mutex{}
+		s.locks[username] = lock
+	}
+	s.mu.Unlock()
+
+	lock.Lock()
+	return lock.Unlock
+}
+
+// BeginEnrollment генерирует новый секрет. Он начинает действовать только после ConfirmEnrollment,
+// поэтому незавершенное подключение не может запереть пользователя.
+func (s *totpService) BeginEnrollment(username string) (*TOTPEnrollment, error) {
+	defer s.lockUser(username)()
+
+	user, err := s.repo.FindByUsername(username)
+	if err != nil {
+		return nil, err
+	}
+	if user.TOTPEnabled {
+		return nil, ErrTOTPAlreadyEnabled
+	}
+
+	key, err := totp.Generate(totp.GenerateOpts{
+		Issuer:      totpIssuer,
+		AccountName: username,
+		Period:      totpOptions.Period,
+		Digits:      totpOptions.Digits,
+		Algorithm:   totpOptions.Algorithm,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	img, err := key.Image(256, 256)
+	if err != nil {
+		return nil, err
+	}
+	var qr bytes.Buffer
+	if err := png.Encode(&qr, img); err != nil {
+		return nil, err
+	}
+
+	user.TOTPPendingSecret = key.Secret()
+	user.UpdatedAt = s.now()
+	if err := s.repo.Update(*user); err != nil {
+		return nil, err
+	}
+
+	return &TOTPEnrollment{Secret: key.Secret(), URI: key.URL(), QRCode: qr.Bytes()}, nil
+}
+
+// ConfirmEnrollment включает TOTP, если код из приложения совпал с выданным секретом,
+// и возвращает коды восстановления. Они показываются один раз, хранятся только их хеши.
+func (s *totpService) ConfirmEnrollment(username, code string) ([]string, error) {
+	defer s.lockUser(username)()
+
+	user, err := s.repo.FindByUsername(username)
+	if err != nil {
+		return nil, err
+	}
+	if user.TOTPEnabled {
+		return nil, ErrTOTPAlreadyEnabled
+	}
+	if user.TOTPPendingSecret == "" {
+		return nil, ErrTOTPNotPending
+	}
+
+	step, ok := s.matchCode(user.TOTPPendingSecret, code, 0)
+	if !ok {
+		return nil, ErrInvalidTOTPCode
+	}
+
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+
+	user.TOTPEnabled = true
+	user.TOTPSecret = user.TOTPPendingSecret
+	user.TOTPPendingSecret = ""
+	user.TOTPLastStep = step
+	user.RecoveryCodeHashes = hashes
+	user.UpdatedAt = s.now()
+	if err := s.repo.Update(*user); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// RegenerateRecoveryCodes заменяет все коды восстановления новыми. Нужен действующий код TOTP.
+func (s *totpService) RegenerateRecoveryCodes(username, code string) ([]string, error) {
+	defer s.lockUser(username)()
+
+	user, err := s.repo.FindByUsername(username)
+	if err != nil {
+		return nil, err
+	}
+	if !user.TOTPEnabled {
+		return nil, ErrTOTPNotEnabled
+	}
+
+	step, ok := s.matchCode(user.TOTPSecret, code, user.TOTPLastStep)
+	if !ok {
+		return nil, ErrInvalidTOTPCode
+	}
+
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+
+	user.TOTPLastStep = step
+	user.RecoveryCodeHashes = hashes
+	user.UpdatedAt = s.now()
+	if err := s.repo.Update(*user); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// Disable отключает TOTP после проверки пароля пользователя.
+func (s *totpService) Disable(username, password string) error {
+	if _, err := s.users.Authenticate(username, password); err != nil {
+		return err
+	}
+	return s.Reset(username)
+}
+
+// Reset отключает TOTP без проверок. Используется администратором и CLI,
+// если пользователь потерял и телефон, и коды восстановления.
+func (s *totpService) Reset(username string) error {
+	defer s.lockUser(username)()
+
+	user, err := s.repo.FindByUsername(username)
+	if err != nil {
+		return err
+	}
+
+	user.TOTPEnabled = false
+	user.TOTPSecret = ""
+	user.TOTPPendingSecret = ""
+	user.TOTPLastStep = 0
+	user.RecoveryCodeHashes = nil
+	user.UpdatedAt = s.now()
+	return s.repo.Update(*user)
+}
+
+// Verify проверяет второй фактор при входе: код TOTP или один из кодов восстановления.
+// Использованный код восстановления удаляется.
+func (s *totpService) Verify(username, code string) error {
+	defer s.lockUser(username)()
+
+	user, err := s.repo.FindByUsername(username)
+	if err != nil {
+		return err
+	}
+	if !user.TOTPEnabled {
+		return ErrTOTPNotEnabled
+	}
+
+	if step, ok := s.matchCode(user.TOTPSecret, code, user.TOTPLastStep); ok {
+		user.TOTPLastStep = step
+		return s.repo.Update(*user)
+	}
+
+	hash := hashToken(normalizeRecoveryCode(code))
+	for i, stored := range user.RecoveryCodeHashes {
+		if subtle.ConstantTimeCompare([]byte(stored), []byte(hash)) == 1 {
+			user.RecoveryCodeHashes = append(user.RecoveryCodeHashes[:i], user.RecoveryCodeHashes[i+1:]...)
+			user.UpdatedAt = s.now()
+			return s.repo.Update(*user)
+		}
+	}
+	return ErrInvalidTOTPCode
+}
+
+// matchCode ищет временной шаг, для которого код совпадает, в пределах допустимого расхождения часов.
+// Шаги не новее lastStep отклоняются, чтобы перехваченный код нельзя было использовать повторно.
+func (s *totpService) matchCode(secret, code string, lastStep int64) (int64, bool) {
+	code = strings.TrimSpace(code)
+	if len(code) != totpOptions.Digits.Length() {
+		return 0, false
+	}
+
+	now := s.now()
+	period := int64(totpOptions.Period)
+	current := now.Unix() / period
+	for offset := -int64(totpOptions.Skew); offset <= int64(totpOptions.Skew); offset++ {
+		step := current + offset
+		if step <= lastStep {
+			continue
+		}
+
+		expected, err := totp.GenerateCodeCustom(secret, time.Unix(step*period, 0), totpOptions)
+		if err != nil {
+			return 0, false
+		}
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(code)) == 1 {
+			return step, true
+		}
+	}
+	return 0, false
+}
+
+// generateRecoveryCodes возвращает коды вида xxxxxxxx-xxxxxxxx и их SHA-256.
+func generateRecoveryCodes() ([]string, []string, error) {
+	codes := make([]string, 0, recoveryCodeCount)
+	hashes := make([]string, 0, recoveryCodeCount)
+	for i := 0; i < recoveryCodeCount; i++ {
+		raw, err := randomHex(8)
+		if err != nil {
+			return nil, nil, err
+		}
+		code := raw[:8] + "-" + raw[8:]
+		codes = append(codes, code)
+		hashes = append(hashes, hashToken(normalizeRecoveryCode(code)))
+	}
+	return codes, hashes, nil
+}
+
+// normalizeRecoveryCode позволяет вводить код без дефиса и в любом регистре.
+func normalizeRecoveryCode(code string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+}
@@ -0,0 +1,125 @@
+package service
+
+import (
+	"antizapret-admin-panel/internal/entity"
+	"antizapret-admin-panel/internal/repository"
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pquerna/otp/totp"
+)
+
+// slowUserRepository задерживает чтение, чтобы параллельные проверки кода успели прочитать
+// пользователя до того, как первая из них его сохранит
+type slowUserRepository struct {
+	repository.UserRepository
+}
+
+func (r slowUserRepository) FindByUsername(username string) (*entity.User, error) {
+	user, err := r.UserRepository.FindByUsername(username)
+	time.Sleep(5 * time.Millisecond)
+	return user, err
+}
+
+// newTestTOTPService создает пользователя ivan с подключенным TOTP и возвращает сервис,
+// секрет и коды восстановления
+func newTestTOTPService(t *testing.T, clock *fakeClock) (*totpService, string, []string) {
+	t.Helper()
+	repo := repository.NewUserRepository(filepath.Join(t.TempDir(), "users.json"))
+	if err := repo.Create(entity.User{Username: "ivan", Role: entity.RoleViewer}); err != nil {
+		t.Fatal(err)
+	}
+	s := NewTOTPService(slowUserRepository{repo}, NewUserService(repo)).(*totpService)
+	s.now = clock.Now
+
+	enrollment, err := s.BeginEnrollment("ivan")
+	if err != nil {
+		t.Fatal(err)
+	}
+	codes, err := s.ConfirmEnrollment("ivan", totpCode(t, enrollment.Secret, clock.Now()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Код подтверждения уже использован, дальше нужен следующий шаг
+	clock.Add(time.Duration(totpOptions.Period) * time.Second)
+	return s, enrollment.Secret, codes
+}
+
+func totpCode(t *testing.T, secret string, at time.Time) string {
+	t.Helper()
+	code, err := totp.GenerateCodeCustom(secret, at, totpOptions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return code
+}
+
+// verifyParallel вызывает Verify с одним кодом из n горутин и возвращает число успешных проверок
+func verifyParallel(t *testing.T, s TOTPService, code string, n int) int {
+	t.Helper()
+	var (
+		wg        sync.WaitGroup
+		mu        sync.Mutex
+		succeeded int
+	)
+	start := make(chan struct{})
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			err := s.Verify("ivan", code)
+			if err != nil && !errors.Is(err, ErrInvalidTOTPCode) {
+				t.Errorf("Verify: %v", err)
+			}
+			if err == nil {
+				mu.Lock()
+				succeeded++
+				mu.Unlock()
+			}
+		}()
+	}
+	close(start)
+	wg.Wait()
+	return succeeded
+}
+
+func TestTOTPVerifyRejectsReplay(t *testing.T) {
+	clock := newFakeClock()
+	s, secret, _ := newTestTOTPService(t, clock)
+
+	code := totpCode(t, secret, clock.Now())
+	if got := verifyParallel(t, s, code, 20); got != 1 {
+		t.Fatalf("one TOTP code accepted %d times in parallel, want 1", got)
+	}
+	if err := s.Verify("ivan", code); !errors.Is(err, ErrInvalidTOTPCode) {
+		t.Errorf("reused code: err = %v, want ErrInvalidTOTPCode", err)
+	}
+
+	clock.Add(time.Duration(totpOptions.Period) * time.Second)
+	if err := s.Verify("ivan", totpCode(t, secret, clock.Now())); err != nil {
+		t.Errorf("code for the next step: %v", err)
+	}
+}
+
+func TestTOTPVerifyRecoveryCodeOnce(t *testing.T) {
+	clock := newFakeClock()
+	s, _, codes := newTestTOTPService(t, clock)
+
+	if got := verifyParallel(t, s, codes[0], 20); got != 1 {
+		t.Fatalf("one recovery code accepted %d times in parallel, want 1", got)
+	}
+	user, err := s.repo.FindByUsername("ivan")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(user.RecoveryCodeHashes) != recoveryCodeCount-1 {
+		t.Errorf("%d recovery codes left, want %d", len(user.RecoveryCodeHashes), recoveryCodeCount-1)
+	}
+	if err := s.Verify("ivan", codes[1]); err != nil {
+		t.Errorf("another recovery code: %v", err)
+	}
+}
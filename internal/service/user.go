@@ -0,0 +1,204 @@
+package service
+
+import (
+	"antizapret-admin-panel/internal/entity"
+	"antizapret-admin-panel/internal/repository"
+	"errors"
+	"regexp"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Минимальная длина пароля администратора
+const minPasswordLength = 8
+
+var usernameRegex = regexp.MustCompile(`^[a-zA-Z0-9_.-]{1,32}$`)
+
+// dummyPasswordHash — bcrypt-хеш со стоимостью bcrypt.DefaultCost, с которым сравнивается пароль
+// несуществующего пользователя: иначе по времени ответа видно, какие логины существуют
+const dummyPasswordHash = "$2a$10$w8peuJ2VeAdJ6gUqZ/O59Oes6Ozgorq4uiI3qhrUbiRghF1jHkJXm"
+
+var (
+	// ErrInvalidCredentials возвращается при неверном логине или пароле
+	ErrInvalidCredentials = errors.New("invalid credentials")
+	// ErrInvalidUsername возвращается, если логин не подходит под usernameRegex
+	ErrInvalidUsername = errors.New("username must be 1-32 characters: letters, digits, '_', '.' or '-'")
+	// ErrWeakPassword возвращается, если пароль короче minPasswordLength
+	ErrWeakPassword = errors.New("password must be at least 8 characters long")
+	// ErrInvalidRole возвращается для неизвестной роли
+	ErrInvalidRole = errors.New("role must be one of: admin, operator, viewer")
+	// ErrLastAdmin возвращается при попытке удалить или понизить последнего администратора
+	ErrLastAdmin = errors.New("cannot remove the last admin")
+)
+
+// UserService управляет учетными записями администраторов панели.
+type UserService interface {
+	ListUsers() ([]entity.User, error)
+	GetUser(username string) (*entity.User, error)
+	CreateUser(username, password, role string) (*entity.User, error)
+	ChangePassword(username, password string) error
+	UpdateUser(username, password, role string) error
+	DeleteUser(username string) error
+	Authenticate(username, password string) (*entity.User, error)
+}
+
+type userService struct {
+	repo repository.UserRepository
+}
+
+// NewUserService — конструктор сервиса пользователей.
+func NewUserService(repo repository.UserRepository) UserService {
+	return &userService{repo: repo}
+}
+
+// ListUsers возвращает всех пользователей, отсортированных по логину.
+func (s *userService) ListUsers() ([]entity.User, error) {
+	return s.repo.FindAll()
+}
+
+// GetUser возвращает пользователя по логину.
+func (s *userService) GetUser(username string) (*entity.User, error) {
+	return s.repo.FindByUsername(username)
+}
+
+// CreateUser создает пользователя с указанной ролью, сохраняя только bcrypt-хеш пароля.
+func (s *userService) CreateUser(username, password, role string) (*entity.User, error) {
+	if !usernameRegex.MatchString(username) {
+		return nil, ErrInvalidUsername
+	}
+	if !entity.IsValidRole(role) {
+		return nil, ErrInvalidRole
+	}
+
+	hash, err := hashPassword(password)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	user := entity.User{
+		Username:     username,
+		PasswordHash: hash,
+		Role:         role,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+
+		PasswordChangedAt: now,
+	}
+	if err := s.repo.Create(user); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// ChangePassword задает пользователю новый пароль.
+func (s *userService) ChangePassword(username, password string) error {
+	return s.UpdateUser(username, password, "")
+}
+
+// UpdateUser меняет пароль и/или роль (пустое значение не меняется). Оба значения проверяются
+// до записи и сохраняются вместе: неподходящий пароль не оставляет роль уже измененной.
+// Роль проверяется на каждый запрос, поэтому действует сразу, без повторного входа.
+func (s *userService) UpdateUser(username, password, role string) error {
+	if role != "" && !entity.IsValidRole(role) {
+		return ErrInvalidRole
+	}
+	if password != "" && len(password) < minPasswordLength {
+		return ErrWeakPassword
+	}
+
+	user, err := s.repo.FindByUsername(username)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	changed := false
+	if role != "" && user.Role != role {
+		if err := s.ensureNotLastAdmin(user); err != nil {
+			return err
+		}
+		user.Role = role
+		user.UpdatedAt = now
+		changed = true
+	}
+	if password != "" {
+		hash, err := hashPassword(password)
+		if err != nil {
+			return err
+		}
+		user.PasswordHash = hash
+		user.UpdatedAt = now
+		user.PasswordChangedAt = now
+		changed = true
+	}
+
+	if !changed {
+		return nil
+	}
+	return s.repo.Update(*user)
+}
+
+// DeleteUser удаляет пользователя. Последнего администратора удалить нельзя,
+// иначе управлять пользователями панели будет некому.
+func (s *userService) DeleteUser(username string) error {
+	user, err := s.repo.FindByUsername(username)
+	if err != nil {
+		return err
+	}
+	if err := s.ensureNotLastAdmin(user); err != nil {
+		return err
+	}
+
+	return s.repo.Delete(username)
+}
+
+// ensureNotLastAdmin возвращает ErrLastAdmin, если user — единственный администратор.
+func (s *userService) ensureNotLastAdmin(user *entity.User) error {
+	if user.Role != entity.RoleAdmin {
+		return nil
+	}
+
+	users, err := s.repo.FindAll()
+	if err != nil {
+		return err
+	}
+	for _, other := range users {
+		if other.Role == entity.RoleAdmin && other.Username != user.Username {
+			return nil
+		}
+	}
+	return ErrLastAdmin
+}
+
+// Authenticate проверяет логин и пароль.
+// Для несуществующего пользователя возвращается та же ошибка, что и для неверного пароля,
+// и пароль так же проверяется bcrypt, чтобы ответ не приходил быстрее.
+func (s *userService) Authenticate(username, password string) (*entity.User, error) {
+	user, err := s.repo.FindByUsername(username)
+	if errors.Is(err, repository.ErrUserNotFound) {
+		bcrypt.CompareHashAndPassword([]byte(dummyPasswordHash), []byte(password))
+		return nil, ErrInvalidCredentials
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)); err != nil {
+		return nil, ErrInvalidCredentials
+	}
+	return user, nil
+}
+
+func hashPassword(password string) (string, error) {
+	if len(password) < minPasswordLength {
+		return "", ErrWeakPassword
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
@@ -0,0 +1,85 @@
+package service
+
+import (
+	"antizapret-admin-panel/internal/entity"
+	"antizapret-admin-panel/internal/repository"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func newTestUserService(t *testing.T) UserService {
+	t.Helper()
+	return NewUserService(repository.NewUserRepository(filepath.Join(t.TempDir(), "users.json")))
+}
+
+// Несуществующий логин проверяется bcrypt с той же стоимостью, что и настоящие пароли
+func TestAuthenticateUnknownUser(t *testing.T) {
+	cost, err := bcrypt.Cost([]byte(dummyPasswordHash))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cost != bcrypt.DefaultCost {
+		t.Errorf("dummy hash cost = %d, want %d", cost, bcrypt.DefaultCost)
+	}
+
+	users := newTestUserService(t)
+	if _, err := users.CreateUser("ivan", "correct-horse", entity.RoleViewer); err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct{ username, password string }{
+		{"ivan", "wrong-password"},
+		{"petr", "correct-horse"},
+	} {
+		if _, err := users.Authenticate(tt.username, tt.password); !errors.Is(err, ErrInvalidCredentials) {
+			t.Errorf("Authenticate(%s): err = %v, want ErrInvalidCredentials", tt.username, err)
+		}
+	}
+	if _, err := users.Authenticate("ivan", "correct-horse"); err != nil {
+		t.Errorf("Authenticate: %v", err)
+	}
+}
+
+// Короткий пароль отклоняется до записи: роль из того же запроса не меняется
+func TestUpdateUserValidatesBeforeWriting(t *testing.T) {
+	users := newTestUserService(t)
+	if _, err := users.CreateUser("admin", "correct-horse", entity.RoleAdmin); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := users.CreateUser("ivan", "correct-horse", entity.RoleViewer); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := users.UpdateUser("ivan", "short", entity.RoleOperator); !errors.Is(err, ErrWeakPassword) {
+		t.Fatalf("err = %v, want ErrWeakPassword", err)
+	}
+	if err := users.UpdateUser("ivan", "new-password", "superuser"); !errors.Is(err, ErrInvalidRole) {
+		t.Fatalf("err = %v, want ErrInvalidRole", err)
+	}
+	if err := users.UpdateUser("admin", "new-password", entity.RoleViewer); !errors.Is(err, ErrLastAdmin) {
+		t.Fatalf("err = %v, want ErrLastAdmin", err)
+	}
+	user, err := users.GetUser("ivan")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.Role != entity.RoleViewer {
+		t.Errorf("role = %q after rejected update, want viewer", user.Role)
+	}
+	if _, err := users.Authenticate("admin", "new-password"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("password changed by a rejected update: %v", err)
+	}
+
+	if err := users.UpdateUser("ivan", "new-password", entity.RoleOperator); err != nil {
+		t.Fatal(err)
+	}
+	user, err = users.Authenticate("ivan", "new-password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.Role != entity.RoleOperator {
+		t.Errorf("role = %q, want operator", user.Role)
+	}
+}
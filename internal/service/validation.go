@@ -0,0 +1,78 @@
+package service
+
+import (
+	"antizapret-admin-panel/internal/entity"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Границы срока действия сертификата, которые принимает client.sh
+const (
+	MinClientExpiresIn = 1
+	MaxClientExpiresIn = 3650
+)
+
+// ErrClientExists возвращается при создании клиента с именем, которое уже занято клиентом того же типа.
+var ErrClientExists = errors.New("client with this name already exists")
+
+// clientNamePattern — допустимые имена клиентов, то же правило проверяет client.sh.
+// На неподходящее имя скрипт переспрашивает его через read и без терминала не завершается.
+var clientNamePattern = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,32}$`)
+
+// ValidationError описывает неверные поля запроса: имя поля в JSON → сообщение.
+type ValidationError struct {
+	Fields map[string]string
+}
+
+func (e *ValidationError) Error() string {
+	fields := make([]string, 0, len(e.Fields))
+	for field := range e.Fields {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	messages := make([]string, len(fields))
+	for i, field := range fields {
+		messages[i] = field + ": " + e.Fields[field]
+	}
+	return "invalid " + strings.Join(messages, "; ")
+}
+
+// validateClient проверяет имя, тип и срок действия нового клиента. expiresIn 0 — срок по умолчанию.
+// Возвращает *ValidationError или nil.
+func validateClient(name, clientType string, expiresIn int) error {
+	fields := make(map[string]string)
+	if !clientNamePattern.MatchString(name) {
+		fields["name"] = "must be 1-32 characters: latin letters, digits, '_' or '-'"
+	}
+	if clientType != entity.ClientTypeOpenVPN && clientType != entity.ClientTypeWireGuard {
+		fields["type"] = "must be 'openvpn' or 'wireguard'"
+	}
+	if expiresIn != 0 {
+		if msg := expiresInProblem(expiresIn); msg != "" {
+			fields["expires_in"] = msg
+		}
+	}
+	if len(fields) > 0 {
+		return &ValidationError{Fields: fields}
+	}
+	return nil
+}
+
+// ValidateExpiresIn проверяет срок действия сертификата в днях. Возвращает *ValidationError или nil.
+func ValidateExpiresIn(days int) error {
+	if msg := expiresInProblem(days); msg != "" {
+		return &ValidationError{Fields: map[string]string{"expires_in": msg}}
+	}
+	return nil
+}
+
+func expiresInProblem(days int) string {
+	if days < MinClientExpiresIn || days > MaxClientExpiresIn {
+		return fmt.Sprintf("must be between %d and %d days", MinClientExpiresIn, MaxClientExpiresIn)
+	}
+	return ""
+}
@@ -0,0 +1,83 @@
+package service
+
+import (
+	"antizapret-admin-panel/internal/entity"
+	"antizapret-admin-panel/internal/repository"
+	"context"
+	"errors"
+	"sort"
+)
+
+// ErrDisconnectNotSupported возвращается для клиентов WireGuard/AmneziaWG: у них нет management-интерфейса.
+var ErrDisconnectNotSupported = errors.New("disconnect is only supported for OpenVPN clients")
+
+// ErrClientNotConnected возвращается, если у клиента нет ни одного подключения.
+var ErrClientNotConnected = errors.New("client is not connected")
+
+// VPNSessionService показывает и разрывает текущие подключения OpenVPN через management-интерфейс.
+type VPNSessionService interface {
+	// ListSessions возвращает подключения всех инстансов, самые новые первыми
+	ListSessions(ctx context.Context) ([]entity.VPNSession, error)
+	// Disconnect разрывает все подключения клиента, не отзывая его сертификат, и возвращает их число
+	Disconnect(ctx context.Context, id string) (*entity.Client, int, error)
+}
+
+type vpnSessionService struct {
+	clients    repository.ClientRepository
+	management repository.OpenVPNManagement
+}
+
+// NewVPNSessionService — конструктор сервиса подключений.
+func NewVPNSessionService(clients repository.ClientRepository, management repository.OpenVPNManagement) VPNSessionService {
+	return &vpnSessionService{clients: clients, management: management}
+}
+
+func (s *vpnSessionService) ListSessions(ctx context.Context) ([]entity.VPNSession, error) {
+	sessions, err := s.management.Sessions(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	clients, err := s.clients.FindAll()
+	if err != nil {
+		return nil, err
+	}
+	// Отозванный клиент может быть еще подключен, но действующий с тем же именем важнее
+	ids := make(map[string]string)
+	for _, client := range clients {
+		if client.Type != entity.ClientTypeOpenVPN {
+			continue
+		}
+		if _, ok := ids[client.Name]; !ok || client.Status != entity.ClientStatusRevoked {
+			ids[client.Name] = client.ID
+		}
+	}
+	for i := range sessions {
+		sessions[i].ClientID = ids[sessions[i].Name]
+	}
+
+	sort.SliceStable(sessions, func(i, j int) bool {
+		a, b := sessions[i].ConnectedSince, sessions[j].ConnectedSince
+		return a != nil && (b == nil || a.After(*b))
+	})
+	return sessions, nil
+}
+
+func (s *vpnSessionService) Disconnect(ctx context.Context, id string) (*entity.Client, int, error) {
+	client, err := s.clients.FindByID(id)
+	if err != nil {
+		return nil, 0, err
+	}
+	if client.Type != entity.ClientTypeOpenVPN {
+		return client, 0, ErrDisconnectNotSupported
+	}
+
+	killed, err := s.management.Kill(ctx, client.Name)
+	if err != nil {
+		return client, killed, err
+	}
+	if killed == 0 {
+		return client, 0, ErrClientNotConnected
+	}
+	return client, killed, nil
+}
@@ -1,16 +1,24 @@
 package main
 
 import (
+	"context"
 	"embed"
 	"io/fs"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
+	"path/filepath"
 	"strings"
+	"syscall"
+	"time"
 
 	"antizapret-admin-panel/internal/api"
+	"antizapret-admin-panel/internal/config"
+	"antizapret-admin-panel/internal/entity"
 	"antizapret-admin-panel/internal/middleware"
 	"antizapret-admin-panel/internal/repository"
+	"antizapret-admin-panel/internal/server"
 	"antizapret-admin-panel/internal/service"
 
 	"github.com/gin-gonic/gin"
@@ -29,59 +37,268 @@ func main() {
 		log.Println("Переменные из .env файла успешно загружены")
 	}
 
-	router := gin.Default()
-	router.RedirectTrailingSlash = false
-	router.RedirectFixedPath = false
-
 	// --- Dependency Injection ---
 	// Здесь мы "собираем" наше приложение вручную.
 
-	// 1. Получаем конфигурацию из переменных окружения
-	vpnClientsPath := os.Getenv("OPENVPN_CLIENTS_PATH")
-	if vpnClientsPath == "" {
-		vpnClientsPath = "mock_fs/root/antizapret/client/openvpn/vpn-udp/" // Значение по умолчанию для разработки
+	// 1. Загружаем конфигурацию: файл, затем переменные окружения, затем значения по умолчанию
+	cfg, configFile, err := config.Load(os.Getenv("CONFIG_FILE"))
+	if err != nil {
+		log.Fatal("Failed to load config: ", err)
 	}
-	antizapretPath := os.Getenv("OPENVPN_ANTIZAPRET_PATH")
-	if antizapretPath == "" {
-		antizapretPath = "mock_fs/root/antizapret/client/openvpn/antizapret-udp/"
+	dataPath := cfg.DataPath
+
+	// 2. Создаем Репозитории
+	scripts := repository.NewScriptRunner(cfg.AntiZapret.ClientScript)
+	clientRepo := repository.NewClientRepository(repository.ClientPaths{
+		OpenVPNClients:    cfg.OpenVPN.ClientsPath,
+		OpenVPNAntizapret: cfg.OpenVPN.AntizapretPath,
+		OpenVPNStatus:     cfg.OpenVPN.StatusPath,
+		OpenVPNPKI:        cfg.OpenVPN.PKIPath,
+		WireGuardConfig:   cfg.WireGuard.ConfigPath,
+		ClientProfiles:    cfg.AntiZapret.ClientProfiles,
+		Metadata:          filepath.Join(dataPath, "clients.json"),
+	}, scripts)
+	userRepo := repository.NewUserRepository(filepath.Join(dataPath, "users.json"))
+	hostListRepo := repository.NewHostListRepository(cfg.AntiZapret.ConfigPath)
+	antiZapretRepo := repository.NewAntiZapretRepository(cfg.AntiZapret.DoallScript, scripts, filepath.Join(dataPath, "antizapret_apply.json"))
+	backupRepo := repository.NewBackupRepository(repository.BackupPaths{
+		Backups: cfg.Backups.Path,
+		// easyrsa3 — родитель pki: в архив попадают и vars, и сам easyrsa
+		EasyRSA:          filepath.Dir(filepath.Clean(cfg.OpenVPN.PKIPath)),
+		WireGuard:        cfg.WireGuard.ConfigPath,
+		AntiZapretConfig: cfg.AntiZapret.ConfigPath,
+		KnotResolver:     cfg.Backups.KnotResolverPath,
+		AntiZapretRoot:   cfg.AntiZapret.Root,
+	})
+	openvpnManagement := repository.NewOpenVPNManagement(cfg.OpenVPN.ManagementPath)
+	auditRepo := repository.NewAuditRepository(filepath.Join(dataPath, "audit.log"))
+	sessionRepo, err := repository.NewSessionRepository(filepath.Join(dataPath, "sessions.json"))
+	if err != nil {
+		log.Fatal("Failed to load sessions: ", err)
 	}
-	clientScriptPath := os.Getenv("CLIENT_SCRIPT_PATH")
-	if clientScriptPath == "" {
-		clientScriptPath = "./mock_fs/root/antizapret/client.sh"
+	var tokenStore repository.TokenStore
+	switch cfg.Auth.DownloadTokenStore {
+	case "memory":
+		tokenStore = repository.NewMemoryTokenStore()
+	default:
+		tokenStore, err = repository.NewFileTokenStore(filepath.Join(dataPath, "download_tokens.json"))
+		if err != nil {
+			log.Fatal("Failed to load download tokens: ", err)
+		}
 	}
 
-	log.Printf("OPENVPN_CLIENTS_PATH = %s", vpnClientsPath)
-	log.Printf("OPENVPN_ANTIZAPRET_PATH = %s", antizapretPath)
-	log.Printf("CLIENT_SCRIPT_PATH = %s", clientScriptPath)
+	// 3. Создаем Сервисы, внедряя в них репозитории
+	clientService := service.NewClientService(clientRepo)
+	jobQueue := service.NewJobQueue()
+	// Списки и восстановление из архива не меняют файлы, пока работает doall.sh
+	configLock := service.NewConfigLock()
+	hostListService := service.NewHostListService(hostListRepo, configLock)
+	applyQueue := service.NewJobQueue()
+	antiZapretService := service.NewAntiZapretService(antiZapretRepo, applyQueue, configLock)
+	backupService := service.NewBackupService(backupRepo, *cfg.Backups.Keep, configLock)
+	vpnSessionService := service.NewVPNSessionService(clientRepo, openvpnManagement)
+	userService := service.NewUserService(userRepo)
+	auditService := service.NewAuditService(auditRepo)
+	downloadTokenService := service.NewDownloadTokenService(tokenStore)
+	loginLimiter := service.NewLoginLimiter(service.DefaultLoginLimitPolicy, time.Now)
+	totpService := service.NewTOTPService(userRepo, userService)
+	authService := service.NewAuthService(userService, totpService, sessionRepo, loginLimiter, time.Duration(cfg.Auth.SessionTTL))
 
-	// 2. Создаем Репозиторий
-	clientRepo := repository.NewClientRepository(vpnClientsPath, antizapretPath, clientScriptPath)
+	// Подкоманды CLI (например, "user add") выполняются вместо запуска сервера
+	if len(os.Args) > 1 {
+		os.Exit(runCommand(os.Args[1:], userService, totpService))
+	}
 
-	// 3. Создаем Сервис, внедряя в него репозиторий
-	clientService := service.NewClientService(clientRepo)
+	// Сервер не стартует с неверной конфигурацией, чтобы не работать молча с чужими путями
+	if err := cfg.Validate(); err != nil {
+		log.Fatalf("Invalid configuration:\n%v", err)
+	}
+	for _, warning := range cfg.Warnings() {
+		log.Printf("Предупреждение конфигурации: %s", warning)
+	}
 
-	// 4. Создаем Хендлер, внедряя в него сервис
-	clientHandler := api.NewClientHandler(clientService)
+	if configFile == "" {
+		log.Println("Файл конфигурации не найден, используются переменные окружения и значения по умолчанию")
+	} else {
+		log.Printf("Конфигурация загружена из %s", configFile)
+	}
+	log.Printf("antizapret.root = %s", cfg.AntiZapret.Root)
+	log.Printf("antizapret.client_script = %s", cfg.AntiZapret.ClientScript)
+	log.Printf("antizapret.doall_script = %s", cfg.AntiZapret.DoallScript)
+	log.Printf("antizapret.client_profiles = %s", cfg.AntiZapret.ClientProfiles)
+	log.Printf("antizapret.config_path = %s", cfg.AntiZapret.ConfigPath)
+	log.Printf("openvpn.clients_path = %s", cfg.OpenVPN.ClientsPath)
+	log.Printf("openvpn.antizapret_path = %s", cfg.OpenVPN.AntizapretPath)
+	log.Printf("openvpn.status_path = %s", cfg.OpenVPN.StatusPath)
+	log.Printf("openvpn.pki_path = %s", cfg.OpenVPN.PKIPath)
+	log.Printf("openvpn.management_path = %s", cfg.OpenVPN.ManagementPath)
+	log.Printf("wireguard.config_path = %s", cfg.WireGuard.ConfigPath)
+	log.Printf("data_path = %s", dataPath)
+	log.Printf("backups.path = %s", cfg.Backups.Path)
+	log.Printf("backups.keep = %d", *cfg.Backups.Keep)
+	log.Printf("backups.knot_resolver_path = %s", cfg.Backups.KnotResolverPath)
+	log.Printf("shutdown_timeout = %s", time.Duration(cfg.ShutdownTimeout))
+	log.Printf("auth.session_ttl = %s", time.Duration(cfg.Auth.SessionTTL))
+	log.Printf("auth.download_token_store = %s", cfg.Auth.DownloadTokenStore)
+	log.Printf("tls.mode = %s", cfg.TLS.Mode)
+	if cfg.TLS.Mode == config.TLSModeACME {
+		log.Printf("tls.acme.domains = %s", strings.Join(cfg.TLS.ACME.Domains, ", "))
+		log.Printf("tls.acme.directory_url = %s", cfg.TLS.ACME.DirectoryURL)
+	}
+	if cfg.PublicBaseURL == "" {
+		log.Println("public_base_url не задан, QR-коды со ссылками на скачивание недоступны")
+	} else {
+		log.Printf("public_base_url = %s", cfg.PublicBaseURL)
+	}
+
+	bootstrapAdmin(userService)
+
+	// По SIGTERM (systemctl stop/restart) сервер дожидается текущих запросов и запущенных скриптов,
+	// чтобы не оборвать easyrsa посреди выпуска или отзыва сертификата
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// 4. Создаем Хендлеры, внедряя в них сервисы
+	clientHandler := api.NewClientHandler(clientService, jobQueue, downloadTokenService, auditService, cfg.PublicBaseURL)
+	downloadTokenHandler := api.NewDownloadTokenHandler(downloadTokenService, auditService)
+	authHandler := api.NewAuthHandler(authService, auditService)
+	auditHandler := api.NewAuditHandler(auditService)
+	userHandler := api.NewUserHandler(userService)
+	totpHandler := api.NewTOTPHandler(totpService)
+	jobHandler := api.NewJobHandler(ctx, jobQueue, applyQueue)
+	antiZapretHandler := api.NewAntiZapretHandler(antiZapretService, auditService)
+	hostListHandler := api.NewHostListHandler(hostListService, auditService)
+	vpnSessionHandler := api.NewVPNSessionHandler(vpnSessionService, auditService)
+	backupHandler := api.NewBackupHandler(backupService, jobQueue, auditService)
+
+	router := gin.Default()
+	router.RedirectTrailingSlash = false
+	router.RedirectFixedPath = false
+	// Без списка прокси gin верит X-Forwarded-For от любого клиента, и c.ClientIP() можно подменить
+	if err := router.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		log.Fatal("Invalid trusted_proxies: ", err)
+	}
 
 	// --- API Routes ---
 	apiGroup := router.Group("/api")
 	{
-		apiGroup.POST("/login", api.LoginHandler)
-		apiGroup.GET("/check-auth", middleware.AuthMiddleware(), api.CheckAuthHandler)
+		apiGroup.POST("/login", authHandler.Login)
+		apiGroup.POST("/login/totp", authHandler.VerifyTOTP)
+		apiGroup.GET("/check-auth", middleware.AuthMiddleware(authService), authHandler.CheckAuth)
+		apiGroup.POST("/logout", middleware.AuthMiddleware(authService), authHandler.Logout)
 		// Публичный маршрут для скачивания файла по токену
-		apiGroup.GET("/download/:token", api.DownloadFileHandler)
+		apiGroup.GET("/download/:token", clientHandler.DownloadByToken)
+		apiGroup.GET("/audit", middleware.AuthMiddleware(authService), middleware.RequirePermission(entity.PermissionAuditRead), auditHandler.GetAudit)
+
+		authSessions := apiGroup.Group("/auth/sessions")
+		authSessions.Use(middleware.AuthMiddleware(authService), middleware.RequirePermission(entity.PermissionSessionsManage))
+		{
+			authSessions.GET("", authHandler.GetSessions)
+			authSessions.DELETE("/:id", authHandler.RevokeSession)
+		}
+
+		// Второй фактор каждый пользователь подключает для себя сам
+		totp := apiGroup.Group("/auth/totp")
+		totp.Use(middleware.AuthMiddleware(authService))
+		{
+			totp.POST("/enroll", totpHandler.Enroll)
+			totp.POST("/confirm", totpHandler.Confirm)
+			totp.POST("/recovery-codes", totpHandler.RegenerateRecoveryCodes)
+			totp.POST("/disable", totpHandler.Disable)
+		}
+
+		loginLocks := apiGroup.Group("/auth/lockouts")
+		loginLocks.Use(middleware.AuthMiddleware(authService), middleware.RequirePermission(entity.PermissionSessionsManage))
+		{
+			loginLocks.GET("", authHandler.GetLoginLocks)
+			loginLocks.DELETE("/:kind/:key", authHandler.UnlockLogin)
+		}
+
+		users := apiGroup.Group("/users")
+		users.Use(middleware.AuthMiddleware(authService), middleware.RequirePermission(entity.PermissionUsersManage))
+		{
+			users.GET("", userHandler.GetUsers)
+			users.POST("", userHandler.CreateUser)
+			users.PUT("/:username", userHandler.UpdateUser)
+			users.DELETE("/:username", userHandler.DeleteUser)
+			users.DELETE("/:username/totp", totpHandler.Reset)
+		}
+
+		// Каждый маршрут клиентов объявляет право, которое ему нужно
+		canRead := middleware.RequirePermission(entity.PermissionClientsRead)
+		canDownload := middleware.RequirePermission(entity.PermissionClientsDownload)
+		canWrite := middleware.RequirePermission(entity.PermissionClientsWrite)
 
 		protected := apiGroup.Group("/clients")
-		protected.Use(middleware.AuthMiddleware())
+		protected.Use(middleware.AuthMiddleware(authService))
 		{
-			// --- Обновленные роуты ---
-			protected.GET("", clientHandler.GetClients)
-			protected.POST("", clientHandler.CreateClient)
-			protected.GET("/:id/config", clientHandler.DownloadConfig)
-
-			// --- Старые роуты, которые пока не трогали ---
-			protected.DELETE("/:id", clientHandler.DeleteClient)
-			protected.GET("/:id/qr-token", clientHandler.GenerateQRToken)
+			protected.GET("", canRead, clientHandler.GetClients)
+			protected.POST("", canWrite, clientHandler.CreateClient)
+			protected.POST("/bulk", canWrite, clientHandler.BulkCreateClients)
+			protected.GET("/:id/config", canDownload, clientHandler.DownloadConfig)
+			protected.GET("/:id/bundle.zip", canDownload, clientHandler.DownloadBundle)
+			protected.POST("/:id/renew", canWrite, clientHandler.RenewClient)
+			protected.DELETE("/:id", canWrite, clientHandler.DeleteClient)
+			protected.POST("/:id/disconnect", canWrite, vpnSessionHandler.DisconnectClient)
+			protected.POST("/:id/qr-token", canDownload, clientHandler.GenerateQRToken)
+			// Прежний GET оставлен для совместимости
+			protected.GET("/:id/qr-token", canDownload, clientHandler.GenerateQRToken)
+			protected.GET("/:id/qr.png", canDownload, clientHandler.QRCodePNG)
+			protected.GET("/:id/qr.svg", canDownload, clientHandler.QRCodeSVG)
+		}
+
+		// Текущие подключения OpenVPN (не путать с /api/auth/sessions — сессиями входа в панель)
+		apiGroup.GET("/sessions", middleware.AuthMiddleware(authService), canRead, vpnSessionHandler.GetSessions)
+
+		// Статус и вывод фоновых задач: client.sh и doall.sh
+		jobs := apiGroup.Group("/jobs")
+		jobs.Use(middleware.AuthMiddleware(authService), canWrite)
+		{
+			jobs.GET("/:id", jobHandler.GetJob)
+			jobs.GET("/:id/stream", jobHandler.StreamJob)
+		}
+
+		// Списки маршрутизации AntiZapret: include-hosts, exclude-hosts, include-ips
+		lists := apiGroup.Group("/lists")
+		lists.Use(middleware.AuthMiddleware(authService))
+		{
+			canReadLists := middleware.RequirePermission(entity.PermissionListsRead)
+			canWriteLists := middleware.RequirePermission(entity.PermissionListsWrite)
+			lists.GET("", canReadLists, hostListHandler.GetLists)
+			lists.GET("/:name", canReadLists, hostListHandler.GetList)
+			lists.POST("/:name", canWriteLists, hostListHandler.AddEntries)
+			lists.DELETE("/:name", canWriteLists, hostListHandler.RemoveEntries)
+			lists.PUT("/:name", canWriteLists, hostListHandler.ReplaceList)
+		}
+
+		// Применение списков: запуск doall.sh и сведения о последних запусках
+		antizapret := apiGroup.Group("/antizapret")
+		antizapret.Use(middleware.AuthMiddleware(authService))
+		{
+			antizapret.GET("/apply", middleware.RequirePermission(entity.PermissionListsRead), antiZapretHandler.GetApplyStatus)
+			antizapret.POST("/apply", middleware.RequirePermission(entity.PermissionListsWrite), antiZapretHandler.Apply)
+		}
+
+		// Резервные копии содержат закрытые ключи PKI и WireGuard, поэтому доступны только администратору
+		backups := apiGroup.Group("/backups")
+		backups.Use(middleware.AuthMiddleware(authService), middleware.RequirePermission(entity.PermissionBackupsManage))
+		{
+			backups.GET("", backupHandler.GetBackups)
+			backups.POST("", backupHandler.CreateBackup)
+			backups.POST("/upload", backupHandler.UploadBackup)
+			backups.GET("/:name", backupHandler.DownloadBackup)
+			backups.DELETE("/:name", backupHandler.DeleteBackup)
+			backups.GET("/:name/preview", backupHandler.PreviewRestore)
+			backups.POST("/:name/restore", backupHandler.RestoreBackup)
+		}
+
+		// Выданные ссылки на скачивание видят и отзывают те же, кто может их выпускать.
+		// Без права на изменение клиентов — только свои (см. api.tokenOwnerFilter)
+		downloadTokens := apiGroup.Group("/download-tokens")
+		downloadTokens.Use(middleware.AuthMiddleware(authService), canDownload)
+		{
+			downloadTokens.GET("", downloadTokenHandler.GetTokens)
+			downloadTokens.DELETE("/:id", downloadTokenHandler.RevokeToken)
 		}
 	}
 
@@ -135,12 +352,8 @@ func main() {
 		c.Data(http.StatusOK, "text/html; charset=utf-8", buffer)
 	})
 
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
-	}
-	log.Printf("Server starting on port %s", port)
-	if err := router.Run(":" + port); err != nil {
-		log.Fatal("Failed to run server: ", err)
+	if err := server.Run(ctx, cfg, router, scripts.Shutdown); err != nil {
+		log.Fatal("Server stopped with error: ", err)
 	}
+	log.Println("Сервер остановлен")
 }
@@ -133,6 +133,8 @@ addWireGuard(){
     echo "# Client = ${CLIENT_NAME}" >> "$ETC_PATH/wireguard/antizapret.conf"
     echo "# Client = ${CLIENT_NAME}" >> "$ETC_PATH/wireguard/vpn.conf"
 
+	mkdir -p "$ROOT_PATH/client/"{wireguard,amneziawg}/{antizapret,vpn}
+
 	echo "Mock WG config for ${CLIENT_NAME}" > "$ROOT_PATH/client/wireguard/antizapret/antizapret-$FILE_NAME-wg.conf"
 	echo "Mock AWG config for ${CLIENT_NAME}" > "$ROOT_PATH/client/amneziawg/antizapret/antizapret-$FILE_NAME-am.conf"
 	echo "Mock WG config for ${CLIENT_NAME}" > "$ROOT_PATH/client/wireguard/vpn/vpn-$FILE_NAME-wg.conf"
@@ -0,0 +1,34 @@
+#!/bin/bash
+#
+# MOCK SCRIPT for regenerating AntiZapret routing lists.
+# The original doall.sh downloads block lists, merges them with config/*.txt
+# and reloads the DNS resolver and firewall. This one only prints similar output.
+#
+# To be executed from the project root directory.
+# MOCK_DOALL_DELAY sets the pause between steps in seconds, MOCK_DOALL_FAIL=1 makes it fail.
+#
+set -e
+
+DELAY="${MOCK_DOALL_DELAY:-1}"
+CONFIG_PATH="mock_fs/root/antizapret/config"
+
+echo "Update AntiZapret files"
+sleep "$DELAY"
+echo "Mock: downloaded block lists"
+sleep "$DELAY"
+
+echo "Parse AntiZapret files"
+for list in include-hosts exclude-hosts include-ips; do
+	count=$(grep -cv '^\s*\(#\|$\)' "$CONFIG_PATH/$list.txt" || true)
+	echo "Mock: $list.txt — $count entries"
+done
+sleep "$DELAY"
+
+if [ "${MOCK_DOALL_FAIL:-0}" = "1" ]; then
+	echo "Error: mock failure requested" >&2
+	exit 2
+fi
+
+echo "Restart AntiZapret services"
+sleep "$DELAY"
+echo "AntiZapret-VPN update successful!"